@@ -0,0 +1,67 @@
+package overpass
+
+import (
+	"net/http"
+	"sync"
+)
+
+// defaultSuccessThreshold is the number of consecutive successes after which
+// adaptive concurrency grows the limit by one.
+const defaultSuccessThreshold = 10
+
+// AdaptiveConcurrency configures automatic adjustment of the parallel request
+// limit (AIMD): every 429 Too Many Requests response halves the limit, and
+// each run of SuccessThreshold consecutive successful responses raises it by
+// one. The limit always stays within [Min, Max].
+type AdaptiveConcurrency struct {
+	Min              int // lower bound (values below 1 are treated as 1)
+	Max              int // upper bound and starting limit (values below Min are treated as Min)
+	SuccessThreshold int // consecutive successes before growing (default 10)
+}
+
+// WithAdaptiveConcurrency enables adaptive concurrency. It replaces the limit
+// set by WithMaxParallel and starts at config.Max; later calls to
+// SetMaxParallel change the current limit until the next adjustment.
+func WithAdaptiveConcurrency(config AdaptiveConcurrency) Option {
+	return func(c *Client) {
+		c.adaptive = newAdaptiveLimiter(config)
+		c.semaphore = newSemaphore(c.adaptive.config.Max)
+	}
+}
+
+// adaptiveLimiter tracks responses and resizes the client's semaphore.
+type adaptiveLimiter struct {
+	mu        sync.Mutex
+	config    AdaptiveConcurrency
+	successes int
+}
+
+func newAdaptiveLimiter(config AdaptiveConcurrency) *adaptiveLimiter {
+	config.Min = max(config.Min, 1)
+	config.Max = max(config.Max, config.Min)
+
+	if config.SuccessThreshold <= 0 {
+		config.SuccessThreshold = defaultSuccessThreshold
+	}
+
+	return &adaptiveLimiter{config: config}
+}
+
+// observe adjusts the limit of sem after a response with the given status.
+// Statuses other than 200 and 429 leave the limit unchanged.
+func (a *adaptiveLimiter) observe(sem *semaphore, statusCode int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	switch statusCode {
+	case http.StatusTooManyRequests:
+		a.successes = 0
+		sem.resize(max(sem.capacity()/2, a.config.Min))
+	case http.StatusOK:
+		a.successes++
+		if a.successes >= a.config.SuccessThreshold {
+			a.successes = 0
+			sem.resize(min(sem.capacity()+1, a.config.Max))
+		}
+	}
+}
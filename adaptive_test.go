@@ -0,0 +1,90 @@
+package overpass
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+)
+
+// statusSequenceClient answers with the given status codes in order, then 200.
+type statusSequenceClient struct {
+	mu       sync.Mutex
+	statuses []int
+}
+
+func (m *statusSequenceClient) Do(_ *http.Request) (*http.Response, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	status := http.StatusOK
+	if len(m.statuses) > 0 {
+		status, m.statuses = m.statuses[0], m.statuses[1:]
+	}
+
+	return &http.Response{StatusCode: status, Body: newTestBody(`{"elements":[]}`)}, nil
+}
+
+func TestAdaptiveConcurrency(t *testing.T) {
+	t.Parallel()
+
+	mock := &statusSequenceClient{statuses: []int{429, 429, 429, 429}}
+	client := NewClient(
+		WithEndpoint(apiEndpoint),
+		WithHTTPClient(mock),
+		WithRetry(RetryConfig{}),
+		WithAdaptiveConcurrency(AdaptiveConcurrency{Min: 2, Max: 8, SuccessThreshold: 3}),
+	)
+
+	if got := client.semaphore.capacity(); got != 8 {
+		t.Fatalf("expected initial limit 8, got %d", got)
+	}
+
+	query := func() {
+		_, _ = client.QueryContextWith(context.Background(), "node(1);out;", QueryOptions{NoCache: true})
+	}
+
+	// A burst of 429s halves the limit down to the minimum
+	for _, expected := range []int{4, 2, 2, 2} {
+		query()
+
+		if got := client.semaphore.capacity(); got != expected {
+			t.Errorf("after 429: expected limit %d, got %d", expected, got)
+		}
+	}
+
+	// Every third success grows the limit by one, up to the maximum
+	for i, expected := range []int{2, 2, 3, 3, 3, 4} {
+		query()
+
+		if got := client.semaphore.capacity(); got != expected {
+			t.Errorf("after success %d: expected limit %d, got %d", i+1, expected, got)
+		}
+	}
+
+	for i := 0; i < 30; i++ {
+		query()
+	}
+
+	if got := client.semaphore.capacity(); got != 8 {
+		t.Errorf("expected limit capped at 8, got %d", got)
+	}
+}
+
+func TestAdaptiveConcurrencyDefaults(t *testing.T) {
+	t.Parallel()
+
+	limiter := newAdaptiveLimiter(AdaptiveConcurrency{Min: 0, Max: -1})
+	if limiter.config.Min != 1 || limiter.config.Max != 1 || limiter.config.SuccessThreshold != defaultSuccessThreshold {
+		t.Errorf("unexpected normalized config %+v", limiter.config)
+	}
+
+	// Other failures leave the limit alone
+	sem := newSemaphore(4)
+	limiter = newAdaptiveLimiter(AdaptiveConcurrency{Min: 1, Max: 4})
+	limiter.observe(sem, http.StatusServiceUnavailable)
+
+	if got := sem.capacity(); got != 4 {
+		t.Errorf("expected limit 4 after 503, got %d", got)
+	}
+}
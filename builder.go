@@ -1,17 +1,43 @@
 package overpass
 
 import (
+	"errors"
 	"fmt"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 )
 
+// ErrInvalidCoordinates is returned for coordinates outside the valid range.
+var ErrInvalidCoordinates = errors.New("overpass: invalid coordinates")
+
+// ErrFormatMismatch is returned by Client.QueryWithBuilder when the builder's
+// output format differs from the one the client requests.
+var ErrFormatMismatch = errors.New("overpass: output format mismatch")
+
+// ErrNoIDs is reported by QueryBuilder.Validate for an id selection without ids.
+var ErrNoIDs = errors.New("overpass: no ids given")
+
+// idElementTypes is the order in which id selections are emitted.
+var idElementTypes = []string{"node", "way", "relation"} //nolint:gochecknoglobals // fixed output order
+
 // QueryBuilder provides fluent API for building Overpass QL queries.
 type QueryBuilder struct {
-	elements   []string     // element type filters
-	bbox       *BoundingBox // bounding box constraint
-	filters    []TagFilter  // tag filters
-	outputMode string       // output mode
-	settings   []string     // query settings like [out:json]
+	elements   []string           // element type filters
+	bbox       *BoundingBox       // bounding box constraint
+	filters    []TagFilter        // tag filters
+	conditions []string           // parenthesized filters like (if: ...)
+	raw        []string           // verbatim statements added to the union
+	ids        map[string][]int64 // id selections per element type
+	emptyIDs   []string           // element types selected by id without ids
+	recurse    []string           // recurse operators applied to the union (>, >>, <, <<)
+	set        string             // named set receiving the result
+	withNodes  bool               // recurse down to nodes when ways or relations are queried
+	precision  int                // coordinate decimals; 0 means DefaultCoordinatePrecision
+	outputs    []string           // out statements in order; the first is the primary one
+	settings   []string           // query settings like [out:json]
+	format     string             // output format chosen by Format or a parsed [out:...]; empty for the default
 }
 
 // BoundingBox represents geographic bounds (south, west, north, east).
@@ -19,6 +45,66 @@ type BoundingBox struct {
 	South, West, North, East float64
 }
 
+// IsValid reports whether both corners are valid coordinates, South is not
+// above North and West is not east of East. Boxes crossing the antimeridian
+// must be split in two.
+func (b BoundingBox) IsValid() bool {
+	return Point{Lat: b.South, Lon: b.West}.IsValid() &&
+		Point{Lat: b.North, Lon: b.East}.IsValid() &&
+		b.South <= b.North && b.West <= b.East
+}
+
+// ToBox converts b to a Box with its south-west corner as Min and its
+// north-east corner as Max.
+func (b BoundingBox) ToBox() Box {
+	return Box{
+		Min: Point{Lat: b.South, Lon: b.West},
+		Max: Point{Lat: b.North, Lon: b.East},
+	}
+}
+
+// TileBBox divides a bounding box into a grid of rows×cols smaller boxes,
+// ordered row by row from south-west to north-east. Adjacent tiles share
+// their edges exactly, so the grid covers the original box without gaps.
+// It returns nil if rows or cols is not positive.
+func TileBBox(b BoundingBox, rows, cols int) []BoundingBox {
+	if rows <= 0 || cols <= 0 {
+		return nil
+	}
+
+	latEdges := splitRange(b.South, b.North, rows)
+	lonEdges := splitRange(b.West, b.East, cols)
+
+	tiles := make([]BoundingBox, 0, rows*cols)
+
+	for row := 0; row < rows; row++ {
+		for col := 0; col < cols; col++ {
+			tiles = append(tiles, BoundingBox{
+				South: latEdges[row],
+				West:  lonEdges[col],
+				North: latEdges[row+1],
+				East:  lonEdges[col+1],
+			})
+		}
+	}
+
+	return tiles
+}
+
+// splitRange returns n+1 evenly spaced edges from start to end (inclusive).
+func splitRange(start, end float64, n int) []float64 {
+	edges := make([]float64, n+1)
+	step := (end - start) / float64(n)
+
+	for i := 0; i < n; i++ {
+		edges[i] = start + step*float64(i)
+	}
+
+	edges[n] = end
+
+	return edges
+}
+
 // TagFilter represents OSM tag filtering.
 type TagFilter struct {
 	Key      string
@@ -31,11 +117,19 @@ func NewQueryBuilder() *QueryBuilder {
 	return &QueryBuilder{
 		elements:   []string{},
 		filters:    []TagFilter{},
-		outputMode: "out body",
+		conditions: []string{},
+		outputs:    []string{"out body"},
 		settings:   []string{"out:json"},
 	}
 }
 
+// FetchRelation returns a builder for relation id with all its members,
+// recursing into nested relations (>>), output with geometry:
+// [out:json](relation(id); >>;);out geom;
+func FetchRelation(id int64) *QueryBuilder {
+	return NewQueryBuilder().Raw(fmt.Sprintf("relation(%d)", id)).Recurse(">>").OutputGeom()
+}
+
 // Node adds node element type to query.
 func (qb *QueryBuilder) Node() *QueryBuilder {
 	qb.elements = append(qb.elements, "node")
@@ -54,7 +148,8 @@ func (qb *QueryBuilder) Relation() *QueryBuilder {
 	return qb
 }
 
-// BBox sets bounding box constraint.
+// BBox sets bounding box constraint. Out-of-range or inverted boxes are
+// reported by Validate.
 func (qb *QueryBuilder) BBox(south, west, north, east float64) *QueryBuilder {
 	qb.bbox = &BoundingBox{
 		South: south,
@@ -66,6 +161,14 @@ func (qb *QueryBuilder) BBox(south, west, north, east float64) *QueryBuilder {
 	return qb
 }
 
+// BBoxBox sets the bounding box constraint from a Box, using Min as the
+// south-west and Max as the north-east corner.
+func (qb *QueryBuilder) BBoxBox(b Box) *QueryBuilder {
+	bbox := b.ToBoundingBox()
+
+	return qb.BBox(bbox.South, bbox.West, bbox.North, bbox.East)
+}
+
 // Tag adds exact tag match filter.
 func (qb *QueryBuilder) Tag(key, value string) *QueryBuilder {
 	qb.filters = append(qb.filters, TagFilter{
@@ -109,33 +212,210 @@ func (qb *QueryBuilder) TagRegex(key, pattern string) *QueryBuilder {
 	return qb
 }
 
+// If adds an (if: expr) conditional filter to each element query.
+// This is an advanced feature: the expression is passed through verbatim and
+// allows value comparisons plain tag filters can't express, e.g.
+// If(`t["maxspeed"] > 50`).
+func (qb *QueryBuilder) If(expr string) *QueryBuilder {
+	qb.conditions = append(qb.conditions, "(if: "+expr+")")
+	return qb
+}
+
+// ChangedSince restricts each element query to elements changed since t,
+// e.g. (changed:"2024-01-01T00:00:00Z").
+func (qb *QueryBuilder) ChangedSince(t time.Time) *QueryBuilder {
+	qb.conditions = append(qb.conditions, fmt.Sprintf(`(changed:"%s")`, t.UTC().Format(time.RFC3339)))
+	return qb
+}
+
+// ChangedBetween restricts each element query to elements changed between from
+// and to, e.g. (changed:"2024-01-01T00:00:00Z","2024-02-01T00:00:00Z").
+func (qb *QueryBuilder) ChangedBetween(from, to time.Time) *QueryBuilder {
+	qb.conditions = append(qb.conditions, fmt.Sprintf(`(changed:"%s","%s")`,
+		from.UTC().Format(time.RFC3339), to.UTC().Format(time.RFC3339)))
+
+	return qb
+}
+
+// NewerThan restricts each element query to elements last edited after t,
+// e.g. (newer:"2024-01-01T00:00:00Z"). Unlike ChangedSince it only compares
+// the timestamp of the current version.
+func (qb *QueryBuilder) NewerThan(t time.Time) *QueryBuilder {
+	qb.conditions = append(qb.conditions, fmt.Sprintf(`(newer:"%s")`, t.UTC().Format(time.RFC3339)))
+	return qb
+}
+
+// User restricts each element query to elements last edited by the named user.
+// Combine with OutputMeta to see the edit metadata.
+func (qb *QueryBuilder) User(name string) *QueryBuilder {
+	qb.conditions = append(qb.conditions, fmt.Sprintf(`(user:"%s")`, EscapeQL(name)))
+	return qb
+}
+
+// UID restricts each element query to elements last edited by the user id.
+func (qb *QueryBuilder) UID(id int64) *QueryBuilder {
+	qb.conditions = append(qb.conditions, fmt.Sprintf("(uid:%d)", id))
+	return qb
+}
+
+// NodeIDs adds node(id:1,2,3) to the query union, selecting nodes by id.
+// Like Raw statements, id selections are not affected by filters or the bbox,
+// and a builder with only id selections emits nothing else. Repeated calls
+// extend the list. Calling it without ids selects nothing and is reported by
+// Validate.
+func (qb *QueryBuilder) NodeIDs(ids ...int64) *QueryBuilder {
+	return qb.addIDs("node", ids)
+}
+
+// WayIDs adds way(id:...) to the query union; see NodeIDs.
+func (qb *QueryBuilder) WayIDs(ids ...int64) *QueryBuilder {
+	return qb.addIDs("way", ids)
+}
+
+// RelationIDs adds relation(id:...) to the query union; see NodeIDs.
+func (qb *QueryBuilder) RelationIDs(ids ...int64) *QueryBuilder {
+	return qb.addIDs("relation", ids)
+}
+
+func (qb *QueryBuilder) addIDs(elemType string, ids []int64) *QueryBuilder {
+	if len(ids) == 0 {
+		qb.emptyIDs = append(qb.emptyIDs, elemType)
+
+		return qb
+	}
+
+	if qb.ids == nil {
+		qb.ids = map[string][]int64{}
+	}
+
+	qb.ids[elemType] = append(qb.ids[elemType], ids...)
+
+	return qb
+}
+
+// Raw adds a verbatim statement such as `node({{bbox}})` to the query union.
+// The statement is not validated or affected by filters; a missing trailing
+// ";" is added. Raw statements may contain Overpass Turbo macros to be
+// expanded later. If no element type was selected, the builder emits only
+// its raw statements.
+func (qb *QueryBuilder) Raw(statement string) *QueryBuilder {
+	qb.raw = append(qb.raw, strings.TrimSuffix(strings.TrimSpace(statement), ";"))
+
+	return qb
+}
+
+// Recurse adds a recurse statement (">", ">>", "<" or "<<") to the query, so
+// the result also contains e.g. the nodes of matched ways (">"). The element
+// statements are grouped and recursed from in a nested union such as
+// ((node[...]; way[...];); >;);.
+func (qb *QueryBuilder) Recurse(op string) *QueryBuilder {
+	qb.recurse = append(qb.recurse, op)
+	return qb
+}
+
+// WithNodes makes queries for ways or relations also return the nodes they
+// reference by adding a ">" recurse, so node coordinates are available even
+// without "out geom". Node-only queries are unaffected.
+func (qb *QueryBuilder) WithNodes() *QueryBuilder {
+	qb.withNodes = true
+	return qb
+}
+
+// As stores the result in the named set instead of the default set and
+// outputs that set, e.g. (...)->.cafes;.cafes out body;.
+func (qb *QueryBuilder) As(set string) *QueryBuilder {
+	qb.set = strings.TrimPrefix(set, ".")
+	return qb
+}
+
+// IsIn adds an is_in(lat,lon) statement returning the areas that contain the
+// coordinate, e.g. is_in(52.5,13.4);. Like Raw statements it replaces the
+// default element types. The coordinate is written with the builder's
+// Precision at the time of the call.
+func (qb *QueryBuilder) IsIn(lat, lon float64) *QueryBuilder {
+	return qb.Raw(fmt.Sprintf("is_in(%s,%s)", qb.formatCoordinate(lat), qb.formatCoordinate(lon)))
+}
+
 // Output sets output mode (body, skel, ids, tags, meta, center, geom, bb).
 func (qb *QueryBuilder) Output(mode string) *QueryBuilder {
-	qb.outputMode = "out " + mode
+	return qb.setOutput("out " + mode)
+}
+
+// AddOutput appends a further out statement with the given mode, e.g.
+// Output("ids").AddOutput("skel") yields "out ids;out skel;". The Output
+// methods keep replacing the first statement.
+func (qb *QueryBuilder) AddOutput(mode string) *QueryBuilder {
+	qb.outputs = append(qb.outputs, "out "+mode)
+	return qb
+}
+
+// setOutput replaces the primary out statement.
+func (qb *QueryBuilder) setOutput(output string) *QueryBuilder {
+	if len(qb.outputs) == 0 {
+		qb.outputs = []string{output}
+	} else {
+		qb.outputs[0] = output
+	}
+
 	return qb
 }
 
 // OutputBody outputs all information (default).
 func (qb *QueryBuilder) OutputBody() *QueryBuilder {
-	qb.outputMode = "out body"
-	return qb
+	return qb.setOutput("out body")
 }
 
 // OutputGeom outputs with geometry (for ways/relations).
 func (qb *QueryBuilder) OutputGeom() *QueryBuilder {
-	qb.outputMode = "out geom"
-	return qb
+	return qb.setOutput("out geom")
 }
 
 // OutputCenter outputs center point only.
 func (qb *QueryBuilder) OutputCenter() *QueryBuilder {
-	qb.outputMode = "out center"
-	return qb
+	return qb.setOutput("out center")
+}
+
+// OutputBB outputs tags and bounding boxes of ways and relations without
+// their geometry, which is much lighter than OutputGeom.
+func (qb *QueryBuilder) OutputBB() *QueryBuilder {
+	return qb.setOutput("out bb")
 }
 
 // OutputMeta outputs with metadata.
 func (qb *QueryBuilder) OutputMeta() *QueryBuilder {
-	qb.outputMode = "out meta"
+	return qb.setOutput("out meta")
+}
+
+// Format sets the output format setting, e.g. Format("xml") yields [out:xml].
+// The setting keeps its position; builders start with [out:json].
+func (qb *QueryBuilder) Format(format string) *QueryBuilder {
+	qb.format = format
+
+	for i, s := range qb.settings {
+		if strings.HasPrefix(s, "out:") {
+			qb.settings[i] = "out:" + format
+			return qb
+		}
+	}
+
+	qb.settings = append([]string{"out:" + format}, qb.settings...)
+
+	return qb
+}
+
+// withFormat returns a copy of qb using format, leaving qb unchanged.
+func (qb *QueryBuilder) withFormat(format string) *QueryBuilder {
+	clone := *qb
+	clone.settings = append([]string(nil), qb.settings...)
+
+	return clone.Format(format)
+}
+
+// Precision sets how many decimals bounding box and IsIn coordinates are
+// written with (default DefaultCoordinatePrecision). Trailing zeros are always
+// dropped; values below 1 restore the default. Set it before calling IsIn.
+func (qb *QueryBuilder) Precision(decimals int) *QueryBuilder {
+	qb.precision = decimals
 	return qb
 }
 
@@ -154,6 +434,21 @@ func (qb *QueryBuilder) Timeout(seconds int) *QueryBuilder {
 	return qb
 }
 
+// Validate reports problems Build cannot express: an invalid bounding box
+// (see BoundingBox.IsValid) or an id selection without ids.
+func (qb *QueryBuilder) Validate() error {
+	if qb.bbox != nil && !qb.bbox.IsValid() {
+		return fmt.Errorf("%w: bbox %v,%v,%v,%v", ErrInvalidCoordinates,
+			qb.bbox.South, qb.bbox.West, qb.bbox.North, qb.bbox.East)
+	}
+
+	if len(qb.emptyIDs) > 0 {
+		return fmt.Errorf("%w: %s selection", ErrNoIDs, qb.emptyIDs[0])
+	}
+
+	return nil
+}
+
 // Build constructs the Overpass QL query string.
 func (qb *QueryBuilder) Build() string {
 	parts := make([]string, 0, 10)
@@ -163,37 +458,128 @@ func (qb *QueryBuilder) Build() string {
 		parts = append(parts, "["+strings.Join(qb.settings, "][")+"]")
 	}
 
-	// If no element types specified, use all
+	statement := qb.statementTree().render()
+	if qb.set != "" {
+		statement += "->." + qb.set
+	}
+
+	parts = append(parts, statement+";")
+
+	for _, output := range qb.outputs {
+		if qb.set != "" {
+			output = "." + qb.set + " " + output
+		}
+
+		parts = append(parts, output+";")
+	}
+
+	return strings.Join(parts, "")
+}
+
+// CountByValue builds a query counting the builder's matches separately for
+// each value of key, e.g. node["cuisine"="pizza"];out count; for every value.
+// The server does the counting, so only one small count element per value is
+// transferred; Result.Counts holds them in the order of values. The values
+// must be known in advance; to discover them, download the elements and use
+// Result.Histogram instead. Output modes and named sets of the builder are
+// ignored.
+func (qb *QueryBuilder) CountByValue(key string, values ...string) string {
+	var sb strings.Builder
+
+	if len(qb.settings) > 0 {
+		sb.WriteString("[" + strings.Join(qb.settings, "][") + "]")
+	}
+
+	for _, value := range values {
+		clone := *qb
+		clone.filters = append(slices.Clip(qb.filters), TagFilter{Key: key, Value: value, Operator: "="})
+
+		sb.WriteString(clone.statementTree().render() + ";out count;")
+	}
+
+	return sb.String()
+}
+
+// qlStatement is a node of the statement tree rendered by Build.
+type qlStatement interface {
+	render() string // statement text without the terminating ";"
+}
+
+// qlClause is a single statement such as node["amenity"="cafe"] or >.
+type qlClause string
+
+func (c qlClause) render() string {
+	return string(c)
+}
+
+// qlUnion groups statements as (a; b; ...).
+type qlUnion []qlStatement
+
+func (u qlUnion) render() string {
+	members := make([]string, len(u))
+	for i, member := range u {
+		members[i] = member.render() + ";"
+	}
+
+	return "(" + strings.Join(members, " ") + ")"
+}
+
+// statementTree builds the query body: element and raw statements, unioned
+// if there are several, wrapped in a further union with any recurse
+// statements.
+func (qb *QueryBuilder) statementTree() qlStatement {
+	// If no element types specified, use all unless raw or id statements stand
+	// in; an empty id selection must not widen the query to the whole planet
 	elements := qb.elements
-	if len(elements) == 0 {
+	if len(elements) == 0 && len(qb.raw) == 0 && len(qb.ids) == 0 && len(qb.emptyIDs) == 0 {
 		elements = []string{"node", "way", "relation"}
 	}
 
-	// Union of element queries
-	if len(elements) > 1 {
-		parts = append(parts, "(")
+	filterSuffix := qb.buildFilterString() + strings.Join(qb.conditions, "")
+	bboxSuffix := qb.buildBboxString()
+
+	members := make(qlUnion, 0, len(elements)+len(qb.ids)+len(qb.raw))
+	for _, elemType := range elements {
+		members = append(members, qlClause(elemType+filterSuffix+bboxSuffix))
 	}
 
-	filterSuffix := qb.buildFilterString()
-	bboxSuffix := qb.buildBboxString()
+	for _, elemType := range idElementTypes {
+		if ids := qb.ids[elemType]; len(ids) > 0 {
+			members = append(members, qlClause(idClause(elemType, ids)))
+		}
+	}
 
-	for i, elemType := range elements {
-		if i > 0 {
-			parts = append(parts, " ")
+	for _, raw := range qb.raw {
+		// Keep multi-statement raw input together as one group
+		if strings.Contains(raw, ";") {
+			members = append(members, qlUnion{qlClause(raw)})
+		} else {
+			members = append(members, qlClause(raw))
 		}
+	}
 
-		query := elemType + filterSuffix + bboxSuffix + ";"
-		parts = append(parts, query)
+	var root qlStatement = members
+	if len(members) == 1 {
+		root = members[0]
 	}
 
-	if len(elements) > 1 {
-		parts = append(parts, ");")
+	recurse := qb.recurse
+	hasWays := slices.ContainsFunc(elements, func(e string) bool { return e != "node" }) ||
+		len(qb.ids["way"]) > 0 || len(qb.ids["relation"]) > 0
+	if qb.withNodes && hasWays && !slices.Contains(recurse, ">") {
+		recurse = append(slices.Clip(recurse), ">")
 	}
 
-	// Output
-	parts = append(parts, qb.outputMode+";")
+	if len(recurse) > 0 {
+		recursed := qlUnion{root}
+		for _, op := range recurse {
+			recursed = append(recursed, qlClause(op))
+		}
+
+		root = recursed
+	}
 
-	return strings.Join(parts, "")
+	return root
 }
 
 // String implements Stringer interface.
@@ -205,15 +591,17 @@ func (qb *QueryBuilder) String() string {
 func (qb *QueryBuilder) buildFilterString() string {
 	var filters string
 	for _, filter := range qb.filters {
+		key, value := EscapeQL(filter.Key), EscapeQL(filter.Value)
+
 		switch filter.Operator {
 		case "=":
-			filters += fmt.Sprintf(`["%s"="%s"]`, filter.Key, filter.Value)
+			filters += fmt.Sprintf(`["%s"="%s"]`, key, value)
 		case "!=":
-			filters += fmt.Sprintf(`["%s"!="%s"]`, filter.Key, filter.Value)
+			filters += fmt.Sprintf(`["%s"!="%s"]`, key, value)
 		case "~":
-			filters += fmt.Sprintf(`["%s"~"%s"]`, filter.Key, filter.Value)
+			filters += fmt.Sprintf(`["%s"~"%s"]`, key, value)
 		case "exists":
-			filters += fmt.Sprintf(`["%s"]`, filter.Key)
+			filters += fmt.Sprintf(`["%s"]`, key)
 		}
 	}
 
@@ -226,8 +614,30 @@ func (qb *QueryBuilder) buildBboxString() string {
 		return ""
 	}
 
-	return fmt.Sprintf("(%.6f,%.6f,%.6f,%.6f)",
-		qb.bbox.South, qb.bbox.West, qb.bbox.North, qb.bbox.East)
+	coords := []float64{qb.bbox.South, qb.bbox.West, qb.bbox.North, qb.bbox.East}
+	formatted := make([]string, len(coords))
+
+	for i, v := range coords {
+		formatted[i] = qb.formatCoordinate(v)
+	}
+
+	return "(" + strings.Join(formatted, ",") + ")"
+}
+
+// formatCoordinate formats v with the builder's precision.
+func (qb *QueryBuilder) formatCoordinate(v float64) string {
+	precision := qb.precision
+	if precision <= 0 {
+		precision = DefaultCoordinatePrecision
+	}
+
+	return FormatCoordinate(v, precision)
+}
+
+// EscapeQL escapes backslashes and double quotes so s can be written inside a
+// double-quoted Overpass QL string, e.g. as a tag value.
+func EscapeQL(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s)
 }
 
 // Helper functions for common queries
@@ -261,6 +671,22 @@ func FindAmenity(south, west, north, east float64, amenityType string) *QueryBui
 		OutputCenter()
 }
 
+// CorridorQuery builds a query for the target elements within radiusMeters of
+// the elements matched by base, e.g. fuel stations along a motorway:
+//
+//	CorridorQuery(`way["ref"="A10"]`, 500, `node["amenity"="fuel"]`)
+//
+// yields [out:json]way["ref"="A10"]->.route;node["amenity"="fuel"](around.route:500);out center;
+// Both selectors are used verbatim; a trailing ";" is dropped. Only the
+// target elements are returned.
+func CorridorQuery(base string, radiusMeters float64, target string) string {
+	base = strings.TrimSuffix(strings.TrimSpace(base), ";")
+	target = strings.TrimSuffix(strings.TrimSpace(target), ";")
+
+	return "[out:json]" + base + "->.route;" +
+		target + "(around.route:" + strconv.FormatFloat(radiusMeters, 'f', -1, 64) + ");out center;"
+}
+
 // FindByTag creates query for elements with specific tag in bounding box.
 func FindByTag(south, west, north, east float64, key, value string) *QueryBuilder {
 	return NewQueryBuilder().
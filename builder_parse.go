@@ -0,0 +1,371 @@
+package overpass
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrQueryTooComplex is returned by ParseQuery for valid queries that use
+// features a QueryBuilder cannot represent.
+var ErrQueryTooComplex = errors.New("overpass: query too complex for QueryBuilder")
+
+// ParseQuery parses a simple Overpass QL query back into a QueryBuilder, e.g.
+// to edit a query produced by Build. Supported are a settings header, a single
+// element statement or one union of element statements sharing the same
+// filters, and one or more final out statements. Tag filters ([k], [k=v],
+// [k!=v], [k~v]) and a bounding box become builder filters; other
+// parenthesized filters such as (around:...) or (if: ...) are kept verbatim.
+// Recursion, named sets and multiple element statements yield
+// ErrQueryTooComplex; syntax errors yield ErrMalformedQuery.
+func ParseQuery(ql string) (*QueryBuilder, error) {
+	qb := &QueryBuilder{}
+
+	rest, err := qb.parseSettings(strings.TrimSpace(ql))
+	if err != nil {
+		return nil, err
+	}
+
+	statements, err := splitStatements(rest)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(statements) < 2 {
+		return nil, fmt.Errorf("%w: expected an element statement followed by out statements, got %d statements",
+			ErrQueryTooComplex, len(statements))
+	}
+
+	for _, statement := range statements[1:] {
+		output := strings.Join(strings.Fields(statement), " ")
+		if output != "out" && !strings.HasPrefix(output, "out ") {
+			return nil, fmt.Errorf("%w: expected out statement, got %q", ErrQueryTooComplex, statement)
+		}
+
+		qb.outputs = append(qb.outputs, output)
+	}
+
+	err = qb.parseBody(statements[0])
+	if err != nil {
+		return nil, err
+	}
+
+	return qb, nil
+}
+
+// parseSettings consumes the leading [key:value] settings of a query.
+func (qb *QueryBuilder) parseSettings(ql string) (string, error) {
+	settings, rest, err := cutSettings(ql)
+	if err != nil {
+		return "", err
+	}
+
+	qb.settings = settings
+
+	for _, setting := range settings {
+		if format, ok := strings.CutPrefix(setting, "out:"); ok {
+			qb.format = format
+		}
+	}
+
+	return rest, nil
+}
+
+// cutSettings splits the leading [key:value] settings off ql. The settings
+// may or may not be terminated by a ';' before the first statement.
+func cutSettings(ql string) ([]string, string, error) {
+	settings := []string{}
+
+	for strings.HasPrefix(ql, "[") {
+		end, err := matchingBracket(ql, 0)
+		if err != nil {
+			return nil, "", err
+		}
+
+		settings = append(settings, ql[1:end])
+		ql = strings.TrimSpace(ql[end+1:])
+	}
+
+	return settings, strings.TrimSpace(strings.TrimPrefix(ql, ";")), nil
+}
+
+// parseBody parses a single element statement or a union of them.
+func (qb *QueryBuilder) parseBody(body string) error {
+	members := []string{body}
+
+	if strings.HasPrefix(body, "(") {
+		end, err := matchingBracket(body, 0)
+		if err != nil {
+			return err
+		}
+
+		if end != len(body)-1 {
+			return fmt.Errorf("%w: unexpected %q after union", ErrQueryTooComplex, body[end+1:])
+		}
+
+		members, err = splitStatements(body[1:end])
+		if err != nil {
+			return err
+		}
+	}
+
+	var suffix string
+
+	for i, member := range members {
+		parsed := &QueryBuilder{}
+
+		err := parsed.parseElement(member)
+		if err != nil {
+			return err
+		}
+
+		memberSuffix := parsed.buildFilterString() + strings.Join(parsed.conditions, "") + parsed.buildBboxString()
+		if i == 0 {
+			suffix = memberSuffix
+			qb.filters, qb.conditions, qb.bbox = parsed.filters, parsed.conditions, parsed.bbox
+		} else if memberSuffix != suffix {
+			return fmt.Errorf("%w: union members use different filters", ErrQueryTooComplex)
+		}
+
+		qb.elements = append(qb.elements, parsed.elements...)
+	}
+
+	return nil
+}
+
+// parseElement parses a statement such as node["amenity"="cafe"](1,2,3,4).
+func (qb *QueryBuilder) parseElement(statement string) error {
+	statement = strings.TrimSpace(statement)
+
+	typeEnd := strings.IndexFunc(statement, func(r rune) bool { return r < 'a' || r > 'z' })
+	if typeEnd < 0 {
+		typeEnd = len(statement)
+	}
+
+	switch statement[:typeEnd] {
+	case "node", "way", "relation":
+		qb.elements = []string{statement[:typeEnd]}
+	case "nwr":
+		qb.elements = []string{"node", "way", "relation"}
+	default:
+		return fmt.Errorf("%w: unsupported statement %q", ErrQueryTooComplex, statement)
+	}
+
+	for rest := strings.TrimSpace(statement[typeEnd:]); rest != ""; {
+		if rest[0] != '[' && rest[0] != '(' {
+			return fmt.Errorf("%w: unsupported syntax %q", ErrQueryTooComplex, rest)
+		}
+
+		end, err := matchingBracket(rest, 0)
+		if err != nil {
+			return err
+		}
+
+		if rest[0] == '[' {
+			err = qb.parseTagFilter(rest[1:end])
+		} else {
+			qb.parseParenFilter(rest[1:end])
+		}
+
+		if err != nil {
+			return err
+		}
+
+		rest = strings.TrimSpace(rest[end+1:])
+	}
+
+	return nil
+}
+
+// parseTagFilter parses the inside of a [...] tag filter.
+func (qb *QueryBuilder) parseTagFilter(filter string) error {
+	key, rest, err := parseQLString(filter)
+	if err != nil {
+		return err
+	}
+
+	if key == "" {
+		return fmt.Errorf("%w: missing key in [%s]", ErrMalformedQuery, filter)
+	}
+
+	if rest == "" {
+		qb.filters = append(qb.filters, TagFilter{Key: key, Operator: "exists"})
+		return nil
+	}
+
+	var operator string
+
+	for _, op := range []string{"!=", "=", "~"} {
+		if strings.HasPrefix(rest, op) {
+			operator = op
+			break
+		}
+	}
+
+	if operator == "" {
+		return fmt.Errorf("%w: unsupported tag filter [%s]", ErrQueryTooComplex, filter)
+	}
+
+	value, rest, err := parseQLString(rest[len(operator):])
+	if err != nil {
+		return err
+	}
+
+	if rest != "" {
+		return fmt.Errorf("%w: unsupported tag filter [%s]", ErrQueryTooComplex, filter)
+	}
+
+	qb.filters = append(qb.filters, TagFilter{Key: key, Value: value, Operator: operator})
+
+	return nil
+}
+
+// parseParenFilter parses the inside of a (...) filter: four numbers form the
+// bounding box, anything else is kept as a verbatim condition.
+func (qb *QueryBuilder) parseParenFilter(filter string) {
+	parts := strings.Split(filter, ",")
+	if len(parts) == 4 {
+		coords := make([]float64, 4)
+
+		valid := true
+
+		for i, part := range parts {
+			value, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+			if err != nil {
+				valid = false
+				break
+			}
+
+			coords[i] = value
+		}
+
+		if valid {
+			qb.BBox(coords[0], coords[1], coords[2], coords[3])
+			return
+		}
+	}
+
+	qb.conditions = append(qb.conditions, "("+filter+")")
+}
+
+// parseQLString reads a quoted or bare key/value at the start of s and returns
+// it with the remaining text.
+func parseQLString(s string) (string, string, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return "", "", fmt.Errorf("%w: empty tag filter", ErrMalformedQuery)
+	}
+
+	if s[0] == '"' || s[0] == '\'' {
+		end := closingQuote(s, 0)
+		if end < 0 {
+			return "", "", fmt.Errorf("%w: unterminated string %s", ErrMalformedQuery, s)
+		}
+
+		value := s[1:end]
+		if s[0] == '"' {
+			unquoted, err := strconv.Unquote(s[:end+1])
+			if err != nil {
+				return "", "", fmt.Errorf("%w: %w", ErrMalformedQuery, err)
+			}
+
+			value = unquoted
+		}
+
+		return value, strings.TrimSpace(s[end+1:]), nil
+	}
+
+	end := strings.IndexAny(s, "!=~")
+	if end < 0 {
+		end = len(s)
+	}
+
+	return strings.TrimSpace(s[:end]), s[end:], nil
+}
+
+// splitStatements splits QL text at top-level semicolons, ignoring those
+// inside strings and brackets. Empty statements are dropped.
+func splitStatements(ql string) ([]string, error) {
+	var statements []string
+
+	start, depth := 0, 0
+
+	for i := 0; i < len(ql); i++ {
+		switch ql[i] {
+		case '"', '\'':
+			end := closingQuote(ql, i)
+			if end < 0 {
+				return nil, fmt.Errorf("%w: unterminated string at offset %d", ErrMalformedQuery, i)
+			}
+
+			i = end
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			depth--
+			if depth < 0 {
+				return nil, fmt.Errorf("%w: unexpected %q at offset %d", ErrMalformedQuery, ql[i], i)
+			}
+		case ';':
+			if depth == 0 {
+				if statement := strings.TrimSpace(ql[start:i]); statement != "" {
+					statements = append(statements, statement)
+				}
+
+				start = i + 1
+			}
+		}
+	}
+
+	if depth != 0 {
+		return nil, fmt.Errorf("%w: unclosed bracket", ErrMalformedQuery)
+	}
+
+	if statement := strings.TrimSpace(ql[start:]); statement != "" {
+		statements = append(statements, statement)
+	}
+
+	return statements, nil
+}
+
+// matchingBracket returns the index of the bracket closing the one at open.
+func matchingBracket(s string, open int) (int, error) {
+	depth := 0
+
+	for i := open; i < len(s); i++ {
+		switch s[i] {
+		case '"', '\'':
+			end := closingQuote(s, i)
+			if end < 0 {
+				return 0, fmt.Errorf("%w: unterminated string at offset %d", ErrMalformedQuery, i)
+			}
+
+			i = end
+		case '(', '[':
+			depth++
+		case ')', ']':
+			depth--
+			if depth == 0 {
+				return i, nil
+			}
+		}
+	}
+
+	return 0, fmt.Errorf("%w: unclosed %q", ErrMalformedQuery, s[open])
+}
+
+// closingQuote returns the index of the quote ending the string literal that
+// starts at start, or -1 if it is unterminated.
+func closingQuote(s string, start int) int {
+	for i := start + 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case s[start]:
+			return i
+		}
+	}
+
+	return -1
+}
@@ -0,0 +1,100 @@
+package overpass
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseQueryRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name    string
+		builder *QueryBuilder
+	}{
+		{"single node", NewQueryBuilder().Node().Tag("amenity", "cafe").BBox(52.5, 13.3, 52.6, 13.5)},
+		{"union", NewQueryBuilder().Node().Way().Tag("amenity", "school").TagExists("name").OutputCenter()},
+		{"all filters", NewQueryBuilder().Relation().TagNot("access", "private").TagRegex("name", "^Berlin").Timeout(25).OutputGeom()},
+		{"conditions", NewQueryBuilder().Way().If(`t["maxspeed"] > 50`).UID(42).Output("tags")},
+		{"default elements", NewQueryBuilder().Tag("shop", "bakery")},
+		{"multiple outputs", NewQueryBuilder().Way().Tag("highway", "primary").Output("ids").AddOutput("skel qt")},
+		{"escaped values", NewQueryBuilder().Node().Tag("name", `He said "hi"`).TagRegex(`ref\"x`, `^A\d`)},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			query := tc.builder.Build()
+
+			parsed, err := ParseQuery(query)
+			if err != nil {
+				t.Fatalf("ParseQuery(%q) failed: %v", query, err)
+			}
+
+			if rebuilt := parsed.Build(); rebuilt != query {
+				t.Errorf("round trip mismatch:\n%s\n%s", query, rebuilt)
+			}
+		})
+	}
+}
+
+func TestParseQueryState(t *testing.T) {
+	t.Parallel()
+
+	parsed, err := ParseQuery(`[out:json][timeout:60];
+		( node[amenity=cafe]["name"](around:500,52.5,13.4);
+		  way[amenity=cafe]["name"](around:500,52.5,13.4); );
+		out   center;`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !reflect.DeepEqual(parsed.settings, []string{"out:json", "timeout:60"}) {
+		t.Errorf("unexpected settings %v", parsed.settings)
+	}
+
+	if !reflect.DeepEqual(parsed.elements, []string{"node", "way"}) {
+		t.Errorf("unexpected elements %v", parsed.elements)
+	}
+
+	expectedFilters := []TagFilter{{Key: "amenity", Value: "cafe", Operator: "="}, {Key: "name", Operator: "exists"}}
+	if !reflect.DeepEqual(parsed.filters, expectedFilters) {
+		t.Errorf("unexpected filters %+v", parsed.filters)
+	}
+
+	if !reflect.DeepEqual(parsed.conditions, []string{"(around:500,52.5,13.4)"}) {
+		t.Errorf("unexpected conditions %v", parsed.conditions)
+	}
+
+	if !reflect.DeepEqual(parsed.outputs, []string{"out center"}) {
+		t.Errorf("unexpected outputs %q", parsed.outputs)
+	}
+}
+
+func TestParseQueryErrors(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		query string
+		want  error
+	}{
+		{"[out:json];way(1);>;out;", ErrQueryTooComplex},
+		{"[out:json];(node[a]; way[b];);out;", ErrQueryTooComplex},
+		{"[out:json];node[a]->.x;out;", ErrQueryTooComplex},
+		{"[out:json];area[name=X];out;", ErrQueryTooComplex},
+		{"[out:json];node[name~\"x\",i];out;", ErrQueryTooComplex},
+		{"[out:json];node[a];", ErrQueryTooComplex},
+		{"[out:json];node[\"a];out;", ErrMalformedQuery},
+		{"[out:json];(node[a];out;", ErrMalformedQuery},
+	}
+
+	for _, tc := range testCases {
+		if _, err := ParseQuery(tc.query); !errors.Is(err, tc.want) {
+			t.Errorf("%q: expected %v, got %v", tc.query, tc.want, err)
+		}
+	}
+}
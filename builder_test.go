@@ -1,8 +1,10 @@
 package overpass
 
 import (
+	"errors"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestNewQueryBuilder(t *testing.T) {
@@ -43,7 +45,7 @@ func TestBuilderBoundingBox(t *testing.T) {
 		Tag("amenity", "cafe").
 		Build()
 
-	if !strings.Contains(query, "(52.500000,13.400000,52.510000,13.410000)") {
+	if !strings.Contains(query, "(52.5,13.4,52.51,13.41)") {
 		t.Errorf("bounding box not formatted correctly: %s", query)
 	}
 }
@@ -160,6 +162,11 @@ func TestBuilderOutputModes(t *testing.T) {
 			NewQueryBuilder().Node().OutputMeta(),
 			"out meta;",
 		},
+		{
+			"bb",
+			NewQueryBuilder().Way().OutputBB(),
+			"out bb;",
+		},
 		{
 			"custom",
 			NewQueryBuilder().Node().Output("skel"),
@@ -232,7 +239,7 @@ func TestBuilderChaining(t *testing.T) {
 		"way",
 		`["amenity"="restaurant"]`,
 		`["cuisine"="italian"]`,
-		"(52.500000,13.400000,52.510000,13.410000)",
+		"(52.5,13.4,52.51,13.41)",
 		"out center;",
 	}
 
@@ -441,3 +448,567 @@ func TestBuilderThreeElements(t *testing.T) {
 		t.Error("three elements should use union syntax")
 	}
 }
+
+func TestTileBBox(t *testing.T) {
+	t.Parallel()
+
+	box := BoundingBox{South: 52.0, West: 13.0, North: 53.0, East: 15.0}
+
+	tiles := TileBBox(box, 2, 4)
+	if len(tiles) != 8 {
+		t.Fatalf("expected 8 tiles, got %d", len(tiles))
+	}
+
+	// Tiles must be contiguous and stay within the original box
+	for i, tile := range tiles {
+		row, col := i/4, i%4
+
+		if tile.South < box.South || tile.North > box.North || tile.West < box.West || tile.East > box.East {
+			t.Errorf("tile %d outside original box: %+v", i, tile)
+		}
+
+		if col > 0 && tile.West != tiles[i-1].East {
+			t.Errorf("gap or overlap between tile %d and %d", i-1, i)
+		}
+
+		if row > 0 && tile.South != tiles[i-4].North {
+			t.Errorf("gap or overlap between tile %d and %d", i-4, i)
+		}
+	}
+
+	// Total area must equal the original box area
+	var area float64
+	for _, tile := range tiles {
+		area += (tile.North - tile.South) * (tile.East - tile.West)
+	}
+
+	if diff := area - 2.0; diff > 1e-9 || diff < -1e-9 {
+		t.Errorf("tiles cover area %f, want 2.0", area)
+	}
+
+	first, last := tiles[0], tiles[len(tiles)-1]
+	if first.South != box.South || first.West != box.West || last.North != box.North || last.East != box.East {
+		t.Errorf("grid does not span the original box: first=%+v last=%+v", first, last)
+	}
+}
+
+func TestTileBBoxSingle(t *testing.T) {
+	t.Parallel()
+
+	box := BoundingBox{South: 1, West: 2, North: 3, East: 4}
+
+	tiles := TileBBox(box, 1, 1)
+	if len(tiles) != 1 || tiles[0] != box {
+		t.Errorf("expected the original box, got %+v", tiles)
+	}
+
+	if tiles := TileBBox(box, 0, 3); tiles != nil {
+		t.Errorf("expected nil for zero rows, got %+v", tiles)
+	}
+}
+
+func TestBuilderIf(t *testing.T) {
+	t.Parallel()
+
+	query := NewQueryBuilder().
+		Way().
+		TagExists("maxspeed").
+		If(`t["maxspeed"] > 50`).
+		Build()
+
+	expected := `[out:json]way["maxspeed"](if: t["maxspeed"] > 50);out body;`
+	if query != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, query)
+	}
+}
+
+func TestBuilderIfWithBBox(t *testing.T) {
+	t.Parallel()
+
+	query := NewQueryBuilder().
+		Node().
+		Way().
+		BBox(1, 2, 3, 4).
+		If(`is_closed()`).
+		Build()
+
+	if !strings.Contains(query, `node(if: is_closed())(1,2,3,4);`) {
+		t.Errorf("if filter not applied to node clause: %s", query)
+	}
+
+	if !strings.Contains(query, `way(if: is_closed())(1,2,3,4);`) {
+		t.Errorf("if filter not applied to way clause: %s", query)
+	}
+}
+
+func TestBuilderEditFilters(t *testing.T) {
+	t.Parallel()
+
+	since := time.Date(2024, 3, 1, 12, 0, 0, 0, time.FixedZone("CET", 3600))
+
+	testCases := []struct {
+		name     string
+		builder  *QueryBuilder
+		expected string
+	}{
+		{
+			"changed since",
+			NewQueryBuilder().Node().ChangedSince(since).OutputMeta(),
+			`[out:json]node(changed:"2024-03-01T11:00:00Z");out meta;`,
+		},
+		{
+			"changed between",
+			NewQueryBuilder().Way().ChangedBetween(since, since.Add(30*24*time.Hour)).OutputMeta(),
+			`[out:json]way(changed:"2024-03-01T11:00:00Z","2024-03-31T11:00:00Z");out meta;`,
+		},
+		{
+			"newer than",
+			NewQueryBuilder().Node().Tag("shop", "bakery").NewerThan(since),
+			`[out:json]node["shop"="bakery"](newer:"2024-03-01T11:00:00Z");out body;`,
+		},
+		{
+			"user",
+			NewQueryBuilder().Way().User("Mapper Joe").OutputMeta(),
+			`[out:json]way(user:"Mapper Joe");out meta;`,
+		},
+		{
+			"user escaping",
+			NewQueryBuilder().Node().User(`say "hi" \o/`),
+			`[out:json]node(user:"say \"hi\" \\o/");out body;`,
+		},
+		{
+			"tag escaping",
+			NewQueryBuilder().Node().Tag("name", `He said "hi"`).TagNot(`a\b`, "x").TagRegex("ref", `^A\d`),
+			`[out:json]node["name"="He said \"hi\""]["a\\b"!="x"]["ref"~"^A\\d"];out body;`,
+		},
+		{
+			"uid with tag and bbox",
+			NewQueryBuilder().Node().Tag("amenity", "cafe").UID(12345).BBox(1, 2, 3, 4),
+			`[out:json]node["amenity"="cafe"](uid:12345)(1,2,3,4);out body;`,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := tc.builder.Build(); got != tc.expected {
+				t.Errorf("expected:\n%s\ngot:\n%s", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestBoundingBoxBoxRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	bbox := BoundingBox{South: 52.5, West: 13.3, North: 52.6, East: 13.5}
+
+	box := bbox.ToBox()
+	if box.Min != (Point{52.5, 13.3}) || box.Max != (Point{52.6, 13.5}) {
+		t.Errorf("unexpected box: %+v", box)
+	}
+
+	if got := box.ToBoundingBox(); got != bbox {
+		t.Errorf("round trip: expected %+v, got %+v", bbox, got)
+	}
+}
+
+func TestBoundingBoxIsValid(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		bbox     BoundingBox
+		expected bool
+	}{
+		{"valid", BoundingBox{South: 52.5, West: 13.3, North: 52.6, East: 13.5}, true},
+		{"degenerate", BoundingBox{South: 1, West: 1, North: 1, East: 1}, true},
+		{"inverted latitude", BoundingBox{South: 52.6, West: 13.3, North: 52.5, East: 13.5}, false},
+		{"inverted longitude", BoundingBox{South: 52.5, West: 13.5, North: 52.6, East: 13.3}, false},
+		{"latitude out of range", BoundingBox{South: -91, West: 0, North: 10, East: 10}, false},
+		{"longitude out of range", BoundingBox{South: 0, West: 0, North: 10, East: 181}, false},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := tc.bbox.IsValid(); got != tc.expected {
+				t.Errorf("IsValid() = %v, want %v", got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestBuilderValidate(t *testing.T) {
+	t.Parallel()
+
+	err := NewQueryBuilder().Node().BBox(52.5, 13.3, 52.6, 13.5).Validate()
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	err = NewQueryBuilder().Node().Validate()
+	if err != nil {
+		t.Errorf("unexpected error without bbox: %v", err)
+	}
+
+	err = NewQueryBuilder().Node().BBox(52.6, 13.3, 52.5, 13.5).Validate()
+	if !errors.Is(err, ErrInvalidCoordinates) {
+		t.Errorf("expected ErrInvalidCoordinates, got %v", err)
+	}
+}
+
+func TestBuilderRaw(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		builder  *QueryBuilder
+		expected string
+	}{
+		{
+			"raw only",
+			NewQueryBuilder().Raw("node({{bbox}})"),
+			"[out:json]node({{bbox}});out body;",
+		},
+		{
+			"trailing semicolon kept single",
+			NewQueryBuilder().Raw(" way(123); ").OutputGeom(),
+			"[out:json]way(123);out geom;",
+		},
+		{
+			"raw joins element union",
+			NewQueryBuilder().Node().Tag("amenity", "cafe").Raw("way(1)"),
+			`[out:json](node["amenity"="cafe"]; way(1););out body;`,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := tc.builder.Build(); got != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestBuilderIsIn(t *testing.T) {
+	t.Parallel()
+
+	query := NewQueryBuilder().IsIn(52.5, 13.4).Output("tags").Build()
+
+	expected := "[out:json]is_in(52.5,13.4);out tags;"
+	if query != expected {
+		t.Errorf("expected %q, got %q", expected, query)
+	}
+
+	query = NewQueryBuilder().Precision(3).IsIn(52.123456789, 13.987654321).Build()
+
+	if !strings.Contains(query, "is_in(52.123,13.988);") {
+		t.Errorf("expected builder precision in is_in, got %q", query)
+	}
+}
+
+func TestBuilderFormat(t *testing.T) {
+	t.Parallel()
+
+	query := NewQueryBuilder().Timeout(25).Format("xml").Node().Build()
+	if !strings.HasPrefix(query, "[out:xml][timeout:25]") {
+		t.Errorf("expected format replaced in place, got %s", query)
+	}
+
+	qb := &QueryBuilder{outputs: []string{"out"}}
+	if query := qb.Format("csv(name)").Node().Build(); !strings.HasPrefix(query, "[out:csv(name)]node") {
+		t.Errorf("expected format setting added, got %s", query)
+	}
+}
+
+func TestBuilderBBoxBox(t *testing.T) {
+	t.Parallel()
+
+	box := Box{Min: Point{Lat: 52.5, Lon: 13.3}, Max: Point{Lat: 52.6, Lon: 13.5}}
+
+	fromBox := NewQueryBuilder().Node().BBoxBox(box).Build()
+	fromFloats := NewQueryBuilder().Node().BBox(52.5, 13.3, 52.6, 13.5).Build()
+
+	if fromBox != fromFloats {
+		t.Errorf("expected %q, got %q", fromFloats, fromBox)
+	}
+}
+
+func TestBuilderStatementTree(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		builder  *QueryBuilder
+		expected string
+	}{
+		{
+			"elements, raw and recurse",
+			NewQueryBuilder().Node().Way().Tag("amenity", "cafe").Raw("relation(1)").Recurse(">"),
+			`[out:json]((node["amenity"="cafe"]; way["amenity"="cafe"]; relation(1);); >;);out body;`,
+		},
+		{
+			"single element recurse",
+			NewQueryBuilder().Way().Tag("highway", "primary").Recurse(">").Recurse("<"),
+			`[out:json](way["highway"="primary"]; >; <;);out body;`,
+		},
+		{
+			"multi-statement raw stays grouped",
+			NewQueryBuilder().Node().Raw("way(1); >").OutputGeom(),
+			`[out:json](node; (way(1); >;););out geom;`,
+		},
+		{
+			"named set",
+			NewQueryBuilder().Node().Way().Tag("amenity", "cafe").As(".cafes"),
+			`[out:json](node["amenity"="cafe"]; way["amenity"="cafe"];)->.cafes;.cafes out body;`,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			query := tc.builder.Build()
+			if query != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, query)
+			}
+
+			// Balanced output formats without errors
+			if _, err := FormatQuery(query); err != nil {
+				t.Errorf("malformed query %q: %v", query, err)
+			}
+		})
+	}
+}
+
+func TestBuilderWithNodes(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		builder  *QueryBuilder
+		expected string
+	}{
+		{
+			"way query",
+			NewQueryBuilder().Way().Tag("highway", "primary").WithNodes(),
+			`[out:json](way["highway"="primary"]; >;);out body;`,
+		},
+		{
+			"relation and node query",
+			NewQueryBuilder().Node().Relation().Tag("type", "route").WithNodes(),
+			`[out:json]((node["type"="route"]; relation["type"="route"];); >;);out body;`,
+		},
+		{
+			"explicit recurse not duplicated",
+			NewQueryBuilder().Way().Recurse(">").WithNodes(),
+			`[out:json](way; >;);out body;`,
+		},
+		{
+			"node only",
+			NewQueryBuilder().Node().Tag("amenity", "cafe").WithNodes(),
+			`[out:json]node["amenity"="cafe"];out body;`,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			if query := tc.builder.Build(); query != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, query)
+			}
+		})
+	}
+}
+
+func TestBuilderPrecision(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		builder  *QueryBuilder
+		expected string
+	}{
+		{
+			"default keeps centimetres",
+			NewQueryBuilder().Node().BBox(52.123456789, 13.4, -0.00000001, 13.5),
+			"(52.1234568,13.4,0,13.5)",
+		},
+		{
+			"custom precision",
+			NewQueryBuilder().Node().BBox(52.123456789, 13.4, 52.6, 13.5).Precision(3),
+			"(52.123,13.4,52.6,13.5)",
+		},
+		{
+			"non-positive restores default",
+			NewQueryBuilder().Node().BBox(52.123456789, 13.4, 52.6, 13.5).Precision(0),
+			"(52.1234568,13.4,52.6,13.5)",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			if query := tc.builder.Build(); !strings.Contains(query, tc.expected) {
+				t.Errorf("expected bbox %s in %s", tc.expected, query)
+			}
+		})
+	}
+}
+
+func TestBuilderAddOutput(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		builder  *QueryBuilder
+		expected string
+	}{
+		{
+			"ids then skel",
+			NewQueryBuilder().Way().Tag("highway", "primary").Output("ids").AddOutput("skel"),
+			`[out:json]way["highway"="primary"];out ids;out skel;`,
+		},
+		{
+			"primary output replaced after adding",
+			NewQueryBuilder().Node().AddOutput("skel qt").OutputGeom(),
+			`[out:json]node;out geom;out skel qt;`,
+		},
+		{
+			"named set applies to every output",
+			NewQueryBuilder().Node().As("result").Output("ids").AddOutput("skel"),
+			`[out:json]node->.result;.result out ids;.result out skel;`,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			if query := tc.builder.Build(); query != tc.expected {
+				t.Errorf("expected:\n%s\ngot:\n%s", tc.expected, query)
+			}
+		})
+	}
+}
+
+func TestBuilderCountByValue(t *testing.T) {
+	t.Parallel()
+
+	query := NewQueryBuilder().Node().Way().Tag("amenity", "restaurant").BBox(1, 2, 3, 4).Timeout(25).
+		CountByValue("cuisine", "pizza", "sushi")
+
+	expected := `[out:json][timeout:25]` +
+		`(node["amenity"="restaurant"]["cuisine"="pizza"](1,2,3,4); way["amenity"="restaurant"]["cuisine"="pizza"](1,2,3,4););out count;` +
+		`(node["amenity"="restaurant"]["cuisine"="sushi"](1,2,3,4); way["amenity"="restaurant"]["cuisine"="sushi"](1,2,3,4););out count;`
+	if query != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, query)
+	}
+
+	// The builder itself is left untouched
+	qb := NewQueryBuilder().Node().Tag("amenity", "cafe")
+	qb.CountByValue("cuisine", "coffee")
+
+	if built := qb.Build(); built != `[out:json]node["amenity"="cafe"];out body;` {
+		t.Errorf("builder was modified: %s", built)
+	}
+}
+
+func TestFetchRelation(t *testing.T) {
+	t.Parallel()
+
+	expected := `[out:json](relation(62422); >>;);out geom;`
+	if query := FetchRelation(62422).Build(); query != expected {
+		t.Errorf("expected %s, got %s", expected, query)
+	}
+}
+
+func TestBuilderIDs(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		builder  *QueryBuilder
+		expected string
+	}{
+		{
+			name:     "single node",
+			builder:  NewQueryBuilder().NodeIDs(1),
+			expected: "[out:json]node(id:1);out body;",
+		},
+		{
+			name:     "multiple ways across calls",
+			builder:  NewQueryBuilder().WayIDs(10, 11).WayIDs(12),
+			expected: "[out:json]way(id:10,11,12);out body;",
+		},
+		{
+			name:     "union in fixed type order",
+			builder:  NewQueryBuilder().RelationIDs(7).NodeIDs(1, 2).WayIDs(10),
+			expected: "[out:json](node(id:1,2); way(id:10); relation(id:7););out body;",
+		},
+		{
+			name:     "with element selection and way nodes",
+			builder:  NewQueryBuilder().Node().Tag("amenity", "cafe").WayIDs(10).WithNodes(),
+			expected: `[out:json]((node["amenity"="cafe"]; way(id:10);); >;);out body;`,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := tc.builder.Build(); got != tc.expected {
+				t.Errorf("expected %s, got %s", tc.expected, got)
+			}
+
+			if err := tc.builder.Validate(); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+
+	err := NewQueryBuilder().NodeIDs(1).WayIDs().Validate()
+	if !errors.Is(err, ErrNoIDs) {
+		t.Errorf("expected ErrNoIDs, got %v", err)
+	}
+}
+
+func TestCorridorQuery(t *testing.T) {
+	t.Parallel()
+
+	query := CorridorQuery(`way["ref"="A10"];`, 500, ` node["amenity"="fuel"]`)
+
+	expected := `[out:json]way["ref"="A10"]->.route;node["amenity"="fuel"](around.route:500);out center;`
+	if query != expected {
+		t.Errorf("expected %s, got %s", expected, query)
+	}
+
+	statements, err := splitStatements(query)
+	if err != nil || len(statements) != 3 {
+		t.Fatalf("expected three statements, got %q (%v)", statements, err)
+	}
+
+	if got := CorridorQuery(`way["highway"="motorway"]`, 12.5, `nwr["tourism"]`); !strings.Contains(got, "(around.route:12.5)") {
+		t.Errorf("expected fractional radius, got %s", got)
+	}
+}
@@ -26,6 +26,8 @@ func DefaultCacheConfig() CacheConfig {
 
 // cacheEntry holds cached result with expiration.
 type cacheEntry struct {
+	endpoint  string // original endpoint, kept for selective invalidation
+	query     string // original query, kept for selective invalidation
 	result    Result
 	expiresAt time.Time
 }
@@ -35,6 +37,10 @@ type cache struct {
 	mu      sync.RWMutex
 	entries map[string]*cacheEntry
 	config  CacheConfig
+
+	cleanupMu     sync.Mutex
+	cleanupCancel context.CancelFunc // stops the running cleanup goroutine, nil if none
+	cleanupPaused bool
 }
 
 // newCache creates new cache instance.
@@ -45,22 +51,29 @@ func newCache(config CacheConfig) *cache {
 	}
 }
 
-// generateKey creates cache key from query and endpoint.
-func (c *cache) generateKey(endpoint, query string) string {
+// generateKey creates cache key from endpoint and query. The optional extra
+// discriminator separates entries of clients that get different data for the
+// same query (e.g. different credentials); empty keeps the endpoint+query key.
+func (c *cache) generateKey(endpoint, query, extra string) string {
 	h := sha256.New()
 	h.Write([]byte(endpoint))
 	h.Write([]byte(query))
 
+	if extra != "" {
+		h.Write([]byte{0})
+		h.Write([]byte(extra))
+	}
+
 	return hex.EncodeToString(h.Sum(nil))
 }
 
 // get retrieves cached result if exists and not expired.
-func (c *cache) get(endpoint, query string) (Result, bool) {
+func (c *cache) get(endpoint, query, extra string) (Result, bool) {
 	if !c.config.Enabled {
 		return Result{}, false
 	}
 
-	key := c.generateKey(endpoint, query)
+	key := c.generateKey(endpoint, query, extra)
 
 	c.mu.RLock()
 	entry, exists := c.entries[key]
@@ -84,12 +97,12 @@ func (c *cache) get(endpoint, query string) (Result, bool) {
 }
 
 // set stores result in cache with TTL.
-func (c *cache) set(endpoint, query string, result Result) {
+func (c *cache) set(endpoint, query, extra string, result Result) {
 	if !c.config.Enabled {
 		return
 	}
 
-	key := c.generateKey(endpoint, query)
+	key := c.generateKey(endpoint, query, extra)
 
 	c.mu.Lock()
 	defer c.mu.Unlock()
@@ -113,6 +126,8 @@ func (c *cache) set(endpoint, query string, result Result) {
 	}
 
 	c.entries[key] = &cacheEntry{
+		endpoint:  endpoint,
+		query:     query,
 		result:    result,
 		expiresAt: time.Now().Add(c.config.TTL),
 	}
@@ -126,6 +141,25 @@ func (c *cache) clear() {
 	c.entries = make(map[string]*cacheEntry)
 }
 
+// invalidate removes entries whose endpoint and query match the predicate.
+// It returns the number of removed entries.
+func (c *cache) invalidate(pred func(endpoint, query string) bool) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	removed := 0
+
+	for key, entry := range c.entries {
+		if pred(entry.endpoint, entry.query) {
+			delete(c.entries, key)
+
+			removed++
+		}
+	}
+
+	return removed
+}
+
 // size returns current number of cached entries.
 func (c *cache) size() int {
 	c.mu.RLock()
@@ -151,12 +185,22 @@ func (c *cache) cleanup() {
 	}
 }
 
-// startCleanupRoutine starts background goroutine for periodic cleanup.
+// startCleanupRoutine starts background goroutine for periodic cleanup,
+// replacing a routine started earlier. It runs until ctx is done or cleanup
+// is paused, and does nothing while paused or if the cache is disabled.
 func (c *cache) startCleanupRoutine(ctx context.Context) {
-	if !c.config.Enabled {
+	c.cleanupMu.Lock()
+	defer c.cleanupMu.Unlock()
+
+	if c.cleanupPaused || !c.config.Enabled {
 		return
 	}
 
+	if c.cleanupCancel != nil {
+		c.cleanupCancel()
+	}
+
+	ctx, c.cleanupCancel = context.WithCancel(ctx)
 	ticker := time.NewTicker(c.config.TTL / 2) // cleanup at half-TTL intervals
 
 	go func() {
@@ -172,3 +216,25 @@ func (c *cache) startCleanupRoutine(ctx context.Context) {
 		}
 	}()
 }
+
+// pauseCleanup stops the cleanup goroutine until resumeCleanup is called.
+func (c *cache) pauseCleanup() {
+	c.cleanupMu.Lock()
+	defer c.cleanupMu.Unlock()
+
+	c.cleanupPaused = true
+
+	if c.cleanupCancel != nil {
+		c.cleanupCancel()
+		c.cleanupCancel = nil
+	}
+}
+
+// resumeCleanup restarts the cleanup goroutine under ctx after pauseCleanup.
+func (c *cache) resumeCleanup(ctx context.Context) {
+	c.cleanupMu.Lock()
+	c.cleanupPaused = false
+	c.cleanupMu.Unlock()
+
+	c.startCleanupRoutine(ctx)
+}
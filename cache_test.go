@@ -5,6 +5,7 @@ import (
 	"context"
 	"io"
 	"net/http"
+	"strings"
 	"testing"
 	"time"
 )
@@ -33,10 +34,10 @@ func TestCacheDisabledByDefault(t *testing.T) {
 	cache := newCache(DefaultCacheConfig())
 
 	// Set should be no-op when disabled
-	cache.set("endpoint", "query", Result{Count: 42})
+	cache.set("endpoint", "query", "", Result{Count: 42})
 
 	// Get should return miss
-	_, hit := cache.get("endpoint", "query")
+	_, hit := cache.get("endpoint", "query", "")
 	if hit {
 		t.Error("cache hit when cache disabled")
 	}
@@ -55,15 +56,15 @@ func TestCacheSetAndGet(t *testing.T) {
 	result := Result{Count: 42, Timestamp: time.Now()}
 
 	// Cache miss initially
-	_, hit := cache.get("endpoint", "query1")
+	_, hit := cache.get("endpoint", "query1", "")
 	if hit {
 		t.Error("unexpected cache hit")
 	}
 
 	// Set and retrieve
-	cache.set("endpoint", "query1", result)
+	cache.set("endpoint", "query1", "", result)
 
-	retrieved, hit := cache.get("endpoint", "query1")
+	retrieved, hit := cache.get("endpoint", "query1", "")
 	if !hit {
 		t.Fatal("expected cache hit")
 	}
@@ -84,10 +85,10 @@ func TestCacheExpiration(t *testing.T) {
 	cache := newCache(config)
 
 	result := Result{Count: 42}
-	cache.set("endpoint", "query1", result)
+	cache.set("endpoint", "query1", "", result)
 
 	// Should be cached immediately
-	_, hit := cache.get("endpoint", "query1")
+	_, hit := cache.get("endpoint", "query1", "")
 	if !hit {
 		t.Fatal("expected cache hit")
 	}
@@ -96,7 +97,7 @@ func TestCacheExpiration(t *testing.T) {
 	time.Sleep(150 * time.Millisecond)
 
 	// Should be expired
-	_, hit = cache.get("endpoint", "query1")
+	_, hit = cache.get("endpoint", "query1", "")
 	if hit {
 		t.Error("expected cache miss after expiration")
 	}
@@ -109,11 +110,11 @@ func TestCacheKeyGeneration(t *testing.T) {
 	cache := newCache(config)
 
 	// Different queries should have different cache entries
-	cache.set("endpoint", "query1", Result{Count: 1})
-	cache.set("endpoint", "query2", Result{Count: 2})
+	cache.set("endpoint", "query1", "", Result{Count: 1})
+	cache.set("endpoint", "query2", "", Result{Count: 2})
 
-	result1, hit1 := cache.get("endpoint", "query1")
-	result2, hit2 := cache.get("endpoint", "query2")
+	result1, hit1 := cache.get("endpoint", "query1", "")
+	result2, hit2 := cache.get("endpoint", "query2", "")
 
 	if !hit1 || !hit2 {
 		t.Fatal("expected cache hits")
@@ -124,11 +125,11 @@ func TestCacheKeyGeneration(t *testing.T) {
 	}
 
 	// Different endpoints should have different cache entries
-	cache.set("endpoint1", "query", Result{Count: 10})
-	cache.set("endpoint2", "query", Result{Count: 20})
+	cache.set("endpoint1", "query", "", Result{Count: 10})
+	cache.set("endpoint2", "query", "", Result{Count: 20})
 
-	result1, _ = cache.get("endpoint1", "query")
-	result2, _ = cache.get("endpoint2", "query")
+	result1, _ = cache.get("endpoint1", "query", "")
+	result2, _ = cache.get("endpoint2", "query", "")
 
 	if result1.Count != 10 || result2.Count != 20 {
 		t.Error("endpoint differentiation failed")
@@ -146,13 +147,13 @@ func TestCacheMaxEntries(t *testing.T) {
 	cache := newCache(config)
 
 	// Fill cache beyond capacity
-	cache.set("e", "q1", Result{Count: 1})
+	cache.set("e", "q1", "", Result{Count: 1})
 	time.Sleep(time.Millisecond) // Ensure different timestamps
-	cache.set("e", "q2", Result{Count: 2})
+	cache.set("e", "q2", "", Result{Count: 2})
 	time.Sleep(time.Millisecond)
-	cache.set("e", "q3", Result{Count: 3})
+	cache.set("e", "q3", "", Result{Count: 3})
 	time.Sleep(time.Millisecond)
-	cache.set("e", "q4", Result{Count: 4}) // Should evict oldest (q1)
+	cache.set("e", "q4", "", Result{Count: 4}) // Should evict oldest (q1)
 
 	// Check size
 	if size := cache.size(); size != 3 {
@@ -160,13 +161,13 @@ func TestCacheMaxEntries(t *testing.T) {
 	}
 
 	// q1 should be evicted
-	_, hit := cache.get("e", "q1")
+	_, hit := cache.get("e", "q1", "")
 	if hit {
 		t.Error("q1 should have been evicted")
 	}
 
 	// q2-q4 should exist
-	_, hit = cache.get("e", "q4")
+	_, hit = cache.get("e", "q4", "")
 	if !hit {
 		t.Error("q4 should exist")
 	}
@@ -178,8 +179,8 @@ func TestCacheClear(t *testing.T) {
 	config := CacheConfig{Enabled: true, TTL: time.Hour, MaxEntries: 100}
 	cache := newCache(config)
 
-	cache.set("e", "q1", Result{Count: 1})
-	cache.set("e", "q2", Result{Count: 2})
+	cache.set("e", "q1", "", Result{Count: 1})
+	cache.set("e", "q2", "", Result{Count: 2})
 
 	if size := cache.size(); size != 2 {
 		t.Errorf("expected size=2, got %d", size)
@@ -191,12 +192,52 @@ func TestCacheClear(t *testing.T) {
 		t.Errorf("expected size=0 after clear, got %d", size)
 	}
 
-	_, hit := cache.get("e", "q1")
+	_, hit := cache.get("e", "q1", "")
 	if hit {
 		t.Error("cache should be empty after clear")
 	}
 }
 
+func TestCacheInvalidate(t *testing.T) {
+	t.Parallel()
+
+	config := CacheConfig{Enabled: true, TTL: time.Hour, MaxEntries: 100}
+	cache := newCache(config)
+
+	cache.set("e1", "node(area:3600062422);out;", "", Result{Count: 1})
+	cache.set("e1", "way(area:3600062422);out;", "", Result{Count: 2})
+	cache.set("e1", "node(area:3600051477);out;", "", Result{Count: 3})
+	cache.set("e2", "node(1);out;", "", Result{Count: 4})
+
+	removed := cache.invalidate(func(_, query string) bool {
+		return strings.Contains(query, "3600062422")
+	})
+	if removed != 2 {
+		t.Errorf("expected 2 removed entries, got %d", removed)
+	}
+
+	if size := cache.size(); size != 2 {
+		t.Errorf("expected size=2 after invalidate, got %d", size)
+	}
+
+	if _, hit := cache.get("e1", "node(area:3600062422);out;", ""); hit {
+		t.Error("matching entry should have been invalidated")
+	}
+
+	if _, hit := cache.get("e1", "node(area:3600051477);out;", ""); !hit {
+		t.Error("non-matching entry should remain")
+	}
+
+	// Invalidate by endpoint
+	cache.invalidate(func(endpoint, _ string) bool {
+		return endpoint == "e2"
+	})
+
+	if _, hit := cache.get("e2", "node(1);out;", ""); hit {
+		t.Error("entry for e2 should have been invalidated")
+	}
+}
+
 func TestCacheCleanup(t *testing.T) {
 	t.Parallel()
 
@@ -207,8 +248,8 @@ func TestCacheCleanup(t *testing.T) {
 	}
 	cache := newCache(config)
 
-	cache.set("e", "q1", Result{Count: 1})
-	cache.set("e", "q2", Result{Count: 2})
+	cache.set("e", "q1", "", Result{Count: 1})
+	cache.set("e", "q2", "", Result{Count: 2})
 
 	if size := cache.size(); size != 2 {
 		t.Errorf("expected size=2, got %d", size)
@@ -240,8 +281,8 @@ func TestCacheCleanupRoutine(t *testing.T) {
 
 	cache.startCleanupRoutine(ctx)
 
-	cache.set("e", "q1", Result{Count: 1})
-	cache.set("e", "q2", Result{Count: 2})
+	cache.set("e", "q1", "", Result{Count: 1})
+	cache.set("e", "q2", "", Result{Count: 2})
 
 	// Wait for automatic cleanup
 	time.Sleep(150 * time.Millisecond)
@@ -251,6 +292,47 @@ func TestCacheCleanupRoutine(t *testing.T) {
 	}
 }
 
+func TestClientPauseResumeCacheCleanup(t *testing.T) {
+	t.Parallel()
+
+	client := NewClient(
+		WithHTTPClient(&mockRecordingHTTPClient{}),
+		WithCache(CacheConfig{Enabled: true, TTL: 20 * time.Millisecond, MaxEntries: 100}),
+	)
+	defer client.Close()
+
+	client.PauseCacheCleanup()
+	client.cache.set(client.apiEndpoint, "q1", "", Result{Count: 1})
+
+	// Expired entries stay in memory while paused
+	time.Sleep(80 * time.Millisecond)
+
+	if size := client.CacheSize(); size != 1 {
+		t.Fatalf("expected paused cleanup to keep the entry, got size=%d", size)
+	}
+
+	// Queries keep working while paused
+	_, err := client.QueryContext(context.Background(), "node(1);out;")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Enabling the cache again must not restart cleanup behind our back
+	client.SetCacheConfig(CacheConfig{Enabled: true, TTL: 20 * time.Millisecond, MaxEntries: 100})
+	time.Sleep(80 * time.Millisecond)
+
+	if size := client.CacheSize(); size != 2 {
+		t.Fatalf("expected cleanup to stay paused, got size=%d", size)
+	}
+
+	client.ResumeCacheCleanup()
+	time.Sleep(80 * time.Millisecond)
+
+	if size := client.CacheSize(); size != 0 {
+		t.Errorf("expected cleanup after resume, got size=%d", size)
+	}
+}
+
 func TestClientCacheIntegration(t *testing.T) {
 	t.Parallel()
 
@@ -309,8 +391,8 @@ func TestClientClearCache(t *testing.T) {
 	client.SetCacheConfig(CacheConfig{Enabled: true, TTL: time.Hour, MaxEntries: 100})
 
 	// Populate cache
-	client.cache.set(client.apiEndpoint, "query1", Result{Count: 1})
-	client.cache.set(client.apiEndpoint, "query2", Result{Count: 2})
+	client.cache.set(client.apiEndpoint, "query1", "", Result{Count: 1})
+	client.cache.set(client.apiEndpoint, "query2", "", Result{Count: 2})
 
 	if size := client.CacheSize(); size != 2 {
 		t.Errorf("expected size=2, got %d", size)
@@ -323,6 +405,31 @@ func TestClientClearCache(t *testing.T) {
 	}
 }
 
+func TestClientInvalidateCache(t *testing.T) {
+	t.Parallel()
+
+	client := New()
+	client.SetCacheConfig(CacheConfig{Enabled: true, TTL: time.Hour, MaxEntries: 100})
+
+	client.cache.set(client.apiEndpoint, `node["amenity"="cafe"](52.5,13.4,52.6,13.5);out;`, "", Result{Count: 1})
+	client.cache.set(client.apiEndpoint, `node["amenity"="bar"](48.1,11.5,48.2,11.6);out;`, "", Result{Count: 2})
+
+	client.InvalidateCache(func(_, query string) bool {
+		return strings.Contains(query, "52.5,13.4")
+	})
+
+	if size := client.CacheSize(); size != 1 {
+		t.Errorf("expected size=1 after invalidate, got %d", size)
+	}
+
+	// nil predicate is a no-op
+	client.InvalidateCache(nil)
+
+	if size := client.CacheSize(); size != 1 {
+		t.Errorf("expected size=1 after nil invalidate, got %d", size)
+	}
+}
+
 func TestCacheDisabledSkipsStorage(t *testing.T) {
 	t.Parallel()
 
@@ -334,7 +441,7 @@ func TestCacheDisabledSkipsStorage(t *testing.T) {
 	cache := newCache(config)
 
 	// Attempt to set
-	cache.set("endpoint", "query", Result{Count: 42})
+	cache.set("endpoint", "query", "", Result{Count: 42})
 
 	// Size should be 0 since cache is disabled
 	if size := cache.size(); size != 0 {
@@ -354,7 +461,7 @@ func TestCacheWithZeroMaxEntries(t *testing.T) {
 
 	// Add many entries
 	for i := 0; i < 100; i++ {
-		cache.set("e", string(rune(i)), Result{Count: i})
+		cache.set("e", string(rune(i)), "", Result{Count: i})
 	}
 
 	// All should be stored (no eviction)
@@ -362,3 +469,55 @@ func TestCacheWithZeroMaxEntries(t *testing.T) {
 		t.Errorf("expected size=100, got %d", size)
 	}
 }
+
+func TestCacheKeyExtra(t *testing.T) {
+	t.Parallel()
+
+	cache := newCache(CacheConfig{Enabled: true, TTL: time.Minute, MaxEntries: 100})
+
+	cache.set("e", "q", "", Result{Count: 1})
+	cache.set("e", "q", "tenant-a", Result{Count: 2})
+
+	if size := cache.size(); size != 2 {
+		t.Fatalf("expected 2 separate entries, got %d", size)
+	}
+
+	if result, hit := cache.get("e", "q", "tenant-a"); !hit || result.Count != 2 {
+		t.Errorf("expected tenant-a entry, got %+v (hit=%v)", result, hit)
+	}
+
+	if _, hit := cache.get("e", "q", "tenant-b"); hit {
+		t.Error("unexpected hit for different discriminator")
+	}
+}
+
+func TestClientCacheKeyExtra(t *testing.T) {
+	t.Parallel()
+
+	mock := &mockRecordingHTTPClient{}
+
+	clientA := NewWithSettings(apiEndpoint, 1, mock)
+	clientA.SetCacheConfig(CacheConfig{Enabled: true, TTL: time.Minute, MaxEntries: 100})
+
+	// A copy shares the cache but sends different credentials
+	clientB := clientA
+	clientA.CacheKeyExtra = func() string { return "token-a" }
+	clientB.CacheKeyExtra = func() string { return "token-b" }
+
+	query := "[out:json];node(1);out;"
+
+	for _, client := range []*Client{&clientA, &clientB, &clientA, &clientB} {
+		_, err := client.QueryContext(context.Background(), query)
+		if err != nil {
+			t.Fatalf("query failed: %v", err)
+		}
+	}
+
+	if n := len(mock.recordedQueries()); n != 2 {
+		t.Errorf("expected one request per discriminator, got %d", n)
+	}
+
+	if size := clientA.CacheSize(); size != 2 {
+		t.Errorf("expected 2 cache entries, got %d", size)
+	}
+}
@@ -1,5 +1,7 @@
 package overpass
 
+import "fmt"
+
 // Category represents high-level OSM feature category.
 type Category string
 
@@ -195,3 +197,58 @@ func (m *Meta) IsHealthcare() bool {
 
 	return false
 }
+
+// GroupByCategory buckets every element of the result by Meta.GetCategory.
+// Elements without a recognized tag are grouped under CategoryUnknown.
+// Within a category, elements keep the order of Result.Elements.
+func (r *Result) GroupByCategory() map[Category][]Element {
+	groups := map[Category][]Element{}
+
+	for _, element := range r.Elements() {
+		category := element.Meta().GetCategory()
+		groups[category] = append(groups[category], element)
+	}
+
+	return groups
+}
+
+// Color is an opaque RGB color for visualizing elements.
+type Color struct {
+	R, G, B uint8
+}
+
+// Hex returns the color in #rrggbb notation.
+func (c Color) Hex() string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+var categoryColors = map[Category]Color{ //nolint:gochecknoglobals // fixed legend palette
+	CategoryTransportation: {0x61, 0x61, 0x61},
+	CategoryAmenity:        {0xe5, 0x39, 0x35},
+	CategoryNatural:        {0x43, 0xa0, 0x47},
+	CategoryWater:          {0x1e, 0x88, 0xe5},
+	CategoryBuilding:       {0x8d, 0x6e, 0x63},
+	CategoryLeisure:        {0x00, 0xac, 0xc1},
+	CategoryLanduse:        {0xc0, 0xca, 0x33},
+	CategoryBoundary:       {0x8e, 0x24, 0xaa},
+	CategoryPlace:          {0x37, 0x47, 0x4f},
+	CategoryShop:           {0xfb, 0x8c, 0x00},
+	CategoryTourism:        {0xd8, 0x1b, 0x60},
+	CategoryUnknown:        {0xbd, 0xbd, 0xbd},
+}
+
+// Color returns a fixed color for the category, suitable for map legends
+// without writing MapCSS. Every category has a distinct color; unrecognized
+// values get the color of CategoryUnknown.
+func (c Category) Color() Color {
+	if color, ok := categoryColors[c]; ok {
+		return color
+	}
+
+	return categoryColors[CategoryUnknown]
+}
+
+// DisplayColor returns the color of the element's category.
+func (m *Meta) DisplayColor() Color {
+	return m.GetCategory().Color()
+}
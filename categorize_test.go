@@ -666,3 +666,83 @@ func TestRealWorldExample(t *testing.T) {
 		t.Errorf("expected Main Street, got %s", road.GetName())
 	}
 }
+
+func TestGroupByCategory(t *testing.T) {
+	t.Parallel()
+
+	result := Result{
+		Nodes: map[int64]*Node{
+			1: {Meta: Meta{ID: 1, Tags: map[string]string{"amenity": "restaurant"}}},
+			2: {Meta: Meta{ID: 2}},
+		},
+		Ways: map[int64]*Way{
+			10: {Meta: Meta{ID: 10, Tags: map[string]string{"highway": "primary"}}},
+		},
+		Relations: map[int64]*Relation{
+			20: {Meta: Meta{ID: 20, Tags: map[string]string{"type": "multipolygon"}}},
+		},
+	}
+
+	groups := result.GroupByCategory()
+
+	if len(groups) != 3 {
+		t.Fatalf("expected 3 categories, got %d: %v", len(groups), groups)
+	}
+
+	transport := groups[CategoryTransportation]
+	if len(transport) != 1 || transport[0].Way == nil || transport[0].Way.ID != 10 {
+		t.Errorf("expected highway way 10 in transportation, got %+v", transport)
+	}
+
+	amenities := groups[CategoryAmenity]
+	if len(amenities) != 1 || amenities[0].Node == nil || amenities[0].Node.ID != 1 {
+		t.Errorf("expected restaurant node 1 in amenity, got %+v", amenities)
+	}
+
+	unknown := groups[CategoryUnknown]
+	if len(unknown) != 2 || unknown[0].Type != ElementTypeNode || unknown[1].Type != ElementTypeRelation {
+		t.Errorf("expected untagged node and relation in unknown, got %+v", unknown)
+	}
+}
+
+func TestCategoryColor(t *testing.T) {
+	t.Parallel()
+
+	categories := []Category{
+		CategoryTransportation, CategoryAmenity, CategoryNatural, CategoryWater,
+		CategoryBuilding, CategoryLeisure, CategoryLanduse, CategoryBoundary,
+		CategoryPlace, CategoryShop, CategoryTourism, CategoryUnknown,
+	}
+
+	seen := map[Color]Category{}
+
+	for _, category := range categories {
+		color := category.Color()
+		if other, dup := seen[color]; dup {
+			t.Errorf("%s and %s share color %s", category, other, color.Hex())
+		}
+
+		seen[color] = category
+
+		if category.Color() != color {
+			t.Errorf("%s: color is not deterministic", category)
+		}
+	}
+
+	if Category("made-up").Color() != CategoryUnknown.Color() {
+		t.Error("expected unknown categories to use the unknown color")
+	}
+
+	if hex := CategoryWater.Color().Hex(); hex != "#1e88e5" {
+		t.Errorf("expected #1e88e5, got %s", hex)
+	}
+
+	meta := Meta{Tags: map[string]string{"amenity": "cafe"}}
+	if meta.DisplayColor() != CategoryAmenity.Color() {
+		t.Errorf("expected amenity color, got %s", meta.DisplayColor().Hex())
+	}
+
+	if (&Meta{}).DisplayColor() != CategoryUnknown.Color() {
+		t.Error("expected untagged elements to use the unknown color")
+	}
+}
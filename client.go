@@ -2,7 +2,11 @@ package overpass
 
 import (
 	"context"
+	"fmt"
+	"io"
 	"net/http"
+	"strings"
+	"time"
 )
 
 const apiEndpoint = "https://overpass-api.de/api/interpreter"
@@ -14,44 +18,81 @@ type HTTPClient interface {
 
 // A Client manages communication with the Overpass API.
 type Client struct {
+	// DefaultTimeout is applied to queries whose context carries no deadline
+	// (0 = no timeout). This also covers the deprecated Query method.
+	DefaultTimeout time.Duration
+
+	// MaxResponseBytes limits the size of a response body (0 = unlimited).
+	// Larger responses fail with *ResponseTooLargeError, which protects against
+	// misbehaving or untrusted mirrors.
+	MaxResponseBytes int64
+
+	// MaxElements limits the number of elements in a result (0 = unlimited).
+	// Larger results fail with *TooManyElementsError after parsing and are
+	// not cached.
+	MaxElements int
+
+	// MinInterval is the minimum time between the end of one request and the
+	// start of the next (0 = no spacing). Use it to stay polite towards public
+	// endpoints; waiting respects context cancellation.
+	MinInterval time.Duration
+
+	// CacheKeyExtra, if set, returns a discriminator mixed into cache keys.
+	// By default entries are keyed by endpoint and effective query only, so
+	// copies of a client that share the cache but send different credentials
+	// or headers should set distinct discriminators.
+	CacheKeyExtra func() string
+
+	// KeepRawJSON stores the source JSON of every element in Result.Raw,
+	// which helps debugging unexpected or missing fields. Off by default to
+	// avoid the extra memory. XML responses are not recorded.
+	KeepRawJSON bool
+
+	// OutputFormat is the response format requested for builder queries,
+	// "json" (the default if empty) or "xml". QueryWithBuilder sets the
+	// builder's [out:...] accordingly. Responses in either format are decoded
+	// into the same Result.
+	OutputFormat string
+
 	apiEndpoint string
 	httpClient  HTTPClient
-	semaphore   chan struct{}
+	semaphore   *semaphore
+	adaptive    *adaptiveLimiter // nil unless adaptive concurrency is enabled
+	pacer       *pacer
 	retryConfig RetryConfig
 	cache       *cache
 	cacheCtx    context.Context
 	cacheCancel context.CancelFunc
+	prologue    string
+	userAgent   string
+	headers     http.Header
 }
 
 // New returns Client instance with default overpass-api.de endpoint.
 func New() Client {
-	return NewWithSettings(apiEndpoint, 1, http.DefaultClient)
+	return NewClient()
 }
 
-// NewWithSettings returns Client with custom settings.
-func NewWithSettings(
-	apiEndpoint string,
-	maxParallel int,
-	httpClient HTTPClient,
-) Client {
+// NewClient returns a Client configured by the given options. Without options
+// it uses the overpass-api.de endpoint, one parallel request, http.DefaultClient,
+// the default retry configuration and a disabled cache.
+func NewClient(opts ...Option) Client {
 	ctx, cancel := context.WithCancel(context.Background())
 
-	// Use http.DefaultClient if nil is passed
-	if httpClient == nil {
-		httpClient = http.DefaultClient
-	}
-
 	client := Client{
 		apiEndpoint: apiEndpoint,
-		httpClient:  httpClient,
-		semaphore:   make(chan struct{}, maxParallel),
+		httpClient:  http.DefaultClient,
+		semaphore:   newSemaphore(1),
+		pacer:       &pacer{},
+		headers:     http.Header{},
 		retryConfig: DefaultRetryConfig(),
 		cache:       newCache(DefaultCacheConfig()),
 		cacheCtx:    ctx,
 		cacheCancel: cancel,
 	}
-	for i := 0; i < maxParallel; i++ {
-		client.semaphore <- struct{}{}
+
+	for _, opt := range opts {
+		opt(&client)
 	}
 
 	client.cache.startCleanupRoutine(ctx)
@@ -59,6 +100,19 @@ func NewWithSettings(
 	return client
 }
 
+// NewWithSettings returns Client with custom settings.
+func NewWithSettings(
+	apiEndpoint string,
+	maxParallel int,
+	httpClient HTTPClient,
+) Client {
+	return NewClient(
+		WithEndpoint(apiEndpoint),
+		WithMaxParallel(maxParallel),
+		WithHTTPClient(httpClient),
+	)
+}
+
 // NewWithRetry returns Client with custom retry configuration.
 func NewWithRetry(
 	apiEndpoint string,
@@ -66,10 +120,58 @@ func NewWithRetry(
 	httpClient HTTPClient,
 	retryConfig RetryConfig,
 ) Client {
-	c := NewWithSettings(apiEndpoint, maxParallel, httpClient)
-	c.retryConfig = retryConfig
+	return NewClient(
+		WithEndpoint(apiEndpoint),
+		WithMaxParallel(maxParallel),
+		WithHTTPClient(httpClient),
+		WithRetry(retryConfig),
+	)
+}
+
+// SetMaxParallel changes the maximum number of parallel requests at runtime.
+// In-flight requests are not interrupted; when lowering the limit, new requests
+// wait until enough of them have finished. Values below 1 are treated as 1.
+func (c *Client) SetMaxParallel(n int) {
+	c.semaphore.resize(max(n, 1))
+}
+
+// SetQueryPrologue sets a settings header (e.g. "[out:json][timeout:25];")
+// that is prepended to every query not already starting with its own settings.
+// The prologue is part of the effective query, so it is also part of the cache key.
+// An empty prologue disables the feature.
+func (c *Client) SetQueryPrologue(prologue string) {
+	prologue = strings.TrimSpace(prologue)
+	if prologue != "" && !strings.HasSuffix(prologue, ";") {
+		prologue += ";"
+	}
+
+	c.prologue = prologue
+}
+
+// applyPrologue returns the query with the client's prologue prepended if needed.
+func (c *Client) applyPrologue(query string) string {
+	if c.prologue == "" || strings.HasPrefix(strings.TrimSpace(query), "[") {
+		return query
+	}
 
-	return c
+	return c.prologue + query
+}
+
+// SetHeader sets an HTTP header sent with every request, e.g. an Authorization
+// header for an Overpass instance behind an auth proxy. Headers set here take
+// precedence over the client's own Content-Type and User-Agent. An empty value
+// removes the header. Configure headers before issuing requests. Copies of a
+// client keep their own headers.
+func (c *Client) SetHeader(key, value string) {
+	// Copies of the client share the map, so never modify it in place
+	c.headers = c.headers.Clone()
+
+	if value == "" {
+		c.headers.Del(key)
+		return
+	}
+
+	c.headers.Set(key, value)
 }
 
 // SetRetryConfig updates the retry configuration for the client.
@@ -94,11 +196,36 @@ func (c *Client) ClearCache() {
 	c.cache.clear()
 }
 
+// InvalidateCache removes cached entries for which pred returns true.
+// The predicate receives the endpoint and the original query string.
+func (c *Client) InvalidateCache(pred func(endpoint, query string) bool) {
+	if pred == nil {
+		return
+	}
+
+	c.cache.invalidate(pred)
+}
+
 // CacheSize returns the number of cached entries.
 func (c *Client) CacheSize() int {
 	return c.cache.size()
 }
 
+// PauseCacheCleanup stops the background removal of expired cache entries,
+// e.g. during a bulk import. Queries and the cache keep working; expired
+// entries are still never returned, they just stay in memory until cleanup
+// resumes.
+func (c *Client) PauseCacheCleanup() {
+	c.cache.pauseCleanup()
+}
+
+// ResumeCacheCleanup restarts the background cleanup stopped by
+// PauseCacheCleanup. It has no effect after Close or while the cache is
+// disabled.
+func (c *Client) ResumeCacheCleanup() {
+	c.cache.resumeCleanup(c.cacheCtx)
+}
+
 // Close stops the cache cleanup routine and releases resources.
 func (c *Client) Close() {
 	if c.cacheCancel != nil {
@@ -108,36 +235,115 @@ func (c *Client) Close() {
 
 // QueryContext sends request to OverpassAPI with provided querystring and context for cancellation/timeout.
 func (c *Client) QueryContext(ctx context.Context, query string) (Result, error) {
+	return c.QueryContextWith(ctx, query, QueryOptions{})
+}
+
+// QueryReader reads the query from r and runs it like QueryContext. The query
+// is read into a single string buffer without an intermediate byte slice; it
+// has to be held in memory anyway, since the prologue, retries and the
+// form-encoded request body all need it, and the result cache uses the full
+// query text as its key.
+func (c *Client) QueryReader(ctx context.Context, r io.Reader) (Result, error) {
+	var query strings.Builder
+
+	_, err := io.Copy(&query, r)
+	if err != nil {
+		return Result{}, fmt.Errorf("read query: %w", err)
+	}
+
+	return c.QueryContext(ctx, query.String())
+}
+
+// QueryOptions overrides client-wide behavior for a single query.
+type QueryOptions struct {
+	// NoCache neither reads nor stores the result in the cache.
+	NoCache bool
+
+	// Refresh skips the cache lookup but stores the fresh result, e.g. for a
+	// "refresh now" action.
+	Refresh bool
+
+	// Retry replaces the client's retry configuration when set. A config
+	// with MaxRetries 0 disables retries.
+	Retry *RetryConfig
+}
+
+// QueryContextWith works like QueryContext with opts applied to this query only.
+func (c *Client) QueryContextWith(ctx context.Context, query string, opts QueryOptions) (Result, error) {
+	if strings.TrimSpace(query) == "" {
+		return Result{}, ErrEmptyQuery
+	}
+
+	// Apply default timeout if the caller did not set a deadline
+	if _, hasDeadline := ctx.Deadline(); !hasDeadline && c.DefaultTimeout > 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, c.DefaultTimeout)
+		defer cancel()
+	}
+
+	query = c.applyPrologue(query)
+
+	var cacheExtra string
+	if c.CacheKeyExtra != nil {
+		cacheExtra = c.CacheKeyExtra()
+	}
+
 	// Check cache first
-	if result, hit := c.cache.get(c.apiEndpoint, query); hit {
-		return result, nil
+	if !opts.NoCache && !opts.Refresh {
+		if result, hit := c.cache.get(c.apiEndpoint, query, cacheExtra); hit {
+			err := c.checkElementLimit(result)
+			if err != nil {
+				return Result{}, err
+			}
+
+			return result, nil
+		}
+	}
+
+	retryConfig := c.retryConfig
+	if opts.Retry != nil {
+		retryConfig = *opts.Retry
 	}
 
-	var body []byte
+	var result Result
 	var err error
 
 	// Use retry logic if MaxRetries > 0
-	if c.retryConfig.MaxRetries > 0 {
-		body, err = c.retryableHTTPPost(ctx, query)
+	if retryConfig.MaxRetries > 0 {
+		result, err = c.retryableFetch(ctx, query, retryConfig)
 	} else {
-		body, err = c.httpPost(ctx, query)
+		result, err = c.fetch(ctx, query)
 	}
 
 	if err != nil {
 		return Result{}, err
 	}
 
-	result, err := unmarshal(body)
+	result.Query = query
+
+	err = c.checkElementLimit(result)
 	if err != nil {
 		return Result{}, err
 	}
 
 	// Store in cache
-	c.cache.set(c.apiEndpoint, query, result)
+	if !opts.NoCache {
+		c.cache.set(c.apiEndpoint, query, cacheExtra, result)
+	}
 
 	return result, nil
 }
 
+// checkElementLimit enforces MaxElements.
+func (c *Client) checkElementLimit(result Result) error {
+	if c.MaxElements > 0 && result.Count > c.MaxElements {
+		return &TooManyElementsError{Count: result.Count, Limit: c.MaxElements}
+	}
+
+	return nil
+}
+
 // Query is deprecated: use QueryContext instead.
 // It sends request to OverpassAPI with context.Background().
 func (c *Client) Query(query string) (Result, error) {
@@ -145,8 +351,53 @@ func (c *Client) Query(query string) (Result, error) {
 }
 
 // QueryWithBuilder executes query from builder (convenience method).
+// The query is sent in the client's OutputFormat: a builder with the default
+// format is switched to it (the builder itself is not modified), while a
+// builder whose Format differs yields ErrFormatMismatch. Builders failing
+// Validate return its error. In both cases no request is sent.
 func (c *Client) QueryWithBuilder(ctx context.Context, builder *QueryBuilder) (Result, error) {
-	return c.QueryContext(ctx, builder.Build())
+	format := c.OutputFormat
+	if format == "" {
+		format = "json"
+	}
+
+	if format != "json" && format != "xml" {
+		return Result{}, fmt.Errorf("%w: client output format %q cannot be decoded", ErrFormatMismatch, format)
+	}
+
+	if builder.format != "" && builder.format != format {
+		return Result{}, fmt.Errorf("%w: builder requests %q, client uses %q", ErrFormatMismatch, builder.format, format)
+	}
+
+	err := builder.Validate()
+	if err != nil {
+		return Result{}, err
+	}
+
+	return c.QueryContext(ctx, builder.withFormat(format).Build())
+}
+
+// AreasContaining returns the areas (administrative boundaries, landuse,
+// ...) that contain the coordinate, in Result.Areas.
+func (c *Client) AreasContaining(ctx context.Context, lat, lon float64) (Result, error) {
+	return c.QueryWithBuilder(ctx, NewQueryBuilder().IsIn(lat, lon))
+}
+
+// Relation fetches relation id with its members and nested relations (see
+// FetchRelation). Member ways carry their geometry. A relation missing from
+// the response yields ErrElementNotFound.
+func (c *Client) Relation(ctx context.Context, id int64) (*Relation, error) {
+	result, err := c.QueryWithBuilder(ctx, FetchRelation(id))
+	if err != nil {
+		return nil, err
+	}
+
+	relation, ok := result.Relations[id]
+	if !ok {
+		return nil, fmt.Errorf("relation %d: %w", id, ErrElementNotFound)
+	}
+
+	return relation, nil
 }
 
 var DefaultClient = New()
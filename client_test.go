@@ -1,10 +1,14 @@
 package overpass
 
 import (
+	"context"
+	"errors"
 	"net/http"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"testing"
+	"testing/iotest"
 	"time"
 )
 
@@ -21,12 +25,12 @@ func TestNew(t *testing.T) {
 		t.Error("expected http.DefaultClient")
 	}
 
-	if cap(client.semaphore) != 1 {
-		t.Errorf("expected semaphore capacity 1, got %d", cap(client.semaphore))
+	if client.semaphore.capacity() != 1 {
+		t.Errorf("expected semaphore capacity 1, got %d", client.semaphore.capacity())
 	}
 
-	if len(client.semaphore) != 1 {
-		t.Errorf("expected semaphore length 1, got %d", len(client.semaphore))
+	if client.semaphore.available() != 1 {
+		t.Errorf("expected semaphore length 1, got %d", client.semaphore.available())
 	}
 }
 
@@ -47,12 +51,12 @@ func TestNewWithSettings(t *testing.T) {
 		t.Error("expected custom HTTP client")
 	}
 
-	if cap(client.semaphore) != maxParallel {
-		t.Errorf("expected semaphore capacity %d, got %d", maxParallel, cap(client.semaphore))
+	if client.semaphore.capacity() != maxParallel {
+		t.Errorf("expected semaphore capacity %d, got %d", maxParallel, client.semaphore.capacity())
 	}
 
-	if len(client.semaphore) != maxParallel {
-		t.Errorf("expected semaphore length %d, got %d", maxParallel, len(client.semaphore))
+	if client.semaphore.available() != maxParallel {
+		t.Errorf("expected semaphore length %d, got %d", maxParallel, client.semaphore.available())
 	}
 }
 
@@ -182,3 +186,522 @@ func (m *mockConcurrentHTTPClient) Do(_ *http.Request) (*http.Response, error) {
 		Body:       newTestBody(`{"elements":[]}`),
 	}, nil
 }
+
+func TestSetMaxParallel(t *testing.T) {
+	t.Parallel()
+
+	maxConcurrent := int32(0)
+	currentConcurrent := int32(0)
+
+	slowClient := &mockSlowHTTPClient{
+		delay: 20 * time.Millisecond,
+		onRequest: func() {
+			current := atomic.AddInt32(&currentConcurrent, 1)
+
+			for {
+				maxCur := atomic.LoadInt32(&maxConcurrent)
+				if current <= maxCur || atomic.CompareAndSwapInt32(&maxConcurrent, maxCur, current) {
+					break
+				}
+			}
+		},
+		onResponse: func() {
+			atomic.AddInt32(&currentConcurrent, -1)
+		},
+	}
+
+	client := NewWithSettings(apiEndpoint, 4, slowClient)
+
+	runQueries := func(n int) {
+		var waitGroup sync.WaitGroup
+		waitGroup.Add(n)
+
+		for i := 0; i < n; i++ {
+			go func() {
+				defer waitGroup.Done()
+
+				_, err := client.Query(`[out:json];node(1);out;`)
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+			}()
+		}
+
+		waitGroup.Wait()
+	}
+
+	client.SetMaxParallel(1)
+	runQueries(4)
+
+	if maxCur := atomic.LoadInt32(&maxConcurrent); maxCur > 1 {
+		t.Errorf("max concurrent %d exceeds reduced limit 1", maxCur)
+	}
+
+	atomic.StoreInt32(&maxConcurrent, 0)
+	client.SetMaxParallel(3)
+	runQueries(9)
+
+	if maxCur := atomic.LoadInt32(&maxConcurrent); maxCur > 3 {
+		t.Errorf("max concurrent %d exceeds increased limit 3", maxCur)
+	}
+
+	client.SetMaxParallel(0)
+
+	if got := client.semaphore.capacity(); got != 1 {
+		t.Errorf("expected non-positive limit to be clamped to 1, got %d", got)
+	}
+}
+
+func TestSetQueryPrologue(t *testing.T) {
+	t.Parallel()
+
+	mock := &mockRecordingHTTPClient{}
+	client := NewWithSettings(apiEndpoint, 1, mock)
+	client.SetQueryPrologue("[out:json][timeout:25]")
+
+	_, err := client.Query(`node(1);out;`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Queries with their own settings header are left untouched
+	_, err = client.Query(`[out:json][timeout:60];node(2);out;`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	queries := mock.recordedQueries()
+
+	if queries[0] != `[out:json][timeout:25];node(1);out;` {
+		t.Errorf("prologue not applied: %s", queries[0])
+	}
+
+	if queries[1] != `[out:json][timeout:60];node(2);out;` {
+		t.Errorf("prologue applied to query with settings: %s", queries[1])
+	}
+}
+
+func TestQueryPrologueCacheKey(t *testing.T) {
+	t.Parallel()
+
+	mock := &mockRecordingHTTPClient{}
+	client := NewWithSettings(apiEndpoint, 1, mock)
+	client.SetCacheConfig(CacheConfig{Enabled: true, TTL: time.Minute, MaxEntries: 10})
+	client.SetQueryPrologue("[out:json][timeout:25];")
+
+	for i := 0; i < 2; i++ {
+		_, err := client.Query(`node(1);out;`)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	if n := len(mock.recordedQueries()); n != 1 {
+		t.Errorf("expected 1 request with cache, got %d", n)
+	}
+
+	// The cache is keyed by the effective query including the prologue
+	if _, hit := client.cache.get(apiEndpoint, `[out:json][timeout:25];node(1);out;`, ""); !hit {
+		t.Error("expected cache entry for effective query")
+	}
+
+	// Changing the prologue changes the effective query and misses the cache
+	client.SetQueryPrologue("[out:json][timeout:90];")
+
+	_, err := client.Query(`node(1);out;`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if n := len(mock.recordedQueries()); n != 2 {
+		t.Errorf("expected new request after prologue change, got %d requests", n)
+	}
+}
+
+func TestSetHeader(t *testing.T) {
+	t.Parallel()
+
+	mock := &mockRecordingHTTPClient{}
+	client := NewWithSettings(apiEndpoint, 1, mock)
+	client.SetHeader("Authorization", "Bearer secret")
+	client.SetHeader("X-Removed", "value")
+	client.SetHeader("X-Removed", "")
+
+	_, err := client.Query(`[out:json];node(1);out;`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	headers := mock.headers[0]
+
+	if got := headers.Get("Authorization"); got != "Bearer secret" {
+		t.Errorf("expected Authorization header, got %q", got)
+	}
+
+	if got := headers.Get("Content-Type"); got != "application/x-www-form-urlencoded" {
+		t.Errorf("Content-Type should be kept, got %q", got)
+	}
+
+	if _, ok := headers["X-Removed"]; ok {
+		t.Error("header set to empty value should be removed")
+	}
+}
+
+func TestSetHeaderCopiedClient(t *testing.T) {
+	t.Parallel()
+
+	mock := &mockRecordingHTTPClient{}
+	a := NewWithSettings(apiEndpoint, 1, mock)
+	a.SetHeader("Authorization", "token-a")
+
+	b := a
+	b.SetHeader("Authorization", "token-b")
+	b.SetHeader("X-Extra", "b")
+
+	_, err := a.Query(`[out:json];node(1);out;`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	_, err = b.Query(`[out:json];node(2);out;`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := mock.headers[0].Get("Authorization"); got != "token-a" {
+		t.Errorf("original client: expected token-a, got %q", got)
+	}
+
+	if _, ok := mock.headers[0]["X-Extra"]; ok {
+		t.Error("original client must not see headers set on the copy")
+	}
+
+	if got := mock.headers[1].Get("Authorization"); got != "token-b" {
+		t.Errorf("copied client: expected token-b, got %q", got)
+	}
+}
+
+func TestSetHeaderOverridesDefaults(t *testing.T) {
+	t.Parallel()
+
+	mock := &mockRecordingHTTPClient{}
+	client := NewClient(
+		WithHTTPClient(mock),
+		WithUserAgent("my-app/1.0"),
+		WithHeader("Content-Type", "application/x-www-form-urlencoded; charset=utf-8"),
+		WithHeader("User-Agent", "proxy-app/2.0"),
+	)
+
+	_, err := client.Query(`[out:json];node(1);out;`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	headers := mock.headers[0]
+
+	if got := headers.Get("Content-Type"); got != "application/x-www-form-urlencoded; charset=utf-8" {
+		t.Errorf("explicit Content-Type should win, got %q", got)
+	}
+
+	if got := headers.Get("User-Agent"); got != "proxy-app/2.0" {
+		t.Errorf("explicit User-Agent header should win, got %q", got)
+	}
+}
+
+func TestResultQueryProvenance(t *testing.T) {
+	t.Parallel()
+
+	client := NewWithSettings(apiEndpoint, 1, &mockRecordingHTTPClient{})
+	client.SetQueryPrologue("[out:json]")
+
+	result, err := client.QueryContext(context.Background(), "node(1);out;")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if result.Query != "[out:json];node(1);out;" {
+		t.Errorf("expected submitted query, got %q", result.Query)
+	}
+
+	decoded, err := unmarshal([]byte(`{"elements":[]}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if decoded.Query != "" {
+		t.Errorf("expected empty query for direct decoding, got %q", decoded.Query)
+	}
+}
+
+func TestAreasContaining(t *testing.T) {
+	t.Parallel()
+
+	body := `{"elements":[
+		{"type":"area","id":3600062422,"tags":{"admin_level":"4","name":"Berlin"}},
+		{"type":"area","id":3600051477,"tags":{"admin_level":"2","name":"Deutschland"}}
+	]}`
+	mock := &mockRecordingHTTPClient{bodies: []string{body}}
+	client := NewWithSettings(apiEndpoint, 1, mock)
+
+	result, err := client.AreasContaining(context.Background(), 52.52, 13.405)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if queries := mock.recordedQueries(); queries[0] != "[out:json]is_in(52.52,13.405);out body;" {
+		t.Errorf("unexpected query: %s", queries[0])
+	}
+
+	if len(result.Areas) != 2 || result.Count != 2 {
+		t.Fatalf("expected 2 areas, got %+v", result.Areas)
+	}
+
+	if name := result.Areas[3600062422].Tags["name"]; name != "Berlin" {
+		t.Errorf("expected Berlin, got %q", name)
+	}
+}
+
+func TestClientRelation(t *testing.T) {
+	t.Parallel()
+
+	body := `{"elements":[
+		{"type":"node","id":1,"lat":52.5,"lon":13.4},
+		{"type":"way","id":10,"nodes":[1],"geometry":[{"lat":52.5,"lon":13.4},{"lat":52.6,"lon":13.5}]},
+		{"type":"relation","id":7,"tags":{"type":"route"},"members":[{"type":"way","ref":10,"role":""}]},
+		{"type":"relation","id":5,"tags":{"type":"route_master"},"members":[{"type":"relation","ref":7,"role":""}]}
+	]}`
+	mock := &mockRecordingHTTPClient{bodies: []string{body}}
+	client := NewWithSettings(apiEndpoint, 1, mock)
+
+	relation, err := client.Relation(context.Background(), 5)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if queries := mock.recordedQueries(); queries[0] != "[out:json](relation(5); >>;);out geom;" {
+		t.Errorf("unexpected query: %s", queries[0])
+	}
+
+	nested := relation.Members[0].Relation
+	if nested == nil || nested.ID != 7 || len(nested.Members[0].Way.Geometry) != 2 {
+		t.Fatalf("expected nested relation with way geometry, got %+v", relation.Members)
+	}
+
+	_, err = client.Relation(context.Background(), 99)
+	if !errors.Is(err, ErrElementNotFound) {
+		t.Errorf("expected ErrElementNotFound, got %v", err)
+	}
+}
+
+func TestClientQueryReader(t *testing.T) {
+	t.Parallel()
+
+	mock := &mockRecordingHTTPClient{bodies: []string{`{"elements":[{"type":"node","id":1,"lat":52.5,"lon":13.4}]}`}}
+	client := NewWithSettings(apiEndpoint, 1, mock)
+
+	query := `[out:json];node["amenity"="cafe"](52.5,13.4,52.6,13.5);out;`
+
+	result, err := client.QueryReader(context.Background(), strings.NewReader(query))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := mock.recordedQueries(); len(got) != 1 || got[0] != query {
+		t.Errorf("expected query %q to be sent, got %q", query, got)
+	}
+
+	if result.Count != 1 || result.Query != query {
+		t.Errorf("unexpected result: %d elements for %q", result.Count, result.Query)
+	}
+
+	errRead := errors.New("disk on fire")
+
+	_, err = client.QueryReader(context.Background(), iotest.ErrReader(errRead))
+	if !errors.Is(err, errRead) {
+		t.Errorf("expected read error, got %v", err)
+	}
+
+	_, err = client.QueryReader(context.Background(), strings.NewReader("  "))
+	if !errors.Is(err, ErrEmptyQuery) {
+		t.Errorf("expected ErrEmptyQuery, got %v", err)
+	}
+}
+
+func TestQueryWithBuilderFormat(t *testing.T) {
+	t.Parallel()
+
+	mock := &mockRecordingHTTPClient{}
+	client := NewWithSettings(apiEndpoint, 1, mock)
+
+	builder := NewQueryBuilder().Node().Tag("amenity", "cafe").Format("xml").Timeout(30)
+
+	_, err := client.QueryWithBuilder(context.Background(), builder)
+	if !errors.Is(err, ErrFormatMismatch) {
+		t.Errorf("expected ErrFormatMismatch, got %v", err)
+	}
+
+	if queries := mock.recordedQueries(); len(queries) != 0 {
+		t.Errorf("expected no request, got %q", queries)
+	}
+
+	parsed, err := ParseQuery(`[out:csv(name)];node["amenity"="cafe"];out;`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	_, err = client.QueryWithBuilder(context.Background(), parsed)
+	if !errors.Is(err, ErrFormatMismatch) {
+		t.Errorf("expected ErrFormatMismatch for a parsed format, got %v", err)
+	}
+
+	csvClient := NewClient(WithHTTPClient(mock), WithOutputFormat("csv"))
+
+	_, err = csvClient.QueryWithBuilder(context.Background(), NewQueryBuilder().Node())
+	if !errors.Is(err, ErrFormatMismatch) {
+		t.Errorf("expected ErrFormatMismatch for an undecodable client format, got %v", err)
+	}
+}
+
+func TestQueryWithBuilderClientXML(t *testing.T) {
+	t.Parallel()
+
+	body := `<?xml version="1.0" encoding="UTF-8"?>
+<osm version="0.6" generator="Overpass API">
+  <meta osm_base="2024-01-01T00:00:00Z"/>
+  <node id="1" lat="52.5" lon="13.4"><tag k="amenity" v="cafe"/></node>
+  <node id="2" lat="52.6" lon="13.5"/>
+  <way id="10" version="3" timestamp="2023-05-01T10:00:00Z" user="mapper">
+    <center lat="52.55" lon="13.45"/>
+    <nd ref="1" lat="52.5" lon="13.4"/>
+    <nd ref="2" lat="52.6" lon="13.5"/>
+    <tag k="highway" v="residential"/>
+  </way>
+  <relation id="7"><member type="way" ref="10" role="outer"/></relation>
+</osm>`
+	mock := &mockRecordingHTTPClient{bodies: []string{body}}
+	client := NewClient(WithHTTPClient(mock), WithOutputFormat("xml"))
+
+	builder := NewQueryBuilder().Way().Tag("highway", "residential").Timeout(30)
+
+	result, err := client.QueryWithBuilder(context.Background(), builder)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if query := mock.recordedQueries()[0]; !strings.HasPrefix(query, "[out:xml][timeout:30]") {
+		t.Errorf("expected the client's XML format, got %s", query)
+	}
+
+	if query := builder.Build(); !strings.HasPrefix(query, "[out:json]") {
+		t.Errorf("builder must not be modified, got %s", query)
+	}
+
+	if result.Count != 4 || result.Nodes[1].Tags["amenity"] != "cafe" {
+		t.Errorf("unexpected nodes: %d elements, %+v", result.Count, result.Nodes)
+	}
+
+	way := result.Ways[10]
+	if way == nil || way.Nodes[1] != result.Nodes[2] || len(way.Geometry) != 2 || way.Version != 3 ||
+		way.Timestamp == nil || way.Center == nil || way.Center.Lat != 52.55 {
+		t.Errorf("unexpected way: %+v", way)
+	}
+
+	if member := result.Relations[7].Members[0]; member.Way != way || member.Role != "outer" {
+		t.Errorf("unexpected member: %+v", member)
+	}
+
+	if !result.Timestamp.Equal(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected timestamp %v", result.Timestamp)
+	}
+}
+
+func TestQueryWithBuilderValidates(t *testing.T) {
+	t.Parallel()
+
+	mock := &mockRecordingHTTPClient{}
+	client := NewWithSettings(apiEndpoint, 1, mock)
+
+	_, err := client.QueryWithBuilder(context.Background(), NewQueryBuilder().NodeIDs())
+	if !errors.Is(err, ErrNoIDs) {
+		t.Errorf("expected ErrNoIDs, got %v", err)
+	}
+
+	if queries := mock.recordedQueries(); len(queries) != 0 {
+		t.Errorf("expected no request, got %q", queries)
+	}
+
+	if query := NewQueryBuilder().NodeIDs().Build(); strings.Contains(query, "node;") {
+		t.Errorf("empty id selection must not select all elements: %s", query)
+	}
+}
+
+func TestQueryContextWithCacheOptions(t *testing.T) {
+	t.Parallel()
+
+	mock := &mockRecordingHTTPClient{bodies: []string{
+		`{"elements":[{"type":"node","id":1}]}`,
+		`{"elements":[{"type":"node","id":1},{"type":"node","id":2}]}`,
+		`{"elements":[{"type":"node","id":1},{"type":"node","id":2},{"type":"node","id":3}]}`,
+	}}
+	client := NewWithSettings(apiEndpoint, 1, mock)
+	client.SetCacheConfig(CacheConfig{Enabled: true, TTL: time.Minute, MaxEntries: 10})
+
+	ctx := context.Background()
+	query := "[out:json];node;out;"
+
+	steps := []struct {
+		name      string
+		opts      QueryOptions
+		wantCount int
+		requests  int
+	}{
+		{"initial fetch is cached", QueryOptions{}, 1, 1},
+		{"no-cache skips read", QueryOptions{NoCache: true}, 2, 2},
+		{"no-cache skipped write", QueryOptions{}, 1, 2},
+		{"refresh skips read", QueryOptions{Refresh: true}, 3, 3},
+		{"refresh stored result", QueryOptions{}, 3, 3},
+	}
+
+	for _, step := range steps {
+		result, err := client.QueryContextWith(ctx, query, step.opts)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", step.name, err)
+		}
+
+		if result.Count != step.wantCount {
+			t.Errorf("%s: expected count %d, got %d", step.name, step.wantCount, result.Count)
+		}
+
+		if n := len(mock.recordedQueries()); n != step.requests {
+			t.Errorf("%s: expected %d requests, got %d", step.name, step.requests, n)
+		}
+	}
+}
+
+func TestQueryContextWithRetryOverride(t *testing.T) {
+	t.Parallel()
+
+	noRetry := RetryConfig{}
+
+	client := NewWithSettings(apiEndpoint, 1, &failingMockClient{failCount: 1, statusCode: http.StatusServiceUnavailable})
+	client.SetRetryConfig(DefaultRetryConfig())
+
+	_, err := client.QueryContextWith(context.Background(), "node(1);out;", QueryOptions{Retry: &noRetry})
+	if err == nil {
+		t.Fatal("expected error without retries")
+	}
+
+	retry := RetryConfig{MaxRetries: 1, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond, BackoffMultiplier: 1}
+	mock := &failingMockClient{failCount: 1, statusCode: http.StatusServiceUnavailable}
+	client = NewWithSettings(apiEndpoint, 1, mock)
+	client.SetRetryConfig(RetryConfig{})
+
+	_, err = client.QueryContextWith(context.Background(), "node(1);out;", QueryOptions{Retry: &retry})
+	if err != nil {
+		t.Fatalf("expected retry override to succeed, got %v", err)
+	}
+
+	if mock.currentFail != 2 {
+		t.Errorf("expected 2 attempts, got %d", mock.currentFail)
+	}
+}
@@ -220,3 +220,40 @@ func (m *mockCancellableHTTPClient) Do(req *http.Request) (*http.Response, error
 		return nil, req.Context().Err()
 	}
 }
+
+func TestQueryContext_DefaultTimeout(t *testing.T) {
+	t.Parallel()
+
+	client := NewWithSettings(apiEndpoint, 1, &mockCancellableHTTPClient{
+		delay: 200 * time.Millisecond,
+	})
+	client.DefaultTimeout = 50 * time.Millisecond
+
+	// Deprecated Query uses context.Background, so the default timeout applies
+	_, err := client.Query(`[out:json];node(1);out;`)
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context.DeadlineExceeded error, got: %v", err)
+	}
+}
+
+func TestQueryContext_DefaultTimeoutKeepsCallerDeadline(t *testing.T) {
+	t.Parallel()
+
+	client := NewWithSettings(apiEndpoint, 1, &mockCancellableHTTPClient{
+		delay: 50 * time.Millisecond,
+	})
+	client.DefaultTimeout = 10 * time.Millisecond
+
+	// An explicit deadline takes precedence over DefaultTimeout
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	_, err := client.QueryContext(ctx, `[out:json];node(1);out;`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,236 @@
+package overpass
+
+import (
+	"slices"
+	"strings"
+)
+
+// CostLevel is a coarse estimate of how expensive a query is to run.
+type CostLevel int
+
+// Cost levels in increasing order.
+const (
+	CostLow CostLevel = iota
+	CostMedium
+	CostHigh
+)
+
+// String returns "low", "medium" or "high".
+func (l CostLevel) String() string {
+	switch l {
+	case CostLow:
+		return "low"
+	case CostMedium:
+		return "medium"
+	default:
+		return "high"
+	}
+}
+
+// CostEstimate is the result of EstimateCost.
+type CostEstimate struct {
+	Level   CostLevel
+	Reasons []string // why the level was raised, empty for CostLow
+}
+
+// EstimateCost statically inspects a QL query for cost signals, e.g. to warn
+// before sending an expensive query. It is a heuristic, not an exact
+// prediction:
+//   - a node, way, relation or nwr selection without a bbox, area, around,
+//     poly, id, input set or recurse constraint such as (w) or (bn) (and no
+//     global [bbox:...] setting) scans the whole planet and is High
+//   - regular expression tag filters and the recursive >> and << operators
+//     are Medium
+//
+// A query that cannot be split into statements is reported as High.
+func EstimateCost(query string) CostEstimate {
+	var estimate CostEstimate
+
+	settings, body, err := cutSettings(strings.TrimSpace(blankStrings(query)))
+	if err != nil {
+		estimate.raise(CostHigh, "malformed query")
+
+		return estimate
+	}
+
+	statements, err := splitStatements(body)
+	if err != nil {
+		estimate.raise(CostHigh, "malformed query")
+
+		return estimate
+	}
+
+	bounded := slices.ContainsFunc(settings, func(setting string) bool {
+		return strings.HasPrefix(strings.TrimSpace(setting), "bbox:")
+	})
+
+	for _, statement := range statements {
+		estimate.inspect(statement, bounded)
+	}
+
+	return estimate
+}
+
+// inspect raises the estimate for the cost signals of a single statement,
+// descending into union blocks.
+func (e *CostEstimate) inspect(statement string, bounded bool) {
+	statement = strings.TrimSpace(statement)
+	if i := strings.Index(statement, "->"); i >= 0 {
+		statement = strings.TrimSpace(statement[:i])
+	}
+
+	if strings.HasPrefix(statement, "(") && strings.HasSuffix(statement, ")") {
+		inner, err := splitStatements(statement[1 : len(statement)-1])
+		if err == nil {
+			for _, s := range inner {
+				e.inspect(s, bounded)
+			}
+		}
+
+		return
+	}
+
+	switch statement {
+	case ">>", "<<":
+		e.raise(CostMedium, "recurse "+statement)
+
+		return
+	}
+
+	typeEnd := strings.IndexFunc(statement, func(r rune) bool { return r < 'a' || r > 'z' })
+	if typeEnd < 0 {
+		typeEnd = len(statement)
+	}
+
+	elementType := statement[:typeEnd]
+
+	switch elementType {
+	case "node", "way", "rel", "relation", "nwr", "nw", "nr", "wr":
+	default:
+		return
+	}
+
+	filters := statement[typeEnd:]
+
+	if hasRegexFilter(filters) {
+		e.raise(CostMedium, "regex tag filter")
+	}
+
+	if !bounded && !isBoundedSelection(filters) {
+		e.raise(CostHigh, "unbounded "+elementType+" selection")
+	}
+}
+
+// raise lifts the level to at least level and records reason once.
+func (e *CostEstimate) raise(level CostLevel, reason string) {
+	e.Level = max(e.Level, level)
+
+	if !slices.Contains(e.Reasons, reason) {
+		e.Reasons = append(e.Reasons, reason)
+	}
+}
+
+// hasRegexFilter reports whether a [k~v] or [~k~v] filter is present.
+func hasRegexFilter(filters string) bool {
+	for rest := filters; ; {
+		start := strings.IndexByte(rest, '[')
+		if start < 0 {
+			return false
+		}
+
+		end := strings.IndexByte(rest[start:], ']')
+		if end < 0 {
+			return false
+		}
+
+		if strings.Contains(rest[start:start+end], "~") {
+			return true
+		}
+
+		rest = rest[start+end+1:]
+	}
+}
+
+// isBoundedSelection reports whether the filters of a selection restrict it
+// to a region or to known elements.
+func isBoundedSelection(filters string) bool {
+	// node.a["k"] selects from the input set a
+	if strings.HasPrefix(filters, ".") {
+		return true
+	}
+
+	for rest := filters; ; {
+		start := strings.IndexByte(rest, '(')
+		if start < 0 {
+			return false
+		}
+
+		end, err := matchingBracket(rest, start)
+		if err != nil {
+			return false
+		}
+
+		filter := strings.TrimSpace(rest[start+1 : end])
+
+		for _, prefix := range []string{"area", "around", "poly", "id:", "pivot", "."} {
+			if strings.HasPrefix(filter, prefix) {
+				return true
+			}
+		}
+
+		// A bbox (s,w,n,e) or an element id
+		if filter != "" && strings.ContainsRune("0123456789-", rune(filter[0])) {
+			return true
+		}
+
+		if isRecurseFilter(filter) {
+			return true
+		}
+
+		rest = rest[end+1:]
+	}
+}
+
+// isRecurseFilter reports whether filter selects members or parents of an
+// input set, e.g. w, r.a, bn or r:"stop" as in node(w) or way(r.a:"outer").
+func isRecurseFilter(filter string) bool {
+	head := filter
+	if i := strings.IndexAny(filter, ".:"); i >= 0 {
+		head = filter[:i]
+	}
+
+	switch strings.TrimSpace(head) {
+	case "w", "r", "bn", "bw", "br":
+		return true
+	default:
+		return false
+	}
+}
+
+// blankStrings empties the contents of all string literals, so quoted values
+// cannot be mistaken for syntax. Unterminated strings are left as they are.
+func blankStrings(query string) string {
+	var sb strings.Builder
+
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		if c != '"' && c != '\'' {
+			sb.WriteByte(c)
+
+			continue
+		}
+
+		end := closingQuote(query, i)
+		if end < 0 {
+			sb.WriteString(query[i:])
+
+			break
+		}
+
+		sb.WriteString(string([]byte{c, c}))
+
+		i = end
+	}
+
+	return sb.String()
+}
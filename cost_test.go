@@ -0,0 +1,110 @@
+package overpass
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestEstimateCost(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name    string
+		query   string
+		level   CostLevel
+		reasons []string
+	}{
+		{
+			name:  "bbox",
+			query: `[out:json];node["amenity"="cafe"](52.5,13.4,52.6,13.5);out;`,
+			level: CostLow,
+		},
+		{
+			name:  "global bbox setting",
+			query: `[out:json][bbox:52.5,13.4,52.6,13.5];way["highway"];out geom;`,
+			level: CostLow,
+		},
+		{
+			name:  "area with way nodes",
+			query: `area["name"="Berlin"]->.a;(way["highway"](area.a););(._;>;);out;`,
+			level: CostLow,
+		},
+		{
+			name:  "way nodes",
+			query: `way(12345);node(w);out;`,
+			level: CostLow,
+		},
+		{
+			name:  "relation members",
+			query: `rel(123);way(r);out;`,
+			level: CostLow,
+		},
+		{
+			name:  "recurse filters with sets and roles",
+			query: `way(1)->.a;rel(123)->.b;node(w.a);way(r.b:"outer");rel(bn);rel(bw.a);rel(br);out;`,
+			level: CostLow,
+		},
+		{
+			name:    "unbounded regex over the planet",
+			query:   `[out:json];nwr["name"~"^Bahnhof"];out;`,
+			level:   CostHigh,
+			reasons: []string{"regex tag filter", "unbounded nwr selection"},
+		},
+		{
+			name:    "bounded regex with deep recursion",
+			query:   `(relation["route"~"bus|tram"](around:500,52.5,13.4););>>;out;`,
+			level:   CostMedium,
+			reasons: []string{"regex tag filter", "recurse >>"},
+		},
+		{
+			name:    "parentheses inside a value are ignored",
+			query:   `node["name"="(52.5,13.4)"];out;`,
+			level:   CostHigh,
+			reasons: []string{"unbounded node selection"},
+		},
+		{
+			name:    "builder regex over the planet",
+			query:   NewQueryBuilder().Node().TagRegex("name", "^A").Build(),
+			level:   CostHigh,
+			reasons: []string{"regex tag filter", "unbounded node selection"},
+		},
+		{
+			name:  "builder bbox",
+			query: NewQueryBuilder().Way().Tag("highway", "primary").BBox(52.5, 13.4, 52.6, 13.5).Build(),
+			level: CostLow,
+		},
+		{
+			name:  "builder timeout with ids",
+			query: NewQueryBuilder().Timeout(25).NodeIDs(1, 2).Build(),
+			level: CostLow,
+		},
+		{
+			name:  "global bbox setting without separator",
+			query: `[out:json][bbox:52.5,13.4,52.6,13.5]way["highway"];out geom;`,
+			level: CostLow,
+		},
+		{
+			name:    "malformed",
+			query:   `node["amenity"(1,2,3,4);out;`,
+			level:   CostHigh,
+			reasons: []string{"malformed query"},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			got := EstimateCost(tc.query)
+
+			if got.Level != tc.level {
+				t.Errorf("expected level %s, got %s (%v)", tc.level, got.Level, got.Reasons)
+			}
+
+			if !slices.Equal(got.Reasons, tc.reasons) {
+				t.Errorf("expected reasons %q, got %q", tc.reasons, got.Reasons)
+			}
+		})
+	}
+}
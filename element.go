@@ -0,0 +1,103 @@
+package overpass
+
+// Element is a single node, way or relation of a Result.
+// Exactly one of Node, Way and Relation is set, matching Type.
+type Element struct {
+	Type     ElementType
+	Node     *Node
+	Way      *Way
+	Relation *Relation
+}
+
+// Meta returns the common metadata of the wrapped element.
+func (e Element) Meta() *Meta {
+	switch {
+	case e.Node != nil:
+		return &e.Node.Meta
+	case e.Way != nil:
+		return &e.Way.Meta
+	case e.Relation != nil:
+		return &e.Relation.Meta
+	default:
+		return &Meta{}
+	}
+}
+
+// Elements returns all elements of the result: nodes, then ways, then
+// relations, each sorted by id.
+func (r *Result) Elements() []Element {
+	elements := make([]Element, 0, len(r.Nodes)+len(r.Ways)+len(r.Relations))
+
+	for _, id := range sortedKeys(r.Nodes) {
+		elements = append(elements, Element{Type: ElementTypeNode, Node: r.Nodes[id]})
+	}
+
+	for _, id := range sortedKeys(r.Ways) {
+		elements = append(elements, Element{Type: ElementTypeWay, Way: r.Ways[id]})
+	}
+
+	for _, id := range sortedKeys(r.Relations) {
+		elements = append(elements, Element{Type: ElementTypeRelation, Relation: r.Relations[id]})
+	}
+
+	return elements
+}
+
+// IsSkeletal reports whether no element of the result carries tags or
+// coordinates, as returned by "out ids;" or "out skel;" without node
+// coordinates. Callers can use it to decide whether a follow-up detail query
+// is needed. An empty result is skeletal too.
+func (r *Result) IsSkeletal() bool {
+	for _, element := range r.Elements() {
+		if len(element.Meta().Tags) > 0 {
+			return false
+		}
+
+		if _, ok := element.location(); ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// SplitByType returns three results holding only the nodes, ways and
+// relations of r respectively. Count is recomputed per result; Timestamp is
+// shared. Areas are not nodes, ways or relations and are left out of all
+// three. Elements are not copied, so the results share them with r (a way's
+// member nodes remain reachable through Way.Nodes).
+func (r *Result) SplitByType() (nodes, ways, relations Result) {
+	nodes = r.withElements(r.Nodes, map[int64]*Way{}, map[int64]*Relation{})
+	ways = r.withElements(map[int64]*Node{}, r.Ways, map[int64]*Relation{})
+	relations = r.withElements(map[int64]*Node{}, map[int64]*Way{}, r.Relations)
+
+	return nodes, ways, relations
+}
+
+// withElements returns a result with r's metadata and copies of the given maps.
+func (r *Result) withElements(nodes map[int64]*Node, ways map[int64]*Way, relations map[int64]*Relation) Result {
+	result := Result{
+		Timestamp:          r.Timestamp,
+		DataTimestampRaw:   r.DataTimestampRaw,
+		TimestampAreasBase: r.TimestampAreasBase,
+		Nodes:              make(map[int64]*Node, len(nodes)),
+		Ways:               make(map[int64]*Way, len(ways)),
+		Relations:          make(map[int64]*Relation, len(relations)),
+	}
+
+	for id, node := range nodes {
+		result.Nodes[id] = node
+	}
+
+	for id, way := range ways {
+		result.Ways[id] = way
+	}
+
+	for id, relation := range relations {
+		result.Relations[id] = relation
+	}
+
+	result.Count = len(result.Nodes) + len(result.Ways) + len(result.Relations)
+
+	return result
+}
@@ -0,0 +1,163 @@
+package overpass
+
+import (
+	"testing"
+	"time"
+)
+
+func TestResultElements(t *testing.T) {
+	t.Parallel()
+
+	result := Result{
+		Nodes: map[int64]*Node{
+			2: {Meta: Meta{ID: 2}},
+			1: {Meta: Meta{ID: 1}},
+		},
+		Ways:      map[int64]*Way{10: {Meta: Meta{ID: 10}}},
+		Relations: map[int64]*Relation{20: {Meta: Meta{ID: 20}}},
+	}
+
+	elements := result.Elements()
+
+	expected := []struct {
+		elemType ElementType
+		id       int64
+	}{
+		{ElementTypeNode, 1},
+		{ElementTypeNode, 2},
+		{ElementTypeWay, 10},
+		{ElementTypeRelation, 20},
+	}
+
+	if len(elements) != len(expected) {
+		t.Fatalf("expected %d elements, got %d", len(expected), len(elements))
+	}
+
+	for i, want := range expected {
+		if elements[i].Type != want.elemType || elements[i].Meta().ID != want.id {
+			t.Errorf("element %d: expected %s %d, got %s %d",
+				i, want.elemType, want.id, elements[i].Type, elements[i].Meta().ID)
+		}
+	}
+}
+
+func TestElementMetaEmpty(t *testing.T) {
+	t.Parallel()
+
+	if meta := (Element{}).Meta(); meta == nil || meta.ID != 0 {
+		t.Errorf("expected empty meta, got %+v", meta)
+	}
+}
+
+func TestResultSplitByType(t *testing.T) {
+	t.Parallel()
+
+	ts := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	result := Result{
+		Timestamp: ts,
+		Count:     7,
+		Nodes: map[int64]*Node{
+			1: {Meta: Meta{ID: 1}},
+			2: {Meta: Meta{ID: 2}},
+			3: {Meta: Meta{ID: 3}},
+		},
+		Ways: map[int64]*Way{
+			10: {Meta: Meta{ID: 10}},
+			11: {Meta: Meta{ID: 11}},
+		},
+		Relations: map[int64]*Relation{20: {Meta: Meta{ID: 20}}},
+		Areas:     map[int64]*Area{3600000020: {Meta: Meta{ID: 3600000020}}},
+	}
+
+	nodes, ways, relations := result.SplitByType()
+
+	testCases := []struct {
+		name          string
+		result        Result
+		wantNodes     int
+		wantWays      int
+		wantRelations int
+	}{
+		{"nodes", nodes, 3, 0, 0},
+		{"ways", ways, 0, 2, 0},
+		{"relations", relations, 0, 0, 1},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			r := tc.result
+			if len(r.Nodes) != tc.wantNodes || len(r.Ways) != tc.wantWays || len(r.Relations) != tc.wantRelations {
+				t.Errorf("unexpected contents: %d nodes, %d ways, %d relations",
+					len(r.Nodes), len(r.Ways), len(r.Relations))
+			}
+
+			if r.Count != tc.wantNodes+tc.wantWays+tc.wantRelations {
+				t.Errorf("unexpected Count %d", r.Count)
+			}
+
+			if !r.Timestamp.Equal(ts) {
+				t.Errorf("expected shared timestamp, got %v", r.Timestamp)
+			}
+
+			if r.Areas != nil {
+				t.Errorf("expected no areas, got %v", r.Areas)
+			}
+		})
+	}
+
+	// Modifying a split result must not affect the original
+	onlyNodes, _, _ := result.SplitByType()
+	delete(onlyNodes.Nodes, 1)
+
+	if len(result.Nodes) != 3 {
+		t.Error("original result was modified")
+	}
+}
+
+func TestResultIsSkeletal(t *testing.T) {
+	t.Parallel()
+
+	idsOnly, err := unmarshal([]byte(`{"elements":[` +
+		`{"type":"node","id":1},` +
+		`{"type":"way","id":2,"nodes":[1,3]},` +
+		`{"type":"relation","id":4,"members":[{"type":"way","ref":2,"role":"outer"}]}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	full, err := unmarshal([]byte(`{"elements":[` +
+		`{"type":"node","id":1,"lat":52.5,"lon":13.4},` +
+		`{"type":"way","id":2,"nodes":[1]}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tagsOnly := Result{Ways: map[int64]*Way{2: {Meta: Meta{ID: 2, Tags: map[string]string{"highway": "primary"}}}}}
+
+	testCases := []struct {
+		name     string
+		result   Result
+		expected bool
+	}{
+		{"out ids", idsOnly, true},
+		{"full result", full, false},
+		{"out tags", tagsOnly, false},
+		{"empty", Result{}, true},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := tc.result.IsSkeletal(); got != tc.expected {
+				t.Errorf("expected %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
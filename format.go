@@ -0,0 +1,215 @@
+package overpass
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrMalformedQuery is returned by FormatQuery for unterminated strings,
+// comments or macros and for unbalanced brackets.
+var ErrMalformedQuery = errors.New("overpass: malformed query")
+
+// formatIndent is the indentation added per nested block.
+const formatIndent = "  "
+
+// FormatQuery pretty-prints an Overpass QL query: one statement per line,
+// union and difference blocks "( ... );" and "{ ... }" blocks indented by two
+// spaces, and runs of whitespace collapsed to a single space. String
+// literals, comments and Overpass Turbo {{macros}} are kept verbatim.
+// Formatting is idempotent, so the output is suitable as a stable cache key.
+func FormatQuery(query string) (string, error) {
+	f := &qlFormatter{src: query}
+
+	err := f.run()
+	if err != nil {
+		return "", err
+	}
+
+	return strings.Join(f.lines, "\n"), nil
+}
+
+// qlFormatter holds the state of a single FormatQuery run.
+type qlFormatter struct {
+	src          string
+	pos          int
+	lines        []string
+	line         strings.Builder
+	pendingSpace bool
+	depth        int
+	stack        []byte // 'b' block paren, 'p' inline paren, '[' bracket, '{' brace block
+}
+
+func (f *qlFormatter) run() error {
+	for f.pos < len(f.src) {
+		err := f.step()
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(f.stack) > 0 {
+		return fmt.Errorf("%w: unclosed bracket", ErrMalformedQuery)
+	}
+
+	f.flush()
+
+	return nil
+}
+
+// step consumes the next token of the source.
+func (f *qlFormatter) step() error {
+	c := f.src[f.pos]
+	rest := f.src[f.pos:]
+
+	switch {
+	case c == '"' || c == '\'':
+		return f.copyString(c)
+	case strings.HasPrefix(rest, "//"):
+		f.lineComment()
+	case strings.HasPrefix(rest, "/*"):
+		return f.copyUntil("*/", "comment")
+	case strings.HasPrefix(rest, "{{"):
+		return f.copyUntil("}}", "macro")
+	case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+		f.pendingSpace = true
+		f.pos++
+	case c == '(' && f.line.Len() == 0:
+		f.line.WriteByte('(')
+		f.openBlock('b')
+	case c == '{':
+		f.pendingSpace = true
+		f.write("{")
+		f.openBlock('{')
+	case c == '(':
+		f.write("(")
+		f.stack = append(f.stack, 'p')
+		f.pos++
+	case c == '[':
+		f.write("[")
+		f.stack = append(f.stack, '[')
+		f.pos++
+	case c == ')' || c == ']' || c == '}':
+		return f.close(c)
+	case c == ';':
+		f.write(";")
+		f.pos++
+
+		if len(f.stack) == 0 || f.stack[len(f.stack)-1] == 'b' || f.stack[len(f.stack)-1] == '{' {
+			f.flush()
+		}
+	default:
+		f.write(string(c))
+		f.pos++
+	}
+
+	return nil
+}
+
+// openBlock ends the current line with a block opener and indents.
+func (f *qlFormatter) openBlock(kind byte) {
+	f.flush()
+	f.stack = append(f.stack, kind)
+	f.depth++
+	f.pos++
+}
+
+// close handles a closing bracket, dedenting after blocks.
+func (f *qlFormatter) close(c byte) error {
+	expected := "bp" // parens close blocks and inline groups alike
+
+	switch c {
+	case ']':
+		expected = "["
+	case '}':
+		expected = "{"
+	}
+
+	if len(f.stack) == 0 || !strings.ContainsRune(expected, rune(f.stack[len(f.stack)-1])) {
+		return fmt.Errorf("%w: unexpected %q at offset %d", ErrMalformedQuery, c, f.pos)
+	}
+
+	kind := f.stack[len(f.stack)-1]
+	f.stack = f.stack[:len(f.stack)-1]
+	f.pos++
+
+	if kind != 'b' && kind != '{' {
+		f.write(string(c))
+		return nil
+	}
+
+	f.flush()
+	f.depth--
+	f.line.WriteByte(c)
+
+	if kind == '{' {
+		f.flush()
+	}
+
+	return nil
+}
+
+// copyString copies a quoted literal including backslash escapes.
+func (f *qlFormatter) copyString(quote byte) error {
+	for i := f.pos + 1; i < len(f.src); i++ {
+		switch f.src[i] {
+		case '\\':
+			i++
+		case quote:
+			f.write(f.src[f.pos : i+1])
+			f.pos = i + 1
+
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: unterminated string at offset %d", ErrMalformedQuery, f.pos)
+}
+
+// copyUntil copies a token verbatim up to and including end.
+func (f *qlFormatter) copyUntil(end, what string) error {
+	idx := strings.Index(f.src[f.pos+2:], end)
+	if idx < 0 {
+		return fmt.Errorf("%w: unterminated %s at offset %d", ErrMalformedQuery, what, f.pos)
+	}
+
+	stop := f.pos + 2 + idx + len(end)
+	f.write(f.src[f.pos:stop])
+	f.pos = stop
+
+	return nil
+}
+
+// lineComment copies a // comment and ends the line after it. Comments
+// after a statement land on their own line since ";" already ended it.
+func (f *qlFormatter) lineComment() {
+	end := strings.IndexByte(f.src[f.pos:], '\n')
+	if end < 0 {
+		end = len(f.src) - f.pos
+	}
+
+	f.pendingSpace = true
+	f.write(strings.TrimRight(f.src[f.pos:f.pos+end], " \t\r"))
+	f.pos += end
+	f.flush()
+}
+
+// write appends a token, separated by a single space after whitespace.
+func (f *qlFormatter) write(token string) {
+	if f.pendingSpace && f.line.Len() > 0 {
+		f.line.WriteByte(' ')
+	}
+
+	f.pendingSpace = false
+	f.line.WriteString(token)
+}
+
+// flush emits the current line at the current indentation.
+func (f *qlFormatter) flush() {
+	if f.line.Len() > 0 {
+		f.lines = append(f.lines, strings.Repeat(formatIndent, f.depth)+f.line.String())
+		f.line.Reset()
+	}
+
+	f.pendingSpace = false
+}
@@ -0,0 +1,96 @@
+package overpass
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestFormatQuery(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			"compact union",
+			`[out:json][timeout:25];(node["amenity"="cafe"](52.5,13.3,52.6,13.5);way["amenity"="cafe"](52.5,13.3,52.6,13.5););out body;`,
+			"[out:json][timeout:25];\n" +
+				"(\n" +
+				`  node["amenity"="cafe"](52.5,13.3,52.6,13.5);` + "\n" +
+				`  way["amenity"="cafe"](52.5,13.3,52.6,13.5);` + "\n" +
+				");\n" +
+				"out body;",
+		},
+		{
+			"nested union with set and recursion",
+			"(  way[highway];\n\n  ( node(1); >; ); )->.a;  .a out;",
+			"(\n  way[highway];\n  (\n    node(1);\n    >;\n  );\n)->.a;\n.a out;",
+		},
+		{
+			"strings keep whitespace and semicolons",
+			`node["name"="A;  (B)"]  ;out;`,
+			`node["name"="A;  (B)"] ;` + "\nout;",
+		},
+		{
+			"comments preserved",
+			"// cafes\nnode[amenity=cafe]; /* inline; (x) */ out; // done",
+			"// cafes\nnode[amenity=cafe];\n/* inline; (x) */ out;\n// done",
+		},
+		{
+			"braces and macros",
+			"{{geocodeArea:Berlin}}->.a;foreach.a{node(area);out;}",
+			"{{geocodeArea:Berlin}}->.a;\nforeach.a {\n  node(area);\n  out;\n}",
+		},
+		{
+			"filters with if stay inline",
+			"way(if: length() > 100)({{bbox}});out geom;",
+			"way(if: length() > 100)({{bbox}});\nout geom;",
+		},
+		{"empty", "  \n ", ""},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := FormatQuery(tc.input)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if got != tc.expected {
+				t.Fatalf("expected\n%s\ngot\n%s", tc.expected, got)
+			}
+
+			again, err := FormatQuery(got)
+			if err != nil {
+				t.Fatalf("unexpected error reformatting: %v", err)
+			}
+
+			if again != got {
+				t.Errorf("not idempotent:\n%s\nvs\n%s", got, again)
+			}
+		})
+	}
+}
+
+func TestFormatQueryMalformed(t *testing.T) {
+	t.Parallel()
+
+	for _, query := range []string{
+		`node["name"="open];`,
+		"node(1);/* open",
+		"(node(1);",
+		"node(1));",
+		"node[name);",
+		"node({{bbox);",
+	} {
+		if _, err := FormatQuery(query); !errors.Is(err, ErrMalformedQuery) {
+			t.Errorf("%q: expected ErrMalformedQuery, got %v", query, err)
+		}
+	}
+}
@@ -0,0 +1,303 @@
+package overpass
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// geoJSONFeature is a single GeoJSON Feature.
+type geoJSONFeature struct {
+	Type       string            `json:"type"`
+	ID         string            `json:"id,omitempty"`
+	Geometry   geoJSONGeometry   `json:"geometry"`
+	Properties map[string]string `json:"properties"`
+}
+
+// geoJSONGeometry is a GeoJSON geometry object.
+type geoJSONGeometry struct {
+	Type        string `json:"type"`
+	Coordinates any    `json:"coordinates"`
+}
+
+// GeoJSONOptions controls GeoJSON export.
+type GeoJSONOptions struct {
+	// Precision is the number of decimals coordinates are rounded to. Values
+	// below 1 use DefaultCoordinatePrecision.
+	Precision int
+}
+
+// precision returns the effective number of coordinate decimals.
+func (o GeoJSONOptions) precision() int {
+	if o.Precision < 1 {
+		return DefaultCoordinatePrecision
+	}
+
+	return o.Precision
+}
+
+// QueryGeoJSON runs the query and writes the result to w as a GeoJSON
+// FeatureCollection. Features are written one at a time and w is flushed after
+// each feature if it supports flushing (e.g. *bufio.Writer or http.ResponseWriter),
+// so large exports can be piped to files or HTTP responses.
+//
+// Nodes become Points, closed ways counter-clockwise Polygons, other ways
+// LineStrings and relations MultiLineStrings of their member ways (or their
+// center Point). Elements without coordinates are skipped.
+func (c *Client) QueryGeoJSON(ctx context.Context, query string, w io.Writer) error {
+	return c.QueryGeoJSONWith(ctx, query, w, GeoJSONOptions{})
+}
+
+// QueryGeoJSONWith is QueryGeoJSON with explicit export options, e.g. a
+// coordinate precision.
+func (c *Client) QueryGeoJSONWith(ctx context.Context, query string, w io.Writer, opts GeoJSONOptions) error {
+	result, err := c.QueryContext(ctx, query)
+	if err != nil {
+		return err
+	}
+
+	return writeGeoJSON(w, &result, opts.precision())
+}
+
+// writeGeoJSON streams the result as a FeatureCollection to w. Each element
+// is converted, marshaled and written in turn, ordered by type and id, so
+// only one feature is held in memory at a time.
+func writeGeoJSON(w io.Writer, result *Result, precision int) error {
+	_, err := io.WriteString(w, `{"type":"FeatureCollection","features":[`)
+	if err != nil {
+		return fmt.Errorf("geojson: %w", err)
+	}
+
+	fw := &featureWriter{w: w}
+
+	for _, id := range sortedKeys(result.Nodes) {
+		node := result.Nodes[id]
+		if node.hasLocation() {
+			err = fw.write(ElementTypeNode, node.Meta, geoJSONGeometry{
+				Type:        "Point",
+				Coordinates: lonLat(Point{Lat: node.Lat, Lon: node.Lon}, precision),
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, id := range sortedKeys(result.Ways) {
+		way := result.Ways[id]
+		if geometry, ok := way.geoJSONGeometry(precision); ok {
+			err = fw.write(ElementTypeWay, way.Meta, geometry)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, id := range sortedKeys(result.Relations) {
+		relation := result.Relations[id]
+		if geometry, ok := relation.geoJSONGeometry(precision); ok {
+			err = fw.write(ElementTypeRelation, relation.Meta, geometry)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	_, err = io.WriteString(w, "]}")
+	if err != nil {
+		return fmt.Errorf("geojson: %w", err)
+	}
+
+	return flushWriter(w)
+}
+
+// featureWriter writes comma-separated features of a FeatureCollection.
+type featureWriter struct {
+	w       io.Writer
+	written bool
+}
+
+// write marshals a single feature and writes it, flushing w afterwards.
+func (fw *featureWriter) write(elemType ElementType, meta Meta, geometry geoJSONGeometry) error {
+	properties := meta.Tags
+	if properties == nil {
+		properties = map[string]string{}
+	}
+
+	data, err := json.Marshal(geoJSONFeature{
+		Type:       "Feature",
+		ID:         elementKey(elemType, meta.ID),
+		Geometry:   geometry,
+		Properties: properties,
+	})
+	if err != nil {
+		return fmt.Errorf("geojson: %w", err)
+	}
+
+	if fw.written {
+		data = append([]byte{','}, data...)
+	}
+
+	fw.written = true
+
+	_, err = fw.w.Write(data)
+	if err != nil {
+		return fmt.Errorf("geojson: %w", err)
+	}
+
+	return flushWriter(fw.w)
+}
+
+// flushWriter flushes w if it supports flushing.
+func flushWriter(w io.Writer) error {
+	switch f := w.(type) {
+	case interface{ Flush() error }:
+		err := f.Flush()
+		if err != nil {
+			return fmt.Errorf("geojson: %w", err)
+		}
+	case http.Flusher:
+		f.Flush()
+	}
+
+	return nil
+}
+
+// geoJSONGeometry returns a Polygon only when the way's coordinates form a
+// closed ring; a closed way without coordinates (e.g. "out center") falls
+// back to its center Point.
+func (w *Way) geoJSONGeometry(precision int) (geoJSONGeometry, bool) {
+	path := w.path()
+
+	switch {
+	case isClosedPath(path):
+		return geoJSONGeometry{
+			Type:        "Polygon",
+			Coordinates: [][][2]float64{lonLatPath(orientRing(path, false), precision)},
+		}, true
+	case len(path) >= 2:
+		return geoJSONGeometry{Type: "LineString", Coordinates: lonLatPath(path, precision)}, true
+	case w.Center != nil:
+		return geoJSONGeometry{Type: "Point", Coordinates: lonLat(*w.Center, precision)}, true
+	default:
+		return geoJSONGeometry{}, false
+	}
+}
+
+func (r *Relation) geoJSONGeometry(precision int) (geoJSONGeometry, bool) {
+	var lines [][][2]float64
+
+	for _, member := range r.Members {
+		if member.Way == nil {
+			continue
+		}
+
+		if path := member.Way.path(); len(path) >= 2 {
+			lines = append(lines, lonLatPath(path, precision))
+		}
+	}
+
+	if len(lines) > 0 {
+		return geoJSONGeometry{Type: "MultiLineString", Coordinates: lines}, true
+	}
+
+	if p, ok := r.location(); ok {
+		return geoJSONGeometry{Type: "Point", Coordinates: lonLat(p, precision)}, true
+	}
+
+	return geoJSONGeometry{}, false
+}
+
+// lonLat returns a GeoJSON position (longitude first), rounded to precision
+// decimals.
+func lonLat(p Point, precision int) [2]float64 {
+	return [2]float64{
+		roundCoordinate(p.Lon, precision),
+		roundCoordinate(p.Lat, precision),
+	}
+}
+
+func lonLatPath(points []Point, precision int) [][2]float64 {
+	coords := make([][2]float64, len(points))
+	for i, p := range points {
+		coords[i] = lonLat(p, precision)
+	}
+
+	return coords
+}
+
+// CoverageGeoJSON merges all area features of the result into a single GeoJSON
+// MultiPolygon and returns it as a FeatureCollection with one feature. Ways
+// for which IsArea holds and whose coordinates form a closed ring, and
+// multipolygon relations contribute polygons (relation holes are kept); open
+// ways and nodes are excluded. Ways that are members of an included
+// multipolygon are not added twice. The collection is empty if the result
+// contains no areas.
+func (r *Result) CoverageGeoJSON() ([]byte, error) {
+	return r.CoverageGeoJSONWith(GeoJSONOptions{})
+}
+
+// CoverageGeoJSONWith is CoverageGeoJSON with explicit export options, e.g. a
+// coordinate precision.
+func (r *Result) CoverageGeoJSONWith(opts GeoJSONOptions) ([]byte, error) {
+	precision := opts.precision()
+
+	var polygons [][][][2]float64
+
+	memberWays := map[*Way]bool{}
+
+	for _, id := range sortedKeys(r.Relations) {
+		relation := r.Relations[id]
+		if relation.Tags["type"] != "multipolygon" {
+			continue
+		}
+
+		for _, member := range relation.Members {
+			if member.Way != nil {
+				memberWays[member.Way] = true
+			}
+		}
+
+		for _, polygon := range relation.Polygons() {
+			rings := [][][2]float64{lonLatPath(polygon.Outer, precision)}
+			for _, inner := range polygon.Inners {
+				rings = append(rings, lonLatPath(inner, precision))
+			}
+
+			polygons = append(polygons, rings)
+		}
+	}
+
+	for _, id := range sortedKeys(r.Ways) {
+		way := r.Ways[id]
+		if !way.IsArea() || memberWays[way] {
+			continue
+		}
+
+		// A way can be closed by its node references but lack coordinates
+		if path := way.path(); isClosedPath(path) {
+			polygons = append(polygons, [][][2]float64{lonLatPath(orientRing(path, false), precision)})
+		}
+	}
+
+	features := []geoJSONFeature{}
+	if len(polygons) > 0 {
+		features = append(features, geoJSONFeature{
+			Type:       "Feature",
+			Geometry:   geoJSONGeometry{Type: "MultiPolygon", Coordinates: polygons},
+			Properties: map[string]string{},
+		})
+	}
+
+	data, err := json.Marshal(struct {
+		Type     string           `json:"type"`
+		Features []geoJSONFeature `json:"features"`
+	}{"FeatureCollection", features})
+	if err != nil {
+		return nil, fmt.Errorf("geojson: %w", err)
+	}
+
+	return data, nil
+}
@@ -0,0 +1,331 @@
+package overpass
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type flushCountingWriter struct {
+	bytes.Buffer
+	flushes int
+}
+
+func (w *flushCountingWriter) Flush() error {
+	w.flushes++
+
+	return nil
+}
+
+func TestQueryGeoJSON(t *testing.T) {
+	t.Parallel()
+
+	body := `{"elements":[
+		{"type":"node","id":1,"lat":1.5,"lon":2.5,"tags":{"amenity":"cafe"}},
+		{"type":"node","id":2,"lat":1,"lon":1},
+		{"type":"node","id":3,"lat":1,"lon":2},
+		{"type":"node","id":4,"lat":2,"lon":2},
+		{"type":"node","id":5},
+		{"type":"way","id":10,"nodes":[2,3,4,2],"tags":{"building":"yes"}},
+		{"type":"way","id":11,"nodes":[2,3],"tags":{"highway":"path"}},
+		{"type":"relation","id":20,"members":[{"type":"way","ref":11,"role":""}],"tags":{"type":"route"}}
+	]}`
+
+	client := NewWithSettings(apiEndpoint, 1, &mockRecordingHTTPClient{bodies: []string{body}})
+
+	var buf flushCountingWriter
+
+	err := client.QueryGeoJSON(context.Background(), "[out:json];nwr(1);out;", &buf)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var collection struct {
+		Type     string `json:"type"`
+		Features []struct {
+			Type     string `json:"type"`
+			ID       string `json:"id"`
+			Geometry struct {
+				Type        string          `json:"type"`
+				Coordinates json.RawMessage `json:"coordinates"`
+			} `json:"geometry"`
+			Properties map[string]string `json:"properties"`
+		} `json:"features"`
+	}
+
+	if err := json.Unmarshal(buf.Bytes(), &collection); err != nil {
+		t.Fatalf("invalid JSON output: %v\n%s", err, buf.String())
+	}
+
+	if collection.Type != "FeatureCollection" {
+		t.Errorf("expected FeatureCollection, got %q", collection.Type)
+	}
+
+	expected := []struct{ id, geometry string }{
+		{"node/1", "Point"},
+		{"node/2", "Point"},
+		{"node/3", "Point"},
+		{"node/4", "Point"},
+		{"way/10", "Polygon"},
+		{"way/11", "LineString"},
+		{"relation/20", "MultiLineString"},
+	}
+
+	if len(collection.Features) != len(expected) {
+		t.Fatalf("expected %d features, got %d", len(expected), len(collection.Features))
+	}
+
+	for i, want := range expected {
+		feature := collection.Features[i]
+		if feature.ID != want.id || feature.Geometry.Type != want.geometry {
+			t.Errorf("feature %d: expected %s %s, got %s %s",
+				i, want.id, want.geometry, feature.ID, feature.Geometry.Type)
+		}
+	}
+
+	if coords := string(collection.Features[0].Geometry.Coordinates); coords != "[2.5,1.5]" {
+		t.Errorf("expected lon/lat order, got %s", coords)
+	}
+
+	if collection.Features[0].Properties["amenity"] != "cafe" {
+		t.Errorf("expected tags as properties, got %v", collection.Features[0].Properties)
+	}
+
+	// One flush per feature plus the footer
+	if buf.flushes != len(expected)+1 {
+		t.Errorf("expected %d flushes, got %d", len(expected)+1, buf.flushes)
+	}
+}
+
+func TestWayGeoJSONGeometryCenterOnly(t *testing.T) {
+	t.Parallel()
+
+	// A closed way from "out center;": node references but no coordinates
+	result, err := unmarshal([]byte(`{"elements":[
+		{"type":"way","id":10,"nodes":[1,2,3,1],"center":{"lat":1.5,"lon":2.5},"tags":{"building":"yes"}}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	way := result.Ways[10]
+	if !way.IsClosed() {
+		t.Fatal("expected a closed way")
+	}
+
+	geometry, ok := way.geoJSONGeometry(DefaultCoordinatePrecision)
+	if !ok || geometry.Type != "Point" {
+		t.Fatalf("expected center Point, got %+v", geometry)
+	}
+
+	if coords := geometry.Coordinates.([2]float64); coords != [2]float64{2.5, 1.5} {
+		t.Errorf("expected center coordinates, got %v", coords)
+	}
+}
+
+func TestQueryGeoJSONPrecision(t *testing.T) {
+	t.Parallel()
+
+	body := `{"elements":[{"type":"node","id":1,"lat":52.123456789,"lon":13.987654321}]}`
+
+	testCases := []struct {
+		name     string
+		opts     GeoJSONOptions
+		expected string
+	}{
+		{"default", GeoJSONOptions{}, "[13.9876543,52.1234568]"},
+		{"custom", GeoJSONOptions{Precision: 3}, "[13.988,52.123]"},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			client := NewWithSettings(apiEndpoint, 1, &mockRecordingHTTPClient{bodies: []string{body}})
+
+			var buf bytes.Buffer
+
+			err := client.QueryGeoJSONWith(context.Background(), "[out:json];node(1);out;", &buf, tc.opts)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !strings.Contains(buf.String(), `"coordinates":`+tc.expected) {
+				t.Errorf("expected coordinates %s, got %s", tc.expected, buf.String())
+			}
+		})
+	}
+}
+
+func TestQueryGeoJSONEmpty(t *testing.T) {
+	t.Parallel()
+
+	client := NewWithSettings(apiEndpoint, 1, &mockRecordingHTTPClient{})
+
+	var buf bytes.Buffer
+
+	w := bufio.NewWriter(&buf)
+
+	err := client.QueryGeoJSON(context.Background(), "[out:json];node(1);out;", w)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := buf.String(); got != `{"type":"FeatureCollection","features":[]}` {
+		t.Errorf("unexpected output: %s", got)
+	}
+}
+
+func TestQueryGeoJSONError(t *testing.T) {
+	t.Parallel()
+
+	client := New()
+
+	var buf bytes.Buffer
+
+	err := client.QueryGeoJSON(context.Background(), "", &buf)
+	if !errors.Is(err, ErrEmptyQuery) {
+		t.Fatalf("expected ErrEmptyQuery, got %v", err)
+	}
+
+	if buf.Len() != 0 {
+		t.Errorf("nothing should be written on query failure, got %s", buf.String())
+	}
+}
+
+func TestCoverageGeoJSON(t *testing.T) {
+	t.Parallel()
+
+	building := func(id int64, lat, lon float64) *Way {
+		return &Way{
+			Meta: Meta{ID: id, Tags: map[string]string{"building": "yes"}},
+			Geometry: []Point{
+				{lat, lon}, {lat, lon + 1}, {lat + 1, lon + 1}, {lat + 1, lon}, {lat, lon},
+			},
+		}
+	}
+
+	result := Result{
+		Nodes: map[int64]*Node{1: {Meta: Meta{ID: 1}, Lat: 5, Lon: 5}},
+		Ways: map[int64]*Way{
+			10: building(10, 0, 0),
+			11: building(11, 2, 2),
+			12: {Meta: Meta{ID: 12}, Geometry: []Point{{0, 0}, {3, 3}}},
+		},
+	}
+
+	data, err := result.CoverageGeoJSON()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var collection struct {
+		Type     string `json:"type"`
+		Features []struct {
+			Geometry struct {
+				Type        string           `json:"type"`
+				Coordinates [][][][2]float64 `json:"coordinates"`
+			} `json:"geometry"`
+		} `json:"features"`
+	}
+
+	if err := json.Unmarshal(data, &collection); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, data)
+	}
+
+	if collection.Type != "FeatureCollection" || len(collection.Features) != 1 {
+		t.Fatalf("expected collection with one feature, got %s", data)
+	}
+
+	geometry := collection.Features[0].Geometry
+	if geometry.Type != "MultiPolygon" {
+		t.Errorf("expected MultiPolygon, got %s", geometry.Type)
+	}
+
+	if len(geometry.Coordinates) != 2 {
+		t.Fatalf("expected 2 polygons, got %d", len(geometry.Coordinates))
+	}
+
+	if first := geometry.Coordinates[0][0][1]; first != [2]float64{1, 0} {
+		t.Errorf("expected lon/lat ordering, got %v", first)
+	}
+
+	precise := Result{Ways: map[int64]*Way{10: building(10, 0.123456, 0.654321)}}
+
+	data, err = precise.CoverageGeoJSONWith(GeoJSONOptions{Precision: 2})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !bytes.Contains(data, []byte("[0.65,0.12]")) {
+		t.Errorf("expected coordinates rounded to 2 decimals, got %s", data)
+	}
+}
+
+func TestCoverageGeoJSONMultipolygon(t *testing.T) {
+	t.Parallel()
+
+	outer := &Way{Meta: Meta{ID: 10}, Geometry: []Point{{0, 0}, {0, 10}, {10, 10}, {10, 0}, {0, 0}}}
+	inner := &Way{Meta: Meta{ID: 11}, Geometry: []Point{{4, 4}, {4, 6}, {6, 6}, {6, 4}, {4, 4}}}
+
+	result := Result{
+		Ways: map[int64]*Way{10: outer, 11: inner},
+		Relations: map[int64]*Relation{
+			20: {
+				Meta: Meta{ID: 20, Tags: map[string]string{"type": "multipolygon", "landuse": "forest"}},
+				Members: []RelationMember{
+					{Type: ElementTypeWay, Role: "outer", Way: outer},
+					{Type: ElementTypeWay, Role: "inner", Way: inner},
+				},
+			},
+		},
+	}
+
+	data, err := result.CoverageGeoJSON()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var collection struct {
+		Features []struct {
+			Geometry struct {
+				Coordinates [][][][2]float64 `json:"coordinates"`
+			} `json:"geometry"`
+		} `json:"features"`
+	}
+
+	if err := json.Unmarshal(data, &collection); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+
+	polygons := collection.Features[0].Geometry.Coordinates
+	if len(polygons) != 1 || len(polygons[0]) != 2 {
+		t.Errorf("expected one polygon with a hole (member ways not duplicated), got %v", polygons)
+	}
+
+	// Closed by its node references, but the nodes carry no coordinates
+	unlocated := []*Node{{Meta: Meta{ID: 1}}, {Meta: Meta{ID: 2}}, {Meta: Meta{ID: 3}}}
+	closedWithoutCoords := &Way{
+		Meta:  Meta{ID: 12, Tags: map[string]string{"building": "yes"}},
+		Nodes: []*Node{unlocated[0], unlocated[1], unlocated[2], unlocated[0]},
+	}
+
+	empty := Result{
+		Nodes: map[int64]*Node{1: {Lat: 1, Lon: 1}},
+		Ways:  map[int64]*Way{12: closedWithoutCoords},
+	}
+
+	data, err = empty.CoverageGeoJSON()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if string(data) != `{"type":"FeatureCollection","features":[]}` {
+		t.Errorf("expected empty collection, got %s", data)
+	}
+}
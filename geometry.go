@@ -0,0 +1,438 @@
+package overpass
+
+import (
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// earthRadius is the mean Earth radius in meters.
+const earthRadius = 6371000.0
+
+// DefaultCoordinatePrecision is the number of decimals coordinates are written
+// with in queries and exports. Seven decimals of a degree resolve about 1 cm.
+const DefaultCoordinatePrecision = 7
+
+// FormatCoordinate formats a latitude or longitude rounded to precision
+// decimals, dropping trailing zeros (52.5 rather than 52.5000000).
+func FormatCoordinate(v float64, precision int) string {
+	s := strconv.FormatFloat(v, 'f', precision, 64)
+	if strings.Contains(s, ".") {
+		s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	}
+
+	if s == "-0" {
+		return "0"
+	}
+
+	return s
+}
+
+// IsValid reports whether p lies within [-90, 90] latitude and [-180, 180]
+// longitude. NaN coordinates are invalid.
+func (p Point) IsValid() bool {
+	return p.Lat >= -90 && p.Lat <= 90 && p.Lon >= -180 && p.Lon <= 180
+}
+
+// roundCoordinate rounds v to precision decimals.
+func roundCoordinate(v float64, precision int) float64 {
+	scale := math.Pow10(precision)
+
+	return math.Round(v*scale) / scale
+}
+
+// DuplicateCoordinates finds stacked nodes: it maps every coordinate shared by
+// more than one located node to their ids in ascending order. Coordinates are
+// rounded to precision decimals first, so near-identical positions count as
+// duplicates (values below 1 use DefaultCoordinatePrecision, about 1 cm).
+func (r *Result) DuplicateCoordinates(precision int) map[Point][]int64 {
+	if precision < 1 {
+		precision = DefaultCoordinatePrecision
+	}
+
+	byPoint := map[Point][]int64{}
+
+	for _, id := range sortedKeys(r.Nodes) {
+		node := r.Nodes[id]
+		if !node.hasLocation() {
+			continue
+		}
+
+		p := Point{Lat: roundCoordinate(node.Lat, precision), Lon: roundCoordinate(node.Lon, precision)}
+		byPoint[p] = append(byPoint[p], id)
+	}
+
+	for p, ids := range byPoint {
+		if len(ids) < 2 {
+			delete(byPoint, p)
+		}
+	}
+
+	return byPoint
+}
+
+// LabeledPoint is a single representative location for an element.
+type LabeledPoint struct {
+	ID    int64
+	Type  ElementType
+	Point Point
+	Name  string
+}
+
+// Points returns one location per element, e.g. for plotting POIs on a map.
+// Nodes use their coordinates, ways and relations their Center (from "out center")
+// or Centroid. Elements without a resolvable location are skipped.
+// The slice is ordered by type (nodes, ways, relations) and then by id.
+func (r *Result) Points() []LabeledPoint {
+	points := make([]LabeledPoint, 0, len(r.Nodes)+len(r.Ways)+len(r.Relations))
+
+	for _, id := range sortedKeys(r.Nodes) {
+		node := r.Nodes[id]
+		if node.hasLocation() {
+			points = append(points, LabeledPoint{
+				ID: id, Type: ElementTypeNode, Point: Point{Lat: node.Lat, Lon: node.Lon}, Name: node.GetName(),
+			})
+		}
+	}
+
+	for _, id := range sortedKeys(r.Ways) {
+		way := r.Ways[id]
+		if p, ok := way.location(); ok {
+			points = append(points, LabeledPoint{ID: id, Type: ElementTypeWay, Point: p, Name: way.GetName()})
+		}
+	}
+
+	for _, id := range sortedKeys(r.Relations) {
+		relation := r.Relations[id]
+		if p, ok := relation.location(); ok {
+			points = append(points, LabeledPoint{ID: id, Type: ElementTypeRelation, Point: p, Name: relation.GetName()})
+		}
+	}
+
+	return points
+}
+
+// Centroid returns the average of the way's vertices, using Geometry when
+// present and the coordinates of located nodes otherwise. The closing vertex
+// of a closed way is counted once, and a single segment yields its
+// great-circle Midpoint. Out-of-range vertices are ignored. It returns false
+// if no valid coordinates are known.
+func (w *Way) Centroid() (Point, bool) {
+	path := w.path()
+	if len(path) > 1 && path[0] == path[len(path)-1] {
+		path = path[:len(path)-1]
+	}
+
+	if len(path) == 2 {
+		return Midpoint(path[0], path[1]), true
+	}
+
+	return averagePoint(path)
+}
+
+// Centroid returns the average of all member way vertices and member node
+// coordinates. It returns false if no member carries coordinates.
+func (r *Relation) Centroid() (Point, bool) {
+	var points []Point
+
+	for _, member := range r.Members {
+		switch {
+		case member.Node != nil && member.Node.hasLocation():
+			points = append(points, Point{Lat: member.Node.Lat, Lon: member.Node.Lon})
+		case member.Way != nil:
+			points = append(points, member.Way.path()...)
+		}
+	}
+
+	return averagePoint(points)
+}
+
+// Nearest returns the element closest to p and its great-circle distance in
+// meters. Nodes use their coordinates, ways and relations their center or
+// centroid. It returns false if the result has no located elements.
+func (r *Result) Nearest(p Point) (Element, float64, bool) {
+	var (
+		nearest Element
+		best    = math.Inf(1)
+		found   bool
+	)
+
+	for _, element := range r.Elements() {
+		loc, ok := element.location()
+		if !ok {
+			continue
+		}
+
+		if d := haversine(p, loc); d < best {
+			nearest, best, found = element, d, true
+		}
+	}
+
+	if !found {
+		return Element{}, 0, false
+	}
+
+	return nearest, best, true
+}
+
+// location returns the representative location of the wrapped element.
+func (e Element) location() (Point, bool) {
+	switch {
+	case e.Node != nil:
+		return Point{Lat: e.Node.Lat, Lon: e.Node.Lon}, e.Node.hasLocation()
+	case e.Way != nil:
+		return e.Way.location()
+	case e.Relation != nil:
+		return e.Relation.location()
+	default:
+		return Point{}, false
+	}
+}
+
+// haversine returns the great-circle distance between a and b in meters.
+func haversine(a, b Point) float64 {
+	lat1 := a.Lat * math.Pi / 180
+	lat2 := b.Lat * math.Pi / 180
+	dLat := lat2 - lat1
+	dLon := (b.Lon - a.Lon) * math.Pi / 180
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+
+	return 2 * earthRadius * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+// Midpoint returns the point halfway along the great circle from a to b.
+// Unlike the plain average of the coordinates it stays on the shortest path
+// for long segments, near the poles and across the antimeridian.
+func Midpoint(a, b Point) Point {
+	lat1 := a.Lat * math.Pi / 180
+	lat2 := b.Lat * math.Pi / 180
+	lon1 := a.Lon * math.Pi / 180
+	dLon := (b.Lon - a.Lon) * math.Pi / 180
+
+	bx := math.Cos(lat2) * math.Cos(dLon)
+	by := math.Cos(lat2) * math.Sin(dLon)
+
+	lat := math.Atan2(math.Sin(lat1)+math.Sin(lat2), math.Hypot(math.Cos(lat1)+bx, by))
+	lon := lon1 + math.Atan2(by, math.Cos(lat1)+bx)
+
+	// Normalize to [-180, 180)
+	lonDeg := math.Mod(lon*180/math.Pi+540, 360) - 180
+
+	return Point{Lat: lat * 180 / math.Pi, Lon: lonDeg}
+}
+
+// MemberGeometry returns the coordinate paths of the relation's member ways with
+// the given role, in member order. An empty role matches any member. Members
+// without geometry (nodes, unresolved ways) are skipped.
+func (r *Relation) MemberGeometry(role string) [][]Point {
+	var paths [][]Point
+
+	for _, member := range r.Members {
+		if member.Way == nil || (role != "" && member.Role != role) {
+			continue
+		}
+
+		if path := member.Way.path(); len(path) > 0 {
+			paths = append(paths, path)
+		}
+	}
+
+	return paths
+}
+
+// IsClosed reports whether the way forms a ring: at least four vertices with
+// the first equal to the last.
+func (w *Way) IsClosed() bool {
+	if len(w.Nodes) >= 4 {
+		return w.Nodes[0] != nil && w.Nodes[0] == w.Nodes[len(w.Nodes)-1]
+	}
+
+	return isClosedPath(w.path())
+}
+
+// IsArea reports whether the way describes an area rather than a closed line:
+// it must be closed and its tags must satisfy ClosedWayIsArea.
+func (w *Way) IsArea() bool {
+	return w.IsClosed() && ClosedWayIsArea(w.Tags)
+}
+
+// ClosedWayIsArea reports whether a closed way with the given tags describes
+// an area: it must not be tagged area=no, and closed highways and barriers
+// (e.g. roundabouts, fences) only count as areas when tagged area=yes.
+func ClosedWayIsArea(tags map[string]string) bool {
+	switch tags["area"] {
+	case "yes":
+		return true
+	case "no":
+		return false
+	}
+
+	_, highway := tags["highway"]
+	_, barrier := tags["barrier"]
+
+	return !highway && !barrier
+}
+
+// location returns the way's Center if present, otherwise its Centroid.
+func (w *Way) location() (Point, bool) {
+	if w.Center != nil {
+		return *w.Center, true
+	}
+
+	return w.Centroid()
+}
+
+// location returns the relation's Center if present, otherwise its Centroid.
+func (r *Relation) location() (Point, bool) {
+	if r.Center != nil {
+		return *r.Center, true
+	}
+
+	return r.Centroid()
+}
+
+// path returns the way's coordinates from Geometry, or from its located nodes.
+// Points outside the valid coordinate range are skipped.
+func (w *Way) path() []Point {
+	if len(w.Geometry) > 0 {
+		if !slices.ContainsFunc(w.Geometry, func(p Point) bool { return !p.IsValid() }) {
+			return w.Geometry
+		}
+
+		return slices.DeleteFunc(slices.Clone(w.Geometry), func(p Point) bool { return !p.IsValid() })
+	}
+
+	points := make([]Point, 0, len(w.Nodes))
+
+	for _, node := range w.Nodes {
+		if node == nil || !node.hasLocation() {
+			continue
+		}
+
+		if p := (Point{Lat: node.Lat, Lon: node.Lon}); p.IsValid() {
+			points = append(points, p)
+		}
+	}
+
+	return points
+}
+
+// hasLocation reports whether the node carries coordinates.
+func (n *Node) hasLocation() bool {
+	return n.Lat != 0 || n.Lon != 0
+}
+
+func averagePoint(points []Point) (Point, bool) {
+	if len(points) == 0 {
+		return Point{}, false
+	}
+
+	var sum Point
+	for _, p := range points {
+		sum.Lat += p.Lat
+		sum.Lon += p.Lon
+	}
+
+	n := float64(len(points))
+
+	return Point{Lat: sum.Lat / n, Lon: sum.Lon / n}, true
+}
+
+// sortedKeys returns the ids of an element map in ascending order.
+func sortedKeys[T any](m map[int64]T) []int64 {
+	keys := make([]int64, 0, len(m))
+	for id := range m {
+		keys = append(keys, id)
+	}
+
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+
+	return keys
+}
+
+// ConvexHull returns the convex hull of all node coordinates and way geometry
+// points in the result as a closed, counter-clockwise ring (the first point is
+// repeated at the end). It returns an empty slice when fewer than three
+// non-collinear points are available.
+func (r *Result) ConvexHull() []Point {
+	points := r.collectPoints()
+	if len(points) < 3 {
+		return []Point{}
+	}
+
+	// Sort by longitude, then latitude (x, y) and drop duplicates
+	sort.Slice(points, func(i, j int) bool {
+		if points[i].Lon != points[j].Lon {
+			return points[i].Lon < points[j].Lon
+		}
+
+		return points[i].Lat < points[j].Lat
+	})
+
+	unique := points[:1]
+	for _, p := range points[1:] {
+		if p != unique[len(unique)-1] {
+			unique = append(unique, p)
+		}
+	}
+
+	if len(unique) < 3 {
+		return []Point{}
+	}
+
+	// Andrew's monotone chain: build lower and upper hulls
+	hull := make([]Point, 0, 2*len(unique))
+
+	for _, p := range unique {
+		for len(hull) >= 2 && cross(hull[len(hull)-2], hull[len(hull)-1], p) <= 0 {
+			hull = hull[:len(hull)-1]
+		}
+
+		hull = append(hull, p)
+	}
+
+	lowerLen := len(hull) + 1
+	for i := len(unique) - 2; i >= 0; i-- {
+		p := unique[i]
+		for len(hull) >= lowerLen && cross(hull[len(hull)-2], hull[len(hull)-1], p) <= 0 {
+			hull = hull[:len(hull)-1]
+		}
+
+		hull = append(hull, p)
+	}
+
+	// The last point equals the first, so the ring is already closed.
+	// A degenerate (collinear) hull has only two distinct points.
+	if len(hull) < 4 {
+		return []Point{}
+	}
+
+	return hull
+}
+
+// collectPoints gathers the coordinates of located nodes and way geometries.
+func (r *Result) collectPoints() []Point {
+	points := make([]Point, 0, len(r.Nodes))
+
+	for _, node := range r.Nodes {
+		if node.hasLocation() {
+			points = append(points, Point{Lat: node.Lat, Lon: node.Lon})
+		}
+	}
+
+	for _, way := range r.Ways {
+		points = append(points, way.Geometry...)
+	}
+
+	return points
+}
+
+// cross returns the z-component of the cross product of vectors o->a and o->b
+// using longitude as x and latitude as y. Positive means a counter-clockwise turn.
+func cross(o, a, b Point) float64 {
+	return (a.Lon-o.Lon)*(b.Lat-o.Lat) - (a.Lat-o.Lat)*(b.Lon-o.Lon)
+}
@@ -0,0 +1,453 @@
+package overpass
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestConvexHullQuad(t *testing.T) {
+	t.Parallel()
+
+	result := Result{
+		Nodes: map[int64]*Node{
+			1: {Meta: Meta{ID: 1}, Lat: 0, Lon: 1},
+			2: {Meta: Meta{ID: 2}, Lat: 1, Lon: 1},
+			3: {Meta: Meta{ID: 3}, Lat: 0.5, Lon: 1.5}, // interior
+		},
+		Ways: map[int64]*Way{
+			10: {Meta: Meta{ID: 10}, Geometry: []Point{{0, 2}, {1, 2}, {0.2, 1.8}}},
+		},
+	}
+
+	hull := result.ConvexHull()
+
+	expected := []Point{{0, 1}, {0, 2}, {1, 2}, {1, 1}, {0, 1}}
+	if len(hull) != len(expected) {
+		t.Fatalf("expected %d hull points, got %d: %v", len(expected), len(hull), hull)
+	}
+
+	for i := range expected {
+		if hull[i] != expected[i] {
+			t.Errorf("point %d: expected %v, got %v", i, expected[i], hull[i])
+		}
+	}
+}
+
+func TestConvexHullTriangle(t *testing.T) {
+	t.Parallel()
+
+	result := Result{
+		Nodes: map[int64]*Node{
+			1: {Lat: 1, Lon: 1},
+			2: {Lat: 1, Lon: 3},
+			3: {Lat: 3, Lon: 2},
+			4: {Lat: 1.5, Lon: 2},  // interior
+			5: {Lat: 1, Lon: 2},    // on edge
+			6: {Lat: 1, Lon: 1},    // duplicate
+			7: {Meta: Meta{ID: 7}}, // unlocated placeholder
+		},
+	}
+
+	hull := result.ConvexHull()
+	if len(hull) != 4 {
+		t.Fatalf("expected closed triangle with 4 points, got %v", hull)
+	}
+
+	if hull[0] != hull[len(hull)-1] {
+		t.Error("hull should be closed")
+	}
+}
+
+func TestConvexHullDegenerate(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name  string
+		nodes map[int64]*Node
+	}{
+		{"empty", map[int64]*Node{}},
+		{"two points", map[int64]*Node{1: {Lat: 1, Lon: 1}, 2: {Lat: 2, Lon: 2}}},
+		{"duplicates", map[int64]*Node{1: {Lat: 1, Lon: 1}, 2: {Lat: 1, Lon: 1}, 3: {Lat: 2, Lon: 2}}},
+		{"collinear", map[int64]*Node{1: {Lat: 1, Lon: 1}, 2: {Lat: 2, Lon: 2}, 3: {Lat: 3, Lon: 3}}},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			result := Result{Nodes: tc.nodes}
+
+			hull := result.ConvexHull()
+			if hull == nil || len(hull) != 0 {
+				t.Errorf("expected empty non-nil hull, got %v", hull)
+			}
+		})
+	}
+}
+
+func TestResultPoints(t *testing.T) {
+	t.Parallel()
+
+	result := Result{
+		Nodes: map[int64]*Node{
+			1: {Meta: Meta{ID: 1, Tags: map[string]string{"name": "Cafe"}}, Lat: 52.5, Lon: 13.4},
+			2: {Meta: Meta{ID: 2}}, // referenced only, no coordinates
+		},
+		Ways: map[int64]*Way{
+			10: {Meta: Meta{ID: 10, Tags: map[string]string{"name": "Park"}}, Center: &Point{Lat: 52.6, Lon: 13.5}},
+			11: {Meta: Meta{ID: 11}, Geometry: []Point{{0, 0}, {0, 2}, {2, 2}, {2, 0}, {0, 0}}},
+			12: {Meta: Meta{ID: 12}},
+		},
+		Relations: map[int64]*Relation{},
+	}
+
+	points := result.Points()
+	if len(points) != 3 {
+		t.Fatalf("expected 3 points, got %d: %+v", len(points), points)
+	}
+
+	expected := []LabeledPoint{
+		{ID: 1, Type: ElementTypeNode, Point: Point{52.5, 13.4}, Name: "Cafe"},
+		{ID: 10, Type: ElementTypeWay, Point: Point{52.6, 13.5}, Name: "Park"},
+		{ID: 11, Type: ElementTypeWay, Point: Point{1, 1}},
+	}
+
+	for i := range expected {
+		if points[i] != expected[i] {
+			t.Errorf("point %d: expected %+v, got %+v", i, expected[i], points[i])
+		}
+	}
+}
+
+func TestWayCentroidFromNodes(t *testing.T) {
+	t.Parallel()
+
+	way := Way{Nodes: []*Node{{Lat: 1, Lon: 1}, {Lat: 3, Lon: 5}, {Meta: Meta{ID: 9}}}}
+
+	centroid, ok := way.Centroid()
+	if !ok {
+		t.Fatal("expected centroid")
+	}
+
+	// A single segment uses the great-circle midpoint, close to {2 3} here
+	if centroid != Midpoint(Point{1, 1}, Point{3, 5}) || math.Abs(centroid.Lat-2) > 0.01 || math.Abs(centroid.Lon-3) > 0.01 {
+		t.Errorf("expected midpoint near {2 3}, got %v", centroid)
+	}
+
+	if _, ok := (&Way{}).Centroid(); ok {
+		t.Error("expected no centroid for empty way")
+	}
+}
+
+func TestRelationCentroid(t *testing.T) {
+	t.Parallel()
+
+	relation := Relation{
+		Members: []RelationMember{
+			{Type: ElementTypeWay, Way: &Way{Geometry: []Point{{0, 0}, {0, 4}}}},
+			{Type: ElementTypeNode, Node: &Node{Lat: 3, Lon: 2}},
+		},
+	}
+
+	centroid, ok := relation.Centroid()
+	if !ok {
+		t.Fatal("expected centroid")
+	}
+
+	if centroid != (Point{1, 2}) {
+		t.Errorf("expected {1 2}, got %v", centroid)
+	}
+}
+
+func TestWayIsClosed(t *testing.T) {
+	t.Parallel()
+
+	a := &Node{Meta: Meta{ID: 1}, Lat: 0, Lon: 0}
+	b := &Node{Meta: Meta{ID: 2}, Lat: 0, Lon: 1}
+	c := &Node{Meta: Meta{ID: 3}, Lat: 1, Lon: 1}
+
+	testCases := []struct {
+		name     string
+		way      Way
+		expected bool
+	}{
+		{"closed nodes", Way{Nodes: []*Node{a, b, c, a}}, true},
+		{"open nodes", Way{Nodes: []*Node{a, b, c}}, false},
+		{"closed geometry", Way{Geometry: []Point{{0, 0}, {0, 1}, {1, 1}, {0, 0}}}, true},
+		{"too short", Way{Geometry: []Point{{0, 0}, {0, 1}, {0, 0}}}, false},
+		{"empty", Way{}, false},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := tc.way.IsClosed(); got != tc.expected {
+				t.Errorf("expected %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestRelationMemberGeometry(t *testing.T) {
+	t.Parallel()
+
+	body := []byte(`{"elements":[{"type":"relation","id":1,"members":[
+		{"type":"way","ref":10,"role":"outer","geometry":[{"lat":0,"lon":0},{"lat":0,"lon":1}]},
+		{"type":"node","ref":5,"role":"stop"},
+		{"type":"way","ref":11,"role":"outer","geometry":[{"lat":0,"lon":1},{"lat":1,"lon":1}]},
+		{"type":"way","ref":12,"role":"inner"}
+	]}]}`)
+
+	result, err := unmarshal(body)
+	if err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+
+	relation := result.Relations[1]
+
+	outer := relation.MemberGeometry("outer")
+	if len(outer) != 2 {
+		t.Fatalf("expected 2 outer paths, got %d", len(outer))
+	}
+
+	if outer[0][0] != (Point{0, 0}) || outer[1][1] != (Point{1, 1}) {
+		t.Errorf("unexpected paths: %v", outer)
+	}
+
+	if paths := relation.MemberGeometry(""); len(paths) != 2 {
+		t.Errorf("expected 2 paths for any role, got %d", len(paths))
+	}
+
+	if paths := relation.MemberGeometry("inner"); len(paths) != 0 {
+		t.Errorf("expected no paths for inner role without geometry, got %v", paths)
+	}
+}
+
+func TestResultNearest(t *testing.T) {
+	t.Parallel()
+
+	result := Result{
+		Nodes: map[int64]*Node{
+			1: {Meta: Meta{ID: 1}, Lat: 52.5200, Lon: 13.4050},
+			2: {Meta: Meta{ID: 2}, Lat: 52.5210, Lon: 13.4050},
+			3: {Meta: Meta{ID: 3}, Lat: 48.1372, Lon: 11.5756},
+			4: {Meta: Meta{ID: 4}}, // unlocated placeholder
+		},
+		Ways: map[int64]*Way{
+			10: {Meta: Meta{ID: 10}, Center: &Point{Lat: 52.5300, Lon: 13.4050}},
+		},
+	}
+
+	element, dist, ok := result.Nearest(Point{Lat: 52.5211, Lon: 13.4050})
+	if !ok {
+		t.Fatal("expected a nearest element")
+	}
+
+	if element.Node == nil || element.Node.ID != 2 {
+		t.Fatalf("expected node 2, got %+v", element)
+	}
+
+	// 0.0001° of latitude is about 11.1 m
+	if math.Abs(dist-11.12) > 0.05 {
+		t.Errorf("expected distance ~11.12 m, got %.3f", dist)
+	}
+
+	element, _, ok = result.Nearest(Point{Lat: 52.54, Lon: 13.4050})
+	if !ok || element.Way == nil || element.Way.ID != 10 {
+		t.Errorf("expected way 10 via its center, got %+v", element)
+	}
+}
+
+func TestResultNearestEmpty(t *testing.T) {
+	t.Parallel()
+
+	result := Result{Nodes: map[int64]*Node{1: {Meta: Meta{ID: 1}}}}
+
+	if _, _, ok := result.Nearest(Point{Lat: 1, Lon: 1}); ok {
+		t.Error("expected no nearest element without located elements")
+	}
+}
+
+func TestHaversine(t *testing.T) {
+	t.Parallel()
+
+	// Berlin to Munich is roughly 504 km
+	d := haversine(Point{Lat: 52.5200, Lon: 13.4050}, Point{Lat: 48.1372, Lon: 11.5756})
+	if math.Abs(d-504000) > 2000 {
+		t.Errorf("expected ~504 km, got %.0f m", d)
+	}
+
+	if d := haversine(Point{Lat: 1, Lon: 1}, Point{Lat: 1, Lon: 1}); d != 0 {
+		t.Errorf("expected 0 for identical points, got %v", d)
+	}
+}
+
+func TestWayIsArea(t *testing.T) {
+	t.Parallel()
+
+	ring := []Point{{0, 0}, {0, 1}, {1, 1}, {0, 0}}
+
+	testCases := []struct {
+		name     string
+		way      Way
+		expected bool
+	}{
+		{"building", Way{Meta: Meta{Tags: map[string]string{"building": "yes"}}, Geometry: ring}, true},
+		{"untagged ring", Way{Geometry: ring}, true},
+		{"area=no", Way{Meta: Meta{Tags: map[string]string{"leisure": "track", "area": "no"}}, Geometry: ring}, false},
+		{"roundabout", Way{Meta: Meta{Tags: map[string]string{"highway": "primary"}}, Geometry: ring}, false},
+		{"pedestrian area", Way{Meta: Meta{Tags: map[string]string{"highway": "pedestrian", "area": "yes"}}, Geometry: ring}, true},
+		{"open way", Way{Geometry: ring[:3]}, false},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := tc.way.IsArea(); got != tc.expected {
+				t.Errorf("expected %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestFormatCoordinate(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		value     float64
+		precision int
+		expected  string
+	}{
+		{52.5, DefaultCoordinatePrecision, "52.5"},
+		{13, DefaultCoordinatePrecision, "13"},
+		{52.123456789, DefaultCoordinatePrecision, "52.1234568"},
+		{52.123456789, 2, "52.12"},
+		{-0.000000001, DefaultCoordinatePrecision, "0"},
+		{-13.45, 1, "-13.4"},
+	}
+
+	for _, tc := range testCases {
+		if got := FormatCoordinate(tc.value, tc.precision); got != tc.expected {
+			t.Errorf("FormatCoordinate(%v, %d) = %q, want %q", tc.value, tc.precision, got, tc.expected)
+		}
+	}
+}
+
+func TestPointIsValid(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		point    Point
+		expected bool
+	}{
+		{Point{52.5, 13.4}, true},
+		{Point{-90, -180}, true},
+		{Point{90, 180}, true},
+		{Point{90.1, 0}, false},
+		{Point{0, -180.5}, false},
+		{Point{math.NaN(), 0}, false},
+	}
+
+	for _, tc := range testCases {
+		if got := tc.point.IsValid(); got != tc.expected {
+			t.Errorf("%+v.IsValid() = %v, want %v", tc.point, got, tc.expected)
+		}
+	}
+}
+
+func TestWayCentroidSkipsInvalidPoints(t *testing.T) {
+	t.Parallel()
+
+	way := &Way{Geometry: []Point{{0, 0}, {200, 500}, {0, 2}, {2, 2}}}
+
+	got, ok := way.Centroid()
+	if !ok || math.Abs(got.Lat-2.0/3) > 1e-9 || math.Abs(got.Lon-4.0/3) > 1e-9 {
+		t.Errorf("expected centroid of valid vertices, got %+v (%v)", got, ok)
+	}
+
+	if way.Geometry[1] != (Point{200, 500}) {
+		t.Error("Centroid must not modify Geometry")
+	}
+
+	invalid := &Way{Geometry: []Point{{95, 0}}}
+	if _, ok := invalid.Centroid(); ok {
+		t.Error("expected no centroid for a way without valid points")
+	}
+}
+
+func TestMidpoint(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		a, b     Point
+		expected Point
+	}{
+		{"short segment", Point{52.50, 13.40}, Point{52.52, 13.42}, Point{52.51, 13.41}},
+		{"new york to london", Point{40.7128, -74.0060}, Point{51.5074, -0.1278}, Point{52.3684, -41.2903}},
+		{"across the antimeridian", Point{0, 179}, Point{0, -179}, Point{0, -180}},
+		{"same point", Point{10, 20}, Point{10, 20}, Point{10, 20}},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			got := Midpoint(tc.a, tc.b)
+			if math.Abs(got.Lat-tc.expected.Lat) > 1e-3 || math.Abs(got.Lon-tc.expected.Lon) > 1e-3 {
+				t.Errorf("expected %v, got %v", tc.expected, got)
+			}
+		})
+	}
+
+	// The great-circle midpoint of a long span differs clearly from the average
+	naive := Point{Lat: (40.7128 + 51.5074) / 2, Lon: (-74.0060 - 0.1278) / 2}
+	if d := haversine(Midpoint(Point{40.7128, -74.0060}, Point{51.5074, -0.1278}), naive); d < 500000 {
+		t.Errorf("expected midpoint far from naive average, distance %.0f m", d)
+	}
+}
+
+func TestResultDuplicateCoordinates(t *testing.T) {
+	t.Parallel()
+
+	result := Result{Nodes: map[int64]*Node{
+		3: {Meta: Meta{ID: 3}, Lat: 52.5, Lon: 13.4},
+		1: {Meta: Meta{ID: 1}, Lat: 52.5, Lon: 13.4},
+		2: {Meta: Meta{ID: 2}, Lat: 52.50000001, Lon: 13.40000001},
+		4: {Meta: Meta{ID: 4}, Lat: 48.1, Lon: 11.5},
+		5: {Meta: Meta{ID: 5}, Lat: 48.1001, Lon: 11.5},
+		6: {Meta: Meta{ID: 6}},
+		7: {Meta: Meta{ID: 7}},
+	}}
+
+	testCases := []struct {
+		name      string
+		precision int
+		expected  map[Point][]int64
+	}{
+		{"default precision", 0, map[Point][]int64{{52.5, 13.4}: {1, 2, 3}}},
+		{"coarse precision", 3, map[Point][]int64{{52.5, 13.4}: {1, 2, 3}, {48.1, 11.5}: {4, 5}}},
+		{"exact", 9, map[Point][]int64{{52.5, 13.4}: {1, 3}}},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := result.DuplicateCoordinates(tc.precision); !reflect.DeepEqual(got, tc.expected) {
+				t.Errorf("expected %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
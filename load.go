@@ -0,0 +1,44 @@
+package overpass
+
+import (
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LoadResultFile reads a saved Overpass JSON response from disk, e.g. to
+// replay a query dump. Files ending in .gz or .bz2 are decompressed first;
+// anything else is parsed as plain JSON.
+func LoadResultFile(path string) (Result, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return Result{}, fmt.Errorf("load result: %w", err)
+	}
+	defer file.Close()
+
+	var reader io.Reader = file
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".gz":
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return Result{}, fmt.Errorf("load result %s: %w", path, err)
+		}
+		defer gz.Close()
+
+		reader = gz
+	case ".bz2":
+		reader = bzip2.NewReader(file)
+	}
+
+	body, err := io.ReadAll(reader)
+	if err != nil {
+		return Result{}, fmt.Errorf("load result %s: %w", path, err)
+	}
+
+	return unmarshal(body)
+}
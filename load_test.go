@@ -0,0 +1,90 @@
+package overpass
+
+import (
+	"compress/gzip"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const loadFixture = `{"osm3s":{"timestamp_osm_base":"2024-01-02T03:04:05Z"},"elements":[` +
+	`{"type":"node","id":1,"lat":52.5,"lon":13.4,"tags":{"amenity":"cafe"}},` +
+	`{"type":"way","id":2,"nodes":[1]}]}`
+
+func writeGzipFixture(t *testing.T, path string) {
+	t.Helper()
+
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	gz := gzip.NewWriter(file)
+
+	_, err = gz.Write([]byte(loadFixture))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = gz.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadResultFile(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	plain := filepath.Join(dir, "dump.json")
+
+	err := os.WriteFile(plain, []byte(loadFixture), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	compressed := filepath.Join(dir, "dump.json.gz")
+	writeGzipFixture(t, compressed)
+
+	for _, path := range []string{plain, compressed} {
+		result, err := LoadResultFile(path)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", filepath.Base(path), err)
+		}
+
+		if len(result.Nodes) != 1 || len(result.Ways) != 1 {
+			t.Errorf("%s: expected 1 node and 1 way, got %d and %d",
+				filepath.Base(path), len(result.Nodes), len(result.Ways))
+		}
+
+		if result.Nodes[1].Tags["amenity"] != "cafe" || result.Ways[2].Nodes[0] != result.Nodes[1] {
+			t.Errorf("%s: unexpected elements %+v", filepath.Base(path), result)
+		}
+	}
+}
+
+func TestLoadResultFileErrors(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	_, err := LoadResultFile(filepath.Join(dir, "missing.json"))
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected os.ErrNotExist, got %v", err)
+	}
+
+	notGzip := filepath.Join(dir, "dump.json.gz")
+
+	err = os.WriteFile(notGzip, []byte(loadFixture), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = LoadResultFile(notGzip)
+	if err == nil {
+		t.Error("expected error for invalid gzip data")
+	}
+}
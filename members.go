@@ -0,0 +1,203 @@
+package overpass
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ResolveMembers fetches relation members that were returned as bare references
+// (e.g. when the query omitted ">;") and populates them in place.
+// All missing members are requested in a single follow-up query; members that
+// already carry data are skipped. Use ResolveMembersBatched for very large
+// relations.
+func (c *Client) ResolveMembers(ctx context.Context, r *Relation) error {
+	return c.ResolveMembersBatched(ctx, r, 0)
+}
+
+// ResolveMembersBatched is like ResolveMembers but requests at most batchSize
+// members per follow-up query. Batches run sequentially and each response is
+// merged into the relation as it arrives, so members resolved before an error
+// or cancellation stay populated. A batchSize of 0 or less fetches all missing
+// members at once.
+func (c *Client) ResolveMembersBatched(ctx context.Context, r *Relation, batchSize int) error {
+	if r == nil {
+		return nil
+	}
+
+	missing := r.unresolvedMembers()
+	if len(missing) == 0 {
+		return nil
+	}
+
+	if batchSize <= 0 {
+		batchSize = len(missing)
+	}
+
+	for start := 0; start < len(missing); start += batchSize {
+		err := ctx.Err()
+		if err != nil {
+			return fmt.Errorf("resolve members: %w", err)
+		}
+
+		batch := missing[start:min(start+batchSize, len(missing))]
+
+		fetched, err := c.QueryContext(ctx, buildMemberQuery(batch))
+		if err != nil {
+			return fmt.Errorf("resolve members: %w", err)
+		}
+
+		r.mergeMembers(&fetched)
+	}
+
+	return nil
+}
+
+// memberRef identifies a relation member to fetch.
+type memberRef struct {
+	elemType ElementType
+	id       int64
+}
+
+// unresolvedMembers lists the distinct members of r that carry no data yet,
+// in member order.
+func (r *Relation) unresolvedMembers() []memberRef {
+	seen := map[memberRef]bool{}
+
+	var refs []memberRef
+
+	for _, member := range r.Members {
+		var ref memberRef
+
+		switch {
+		case member.Node != nil && !member.Node.isResolved():
+			ref = memberRef{ElementTypeNode, member.Node.ID}
+		case member.Way != nil && !member.Way.isResolved():
+			ref = memberRef{ElementTypeWay, member.Way.ID}
+		case member.Relation != nil && !member.Relation.isResolved():
+			ref = memberRef{ElementTypeRelation, member.Relation.ID}
+		default:
+			continue
+		}
+
+		if !seen[ref] {
+			seen[ref] = true
+			refs = append(refs, ref)
+		}
+	}
+
+	return refs
+}
+
+// mergeMembers copies resolved elements of fetched into the matching members.
+func (r *Relation) mergeMembers(fetched *Result) {
+	for _, member := range r.Members {
+		switch {
+		case member.Node != nil:
+			if node, ok := fetched.Nodes[member.Node.ID]; ok && node.isResolved() {
+				*member.Node = *node
+			}
+		case member.Way != nil:
+			if way, ok := fetched.Ways[member.Way.ID]; ok && way.isResolved() {
+				*member.Way = *way
+			}
+		case member.Relation != nil:
+			if relation, ok := fetched.Relations[member.Relation.ID]; ok && relation.isResolved() {
+				*member.Relation = *relation
+			}
+		}
+	}
+}
+
+// buildMemberQuery creates a union query fetching the given members with geometry.
+func buildMemberQuery(refs []memberRef) string {
+	var nodeIDs, wayIDs, relationIDs []int64
+
+	for _, ref := range refs {
+		switch ref.elemType {
+		case ElementTypeNode:
+			nodeIDs = append(nodeIDs, ref.id)
+		case ElementTypeWay:
+			wayIDs = append(wayIDs, ref.id)
+		case ElementTypeRelation:
+			relationIDs = append(relationIDs, ref.id)
+		}
+	}
+
+	var sb strings.Builder
+
+	sb.WriteString("[out:json];(")
+
+	writeIDClause(&sb, "node", nodeIDs)
+	writeIDClause(&sb, "way", wayIDs)
+	writeIDClause(&sb, "relation", relationIDs)
+
+	sb.WriteString(");out geom;")
+
+	return sb.String()
+}
+
+func writeIDClause(sb *strings.Builder, elemType string, ids []int64) {
+	if len(ids) == 0 {
+		return
+	}
+
+	sb.WriteString(idClause(elemType, ids) + ";")
+}
+
+// idClause formats an id selection such as node(id:1,2,3).
+func idClause(elemType string, ids []int64) string {
+	var sb strings.Builder
+
+	sb.WriteString(elemType + "(id:")
+
+	for i, id := range ids {
+		if i > 0 {
+			sb.WriteString(",")
+		}
+
+		sb.WriteString(strconv.FormatInt(id, 10))
+	}
+
+	sb.WriteString(")")
+
+	return sb.String()
+}
+
+// RoleCounts returns how many members the relation has per role. Members
+// without a role are counted under "".
+func (r *Relation) RoleCounts() map[string]int {
+	counts := map[string]int{}
+	for _, member := range r.Members {
+		counts[member.Role]++
+	}
+
+	return counts
+}
+
+// MemberTypeCounts returns how many node, way and relation members the
+// relation has.
+func (r *Relation) MemberTypeCounts() map[ElementType]int {
+	counts := map[ElementType]int{}
+	for _, member := range r.Members {
+		counts[member.Type]++
+	}
+
+	return counts
+}
+
+// isResolved reports whether the node carries more than its id.
+func (n *Node) isResolved() bool {
+	return n.Lat != 0 || n.Lon != 0 || len(n.Tags) > 0 || n.Version != 0
+}
+
+// isResolved reports whether the way carries node references or geometry.
+func (w *Way) isResolved() bool {
+	return len(w.Nodes) > 0 || len(w.Geometry) > 0
+}
+
+// isResolved reports whether the relation carries members or tags.
+func (r *Relation) isResolved() bool {
+	return len(r.Members) > 0 || len(r.Tags) > 0
+}
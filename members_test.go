@@ -0,0 +1,181 @@
+package overpass
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestResolveMembers(t *testing.T) {
+	t.Parallel()
+
+	relationBody := `{"elements":[{"type":"relation","id":1,"members":[
+		{"type":"way","ref":10,"role":"outer"},
+		{"type":"way","ref":11,"role":"inner"},
+		{"type":"node","ref":20,"role":"label"}
+	]}]}`
+
+	memberBody := `{"elements":[
+		{"type":"way","id":10,"tags":{"building":"yes"},
+			"geometry":[{"lat":1,"lon":1},{"lat":1,"lon":2},{"lat":2,"lon":2},{"lat":1,"lon":1}]},
+		{"type":"way","id":11,"geometry":[{"lat":1.2,"lon":1.2},{"lat":1.4,"lon":1.4}]},
+		{"type":"node","id":20,"lat":1.5,"lon":1.6,"tags":{"name":"Label"}}
+	]}`
+
+	mock := &mockRecordingHTTPClient{bodies: []string{relationBody, memberBody}}
+	client := NewWithSettings(apiEndpoint, 1, mock)
+
+	result, err := client.QueryContext(context.Background(), "[out:json];relation(1);out;")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	relation := result.Relations[1]
+
+	err = client.ResolveMembers(context.Background(), relation)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	queries := mock.recordedQueries()
+	if len(queries) != 2 {
+		t.Fatalf("expected 2 queries, got %d", len(queries))
+	}
+
+	followUp := queries[1]
+	if !strings.Contains(followUp, "way(id:10,11);") || !strings.Contains(followUp, "node(id:20);") {
+		t.Errorf("unexpected follow-up query: %s", followUp)
+	}
+
+	outer := relation.Members[0].Way
+	if len(outer.Geometry) != 4 || outer.Tags["building"] != "yes" {
+		t.Errorf("outer way not resolved: %+v", outer)
+	}
+
+	// Member pointers are shared with the result maps
+	if result.Ways[10] != outer {
+		t.Error("expected member way to stay linked to result map")
+	}
+
+	label := relation.Members[2].Node
+	if label.Lat != 1.5 || label.GetName() != "Label" {
+		t.Errorf("label node not resolved: %+v", label)
+	}
+}
+
+func TestResolveMembersSkipsResolved(t *testing.T) {
+	t.Parallel()
+
+	mock := &mockRecordingHTTPClient{}
+	client := NewWithSettings(apiEndpoint, 1, mock)
+
+	relation := &Relation{
+		Members: []RelationMember{
+			{Type: ElementTypeNode, Node: &Node{Meta: Meta{ID: 1}, Lat: 1, Lon: 2}},
+			{Type: ElementTypeWay, Way: &Way{Meta: Meta{ID: 2}, Geometry: []Point{{1, 2}}}},
+		},
+	}
+
+	err := client.ResolveMembers(context.Background(), relation)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if n := len(mock.recordedQueries()); n != 0 {
+		t.Errorf("expected no queries for resolved members, got %d", n)
+	}
+}
+
+func TestResolveMembersBatched(t *testing.T) {
+	t.Parallel()
+
+	wayBody := func(ids ...int) string {
+		elements := make([]string, len(ids))
+		for i, id := range ids {
+			elements[i] = fmt.Sprintf(`{"type":"way","id":%d,"geometry":[{"lat":1,"lon":%d},{"lat":2,"lon":%d}]}`, id, id, id)
+		}
+
+		return `{"elements":[` + strings.Join(elements, ",") + `]}`
+	}
+
+	relation := &Relation{Meta: Meta{ID: 1}}
+	for id := int64(10); id < 15; id++ {
+		relation.Members = append(relation.Members, RelationMember{Type: ElementTypeWay, Way: &Way{Meta: Meta{ID: id}}})
+	}
+
+	mock := &mockRecordingHTTPClient{bodies: []string{wayBody(10, 11), wayBody(12, 13), wayBody(14)}}
+	client := NewWithSettings(apiEndpoint, 1, mock)
+
+	err := client.ResolveMembersBatched(context.Background(), relation, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	queries := mock.recordedQueries()
+	if len(queries) != 3 {
+		t.Fatalf("expected 3 batch queries, got %d: %v", len(queries), queries)
+	}
+
+	if !strings.Contains(queries[2], "way(id:14);") {
+		t.Errorf("unexpected last batch: %s", queries[2])
+	}
+
+	for _, member := range relation.Members {
+		if len(member.Way.Geometry) != 2 {
+			t.Errorf("way %d not resolved", member.Way.ID)
+		}
+	}
+}
+
+func TestResolveMembersBatchedCancelled(t *testing.T) {
+	t.Parallel()
+
+	relation := &Relation{Members: []RelationMember{
+		{Type: ElementTypeNode, Node: &Node{Meta: Meta{ID: 1}}},
+		{Type: ElementTypeNode, Node: &Node{Meta: Meta{ID: 2}}},
+	}}
+
+	mock := &mockRecordingHTTPClient{}
+	client := NewWithSettings(apiEndpoint, 1, mock)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := client.ResolveMembersBatched(ctx, relation, 1)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+
+	if n := len(mock.recordedQueries()); n != 0 {
+		t.Errorf("expected no queries, got %d", n)
+	}
+}
+
+func TestRelationMemberCounts(t *testing.T) {
+	t.Parallel()
+
+	relation := Relation{Members: []RelationMember{
+		{Type: ElementTypeNode, Role: "stop"},
+		{Type: ElementTypeNode, Role: "platform"},
+		{Type: ElementTypeWay, Role: "platform"},
+		{Type: ElementTypeWay},
+		{Type: ElementTypeWay},
+		{Type: ElementTypeRelation, Role: "stop"},
+	}}
+
+	roles := relation.RoleCounts()
+	if roles["stop"] != 2 || roles["platform"] != 2 || roles[""] != 2 || len(roles) != 3 {
+		t.Errorf("unexpected role counts: %v", roles)
+	}
+
+	types := relation.MemberTypeCounts()
+	if types[ElementTypeNode] != 2 || types[ElementTypeWay] != 3 || types[ElementTypeRelation] != 1 {
+		t.Errorf("unexpected type counts: %v", types)
+	}
+
+	if counts := (&Relation{}).RoleCounts(); counts == nil || len(counts) != 0 {
+		t.Errorf("expected empty non-nil map, got %v", counts)
+	}
+}
@@ -0,0 +1,296 @@
+package overpass
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Conversion factors to meters for the length units OSM tags use.
+const (
+	metersPerFoot = 0.3048
+	metersPerInch = 0.0254
+)
+
+// Wikidata returns the element's Wikidata item id (e.g. "Q64") from the
+// wikidata tag. The id must be "Q" followed by digits.
+func (m *Meta) Wikidata() (string, bool) {
+	id := strings.TrimSpace(m.Tags["wikidata"])
+	if len(id) < 2 || id[0] != 'Q' {
+		return "", false
+	}
+
+	for _, ch := range id[1:] {
+		if ch < '0' || ch > '9' {
+			return "", false
+		}
+	}
+
+	return id, true
+}
+
+// Wikipedia parses the wikipedia tag in its "lang:Title" form,
+// e.g. "de:Berlin" yields ("de", "Berlin").
+func (m *Meta) Wikipedia() (lang, title string, ok bool) {
+	lang, title, found := strings.Cut(m.Tags["wikipedia"], ":")
+	if !found {
+		return "", "", false
+	}
+
+	lang = strings.TrimSpace(lang)
+	title = strings.TrimSpace(title)
+
+	// Reject URLs such as "https://de.wikipedia.org/wiki/Berlin"
+	if lang == "" || title == "" || strings.ContainsAny(lang, " /") || strings.HasPrefix(title, "//") {
+		return "", "", false
+	}
+
+	return lang, title, true
+}
+
+// LatestTimestamp returns the newest edit time (Meta.Timestamp, present with
+// "out meta") across all elements, or false if no element carries one.
+// Unlike Result.Timestamp, which is the database state, this reflects the
+// most recent edit actually contained in the result.
+func (r *Result) LatestTimestamp() (time.Time, bool) {
+	var latest time.Time
+
+	found := false
+
+	for _, element := range r.Elements() {
+		ts := element.Meta().Timestamp
+		if ts != nil && (!found || ts.After(latest)) {
+			latest = *ts
+			found = true
+		}
+	}
+
+	return latest, found
+}
+
+// FeatureKey returns a compact identity such as "n/123", "w/456" or "r/789"
+// that stays unique across element types, for use as a deduplication key.
+// Areas use "a/"; unknown types keep their full name.
+func (m *Meta) FeatureKey(t ElementType) string {
+	prefix := string(t)
+
+	switch t {
+	case ElementTypeNode, ElementTypeWay, ElementTypeRelation, ElementTypeArea:
+		prefix = prefix[:1]
+	}
+
+	return prefix + "/" + strconv.FormatInt(m.ID, 10)
+}
+
+// DistinctTagKeys returns every tag key used by any node, way or relation in
+// the result, sorted and without duplicates.
+func (r *Result) DistinctTagKeys() []string {
+	seen := map[string]bool{}
+	keys := []string{}
+
+	for _, element := range r.Elements() {
+		for key := range element.Meta().Tags {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+// Histogram counts the nodes, ways and relations per value of the tag key;
+// elements without the tag are skipped. It needs the elements downloaded
+// (e.g. with "out tags"), which is wasteful for large areas but discovers
+// every value; QueryBuilder.CountByValue lets the server count known values.
+func (r *Result) Histogram(key string) map[string]int {
+	histogram := map[string]int{}
+
+	for _, element := range r.Elements() {
+		if value, ok := element.Meta().Tags[key]; ok {
+			histogram[value]++
+		}
+	}
+
+	return histogram
+}
+
+// CountWhere returns how many nodes, ways and relations satisfy pred. Unlike
+// building a filtered Result it allocates nothing. Elements without tags are
+// passed with a nil Tags map, which is safe to read.
+func (r *Result) CountWhere(pred func(Meta) bool) int {
+	count := 0
+
+	for _, node := range r.Nodes {
+		if pred(node.Meta) {
+			count++
+		}
+	}
+
+	for _, way := range r.Ways {
+		if pred(way.Meta) {
+			count++
+		}
+	}
+
+	for _, relation := range r.Relations {
+		if pred(relation.Meta) {
+			count++
+		}
+	}
+
+	return count
+}
+
+// MergeMeta combines two copies of the same element, e.g. one fetched with
+// "out tags" and one with "out meta". The copy with the higher Version (or,
+// for equal versions, the later Timestamp; a on a tie) is preferred: its
+// non-empty fields win, and the other copy fills the remaining gaps. Tags are
+// united; keys present in both with different values take the preferred
+// value and are returned as conflicts in ascending order.
+func MergeMeta(a, b Meta) (Meta, []string) {
+	if isNewerMeta(b, a) {
+		a, b = b, a
+	}
+
+	merged := a
+
+	if merged.ID == 0 {
+		merged.ID = b.ID
+	}
+
+	if merged.Timestamp == nil {
+		merged.Timestamp = b.Timestamp
+	}
+
+	if merged.Version == 0 {
+		merged.Version = b.Version
+	}
+
+	if merged.Changeset == 0 {
+		merged.Changeset = b.Changeset
+	}
+
+	if merged.User == "" {
+		merged.User = b.User
+	}
+
+	if merged.UID == 0 {
+		merged.UID = b.UID
+	}
+
+	if len(a.Tags)+len(b.Tags) == 0 {
+		return merged, nil
+	}
+
+	var conflicts []string
+
+	merged.Tags = make(map[string]string, max(len(a.Tags), len(b.Tags)))
+
+	for key, value := range b.Tags {
+		merged.Tags[key] = value
+	}
+
+	for key, value := range a.Tags {
+		if other, ok := b.Tags[key]; ok && other != value {
+			conflicts = append(conflicts, key)
+		}
+
+		merged.Tags[key] = value
+	}
+
+	sort.Strings(conflicts)
+
+	return merged, conflicts
+}
+
+// isNewerMeta reports whether a is a more recent version than b.
+func isNewerMeta(a, b Meta) bool {
+	if a.Version != b.Version {
+		return a.Version > b.Version
+	}
+
+	return a.Timestamp != nil && (b.Timestamp == nil || a.Timestamp.After(*b.Timestamp))
+}
+
+// Elevation returns the ele tag in meters above sea level. Values may carry a
+// unit ("312 m", "1024 ft"); plain numbers are meters.
+func (m *Meta) Elevation() (float64, bool) {
+	return parseLength(m.Tags["ele"])
+}
+
+// Height returns the height tag in meters. Besides metric values ("12",
+// "12 m") it accepts imperial feet and inches such as 12'6".
+func (m *Meta) Height() (float64, bool) {
+	return parseLength(m.Tags["height"])
+}
+
+// parseLength parses an OSM length value into meters.
+func parseLength(value string) (float64, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+
+	if strings.ContainsAny(value, `'"`) {
+		return parseFeetInches(value)
+	}
+
+	end := strings.IndexFunc(value, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.' && r != '-' && r != '+'
+	})
+	if end < 0 {
+		end = len(value)
+	}
+
+	num, err := strconv.ParseFloat(value[:end], 64)
+	if err != nil {
+		return 0, false
+	}
+
+	switch strings.TrimSpace(value[end:]) {
+	case "", "m":
+		return num, true
+	case "km":
+		return num * 1000, true
+	case "ft":
+		return num * metersPerFoot, true
+	default:
+		return 0, false
+	}
+}
+
+// parseFeetInches parses imperial lengths like 12'6", 12' or 6".
+func parseFeetInches(value string) (float64, bool) {
+	feet, inches := "0", value
+
+	if before, after, found := strings.Cut(value, "'"); found {
+		feet, inches = before, after
+	}
+
+	inches = strings.TrimSpace(inches)
+	if inches == "" {
+		inches = "0"
+	} else {
+		var found bool
+		if inches, found = strings.CutSuffix(inches, `"`); !found {
+			return 0, false
+		}
+	}
+
+	ft, err := strconv.ParseFloat(strings.TrimSpace(feet), 64)
+	if err != nil {
+		return 0, false
+	}
+
+	in, err := strconv.ParseFloat(strings.TrimSpace(inches), 64)
+	if err != nil {
+		return 0, false
+	}
+
+	return ft*metersPerFoot + in*metersPerInch, true
+}
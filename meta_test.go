@@ -0,0 +1,311 @@
+package overpass
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMetaWikidata(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name   string
+		tags   map[string]string
+		wantID string
+		wantOK bool
+	}{
+		{"valid", map[string]string{"wikidata": "Q64"}, "Q64", true},
+		{"missing", map[string]string{"name": "Berlin"}, "", false},
+		{"nil tags", nil, "", false},
+		{"no digits", map[string]string{"wikidata": "Q"}, "", false},
+		{"lowercase", map[string]string{"wikidata": "q64"}, "", false},
+		{"multiple ids", map[string]string{"wikidata": "Q64;Q1055"}, "", false},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			meta := Meta{Tags: tc.tags}
+
+			id, ok := meta.Wikidata()
+			if id != tc.wantID || ok != tc.wantOK {
+				t.Errorf("expected (%q, %v), got (%q, %v)", tc.wantID, tc.wantOK, id, ok)
+			}
+		})
+	}
+}
+
+func TestMetaWikipedia(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name      string
+		tags      map[string]string
+		wantLang  string
+		wantTitle string
+		wantOK    bool
+	}{
+		{"valid", map[string]string{"wikipedia": "de:Berlin"}, "de", "Berlin", true},
+		{"title with colon", map[string]string{"wikipedia": "en:Star Trek: Voyager"}, "en", "Star Trek: Voyager", true},
+		{"missing", map[string]string{}, "", "", false},
+		{"no language", map[string]string{"wikipedia": "Berlin"}, "", "", false},
+		{"empty title", map[string]string{"wikipedia": "de:"}, "", "", false},
+		{"url", map[string]string{"wikipedia": "https://de.wikipedia.org/wiki/Berlin"}, "", "", false},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			meta := Meta{Tags: tc.tags}
+
+			lang, title, ok := meta.Wikipedia()
+			if lang != tc.wantLang || title != tc.wantTitle || ok != tc.wantOK {
+				t.Errorf("expected (%q, %q, %v), got (%q, %q, %v)",
+					tc.wantLang, tc.wantTitle, tc.wantOK, lang, title, ok)
+			}
+		})
+	}
+}
+
+func TestResultLatestTimestamp(t *testing.T) {
+	t.Parallel()
+
+	older := time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC)
+	newest := time.Date(2024, 2, 9, 12, 30, 0, 0, time.UTC)
+	middle := time.Date(2023, 12, 24, 18, 0, 0, 0, time.UTC)
+
+	result := Result{
+		Nodes: map[int64]*Node{
+			1: {Meta: Meta{ID: 1, Timestamp: &older}},
+			2: {Meta: Meta{ID: 2}},
+		},
+		Ways:      map[int64]*Way{10: {Meta: Meta{ID: 10, Timestamp: &newest}}},
+		Relations: map[int64]*Relation{20: {Meta: Meta{ID: 20, Timestamp: &middle}}},
+	}
+
+	latest, ok := result.LatestTimestamp()
+	if !ok {
+		t.Fatal("expected a timestamp")
+	}
+
+	if !latest.Equal(newest) {
+		t.Errorf("expected %v, got %v", newest, latest)
+	}
+}
+
+func TestResultLatestTimestampNone(t *testing.T) {
+	t.Parallel()
+
+	result := Result{
+		Timestamp: time.Now(),
+		Nodes:     map[int64]*Node{1: {Meta: Meta{ID: 1}}},
+	}
+
+	if latest, ok := result.LatestTimestamp(); ok || !latest.IsZero() {
+		t.Errorf("expected no timestamp, got %v", latest)
+	}
+}
+
+func TestMetaElevationHeight(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name   string
+		tags   map[string]string
+		ele    float64
+		eleOK  bool
+		height float64
+		htOK   bool
+	}{
+		{"plain meters", map[string]string{"ele": "34.5", "height": "12"}, 34.5, true, 12, true},
+		{"metric unit", map[string]string{"ele": "312 m", "height": "12m"}, 312, true, 12, true},
+		{"negative elevation", map[string]string{"ele": "-28"}, -28, true, 0, false},
+		{"feet unit", map[string]string{"ele": "1000 ft"}, 304.8, true, 0, false},
+		{"feet and inches", map[string]string{"height": `12'6"`}, 0, false, 3.81, true},
+		{"feet only", map[string]string{"height": "10'"}, 0, false, 3.048, true},
+		{"unparseable", map[string]string{"ele": "high", "height": "12 storeys"}, 0, false, 0, false},
+		{"bad imperial", map[string]string{"height": `12'6`}, 0, false, 0, false},
+		{"missing", nil, 0, false, 0, false},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			meta := Meta{Tags: tc.tags}
+
+			ele, ok := meta.Elevation()
+			if ok != tc.eleOK || math.Abs(ele-tc.ele) > 1e-9 {
+				t.Errorf("elevation: expected (%v, %v), got (%v, %v)", tc.ele, tc.eleOK, ele, ok)
+			}
+
+			height, ok := meta.Height()
+			if ok != tc.htOK || math.Abs(height-tc.height) > 1e-9 {
+				t.Errorf("height: expected (%v, %v), got (%v, %v)", tc.height, tc.htOK, height, ok)
+			}
+		})
+	}
+}
+
+func TestResultDistinctTagKeys(t *testing.T) {
+	t.Parallel()
+
+	result := Result{
+		Nodes: map[int64]*Node{
+			1: {Meta: Meta{ID: 1, Tags: map[string]string{"name": "A", "amenity": "cafe"}}},
+			2: {Meta: Meta{ID: 2}},
+		},
+		Ways: map[int64]*Way{
+			10: {Meta: Meta{ID: 10, Tags: map[string]string{"highway": "primary", "name": "B"}}},
+		},
+		Relations: map[int64]*Relation{
+			20: {Meta: Meta{ID: 20, Tags: map[string]string{"type": "route", "amenity": "x"}}},
+		},
+	}
+
+	keys := result.DistinctTagKeys()
+
+	expected := []string{"amenity", "highway", "name", "type"}
+	if !reflect.DeepEqual(keys, expected) {
+		t.Errorf("expected %v, got %v", expected, keys)
+	}
+
+	if keys := (&Result{}).DistinctTagKeys(); keys == nil || len(keys) != 0 {
+		t.Errorf("expected empty non-nil slice, got %v", keys)
+	}
+}
+
+func TestResultCountWhere(t *testing.T) {
+	t.Parallel()
+
+	result := Result{
+		Nodes: map[int64]*Node{
+			1: {Meta: Meta{ID: 1, Tags: map[string]string{"amenity": "cafe"}}},
+			2: {Meta: Meta{ID: 2, Tags: map[string]string{"amenity": "bench"}}},
+			3: {Meta: Meta{ID: 3}},
+		},
+		Ways: map[int64]*Way{
+			10: {Meta: Meta{ID: 10, Tags: map[string]string{"amenity": "restaurant", "building": "yes"}}},
+			11: {Meta: Meta{ID: 11, Tags: map[string]string{"highway": "primary"}}},
+		},
+		Relations: map[int64]*Relation{
+			20: {Meta: Meta{ID: 20, Tags: map[string]string{"amenity": "fast_food"}}},
+		},
+	}
+
+	food := map[string]bool{"cafe": true, "restaurant": true, "fast_food": true}
+
+	if count := result.CountWhere(func(m Meta) bool { return food[m.Tags["amenity"]] }); count != 3 {
+		t.Errorf("expected 3 food amenities, got %d", count)
+	}
+
+	if count := result.CountWhere(func(Meta) bool { return true }); count != 6 {
+		t.Errorf("expected 6 elements, got %d", count)
+	}
+
+	if count := (&Result{}).CountWhere(func(Meta) bool { return true }); count != 0 {
+		t.Errorf("expected 0 for empty result, got %d", count)
+	}
+}
+
+func TestResultHistogram(t *testing.T) {
+	t.Parallel()
+
+	result := Result{
+		Nodes: map[int64]*Node{
+			1: {Meta: Meta{ID: 1, Tags: map[string]string{"cuisine": "pizza"}}},
+			2: {Meta: Meta{ID: 2, Tags: map[string]string{"cuisine": "sushi"}}},
+			3: {Meta: Meta{ID: 3}},
+		},
+		Ways: map[int64]*Way{
+			10: {Meta: Meta{ID: 10, Tags: map[string]string{"cuisine": "pizza", "building": "yes"}}},
+		},
+		Relations: map[int64]*Relation{
+			20: {Meta: Meta{ID: 20, Tags: map[string]string{"cuisine": "pizza"}}},
+		},
+	}
+
+	expected := map[string]int{"pizza": 3, "sushi": 1}
+	if histogram := result.Histogram("cuisine"); !reflect.DeepEqual(histogram, expected) {
+		t.Errorf("expected %v, got %v", expected, histogram)
+	}
+
+	if histogram := result.Histogram("shop"); histogram == nil || len(histogram) != 0 {
+		t.Errorf("expected empty histogram, got %v", histogram)
+	}
+}
+
+func TestMetaFeatureKey(t *testing.T) {
+	t.Parallel()
+
+	meta := Meta{ID: 123}
+
+	keys := map[string]bool{}
+	for _, elemType := range []ElementType{ElementTypeNode, ElementTypeWay, ElementTypeRelation, ElementTypeArea} {
+		keys[meta.FeatureKey(elemType)] = true
+	}
+
+	for _, key := range []string{"n/123", "w/123", "r/123", "a/123"} {
+		if !keys[key] {
+			t.Errorf("missing key %q in %v", key, keys)
+		}
+	}
+
+	if key := meta.FeatureKey("changeset"); key != "changeset/123" {
+		t.Errorf("unexpected key for unknown type: %q", key)
+	}
+}
+
+func TestMergeMeta(t *testing.T) {
+	t.Parallel()
+
+	edited := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	tagsOnly := Meta{ID: 42, Tags: map[string]string{"amenity": "cafe", "name": "Kaffee"}}
+	withMeta := Meta{ID: 42, Version: 3, Timestamp: &edited, User: "mapper", UID: 7, Changeset: 99,
+		Tags: map[string]string{"amenity": "cafe", "opening_hours": "Mo-Fr 08:00-18:00"}}
+
+	merged, conflicts := MergeMeta(tagsOnly, withMeta)
+	if len(conflicts) != 0 {
+		t.Errorf("expected no conflicts, got %v", conflicts)
+	}
+
+	expected := Meta{ID: 42, Version: 3, Timestamp: &edited, User: "mapper", UID: 7, Changeset: 99,
+		Tags: map[string]string{"amenity": "cafe", "name": "Kaffee", "opening_hours": "Mo-Fr 08:00-18:00"}}
+	if !reflect.DeepEqual(merged, expected) {
+		t.Errorf("expected %+v, got %+v", expected, merged)
+	}
+
+	// The newer version wins a conflict regardless of argument order
+	older := Meta{ID: 42, Version: 2, Tags: map[string]string{"name": "Old Name", "cuisine": "coffee_shop"}}
+
+	_, conflicts = MergeMeta(older, withMeta)
+	if len(conflicts) != 0 {
+		t.Errorf("expected no conflicts, got %v", conflicts)
+	}
+
+	older.Tags["amenity"] = "restaurant"
+
+	merged, conflicts = MergeMeta(older, withMeta)
+	if !reflect.DeepEqual(conflicts, []string{"amenity"}) {
+		t.Errorf("expected amenity conflict, got %v", conflicts)
+	}
+
+	if merged.Tags["amenity"] != "cafe" || merged.Tags["cuisine"] != "coffee_shop" || merged.Version != 3 {
+		t.Errorf("expected newer values with older tags filled in, got %+v", merged)
+	}
+
+	if older.Tags["amenity"] != "restaurant" || len(withMeta.Tags) != 2 {
+		t.Error("MergeMeta must not modify its arguments")
+	}
+}
@@ -0,0 +1,66 @@
+package overpass
+
+// Option configures a Client created by NewClient.
+type Option func(*Client)
+
+// WithEndpoint sets the Overpass API interpreter URL.
+func WithEndpoint(endpoint string) Option {
+	return func(c *Client) {
+		c.apiEndpoint = endpoint
+	}
+}
+
+// WithMaxParallel sets the maximum number of parallel requests.
+// Values below 1 are treated as 1.
+func WithMaxParallel(n int) Option {
+	return func(c *Client) {
+		c.semaphore = newSemaphore(max(n, 1))
+	}
+}
+
+// WithHTTPClient sets the HTTP client used for requests.
+// A nil client keeps http.DefaultClient.
+func WithHTTPClient(httpClient HTTPClient) Option {
+	return func(c *Client) {
+		if httpClient != nil {
+			c.httpClient = httpClient
+		}
+	}
+}
+
+// WithRetry sets the retry configuration.
+func WithRetry(config RetryConfig) Option {
+	return func(c *Client) {
+		c.retryConfig = config
+	}
+}
+
+// WithCache sets the cache configuration.
+func WithCache(config CacheConfig) Option {
+	return func(c *Client) {
+		c.cache.config = config
+	}
+}
+
+// WithUserAgent sets the User-Agent header sent with every request. The Overpass
+// API usage policy asks clients to identify themselves.
+func WithUserAgent(userAgent string) Option {
+	return func(c *Client) {
+		c.userAgent = userAgent
+	}
+}
+
+// WithHeader sets an HTTP header sent with every request (see Client.SetHeader).
+func WithHeader(key, value string) Option {
+	return func(c *Client) {
+		c.SetHeader(key, value)
+	}
+}
+
+// WithOutputFormat sets the response format of builder queries, "json" or
+// "xml" (see Client.OutputFormat).
+func WithOutputFormat(format string) Option {
+	return func(c *Client) {
+		c.OutputFormat = format
+	}
+}
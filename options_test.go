@@ -0,0 +1,105 @@
+package overpass
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestNewClientDefaults(t *testing.T) {
+	t.Parallel()
+
+	client := NewClient()
+	defer client.Close()
+
+	if client.apiEndpoint != apiEndpoint {
+		t.Errorf("expected endpoint %s, got %s", apiEndpoint, client.apiEndpoint)
+	}
+
+	if client.httpClient != http.DefaultClient {
+		t.Error("expected http.DefaultClient")
+	}
+
+	if client.semaphore.capacity() != 1 {
+		t.Errorf("expected semaphore capacity 1, got %d", client.semaphore.capacity())
+	}
+
+	if client.retryConfig.MaxRetries != DefaultRetryConfig().MaxRetries {
+		t.Errorf("expected default retry config, got %+v", client.retryConfig)
+	}
+
+	if client.cache.config.Enabled {
+		t.Error("cache should be disabled by default")
+	}
+
+	if client.userAgent != "" {
+		t.Errorf("expected no user agent, got %q", client.userAgent)
+	}
+}
+
+func TestNewClientOptions(t *testing.T) {
+	t.Parallel()
+
+	mock := &mockRecordingHTTPClient{}
+	retry := RetryConfig{MaxRetries: 5, InitialBackoff: time.Millisecond}
+	cacheConfig := CacheConfig{Enabled: true, TTL: time.Minute, MaxEntries: 10}
+
+	client := NewClient(
+		WithEndpoint("https://overpass.example/api/interpreter"),
+		WithMaxParallel(4),
+		WithHTTPClient(mock),
+		WithRetry(retry),
+		WithCache(cacheConfig),
+		WithUserAgent("my-app/1.0"),
+	)
+	defer client.Close()
+
+	if client.apiEndpoint != "https://overpass.example/api/interpreter" {
+		t.Errorf("unexpected endpoint %s", client.apiEndpoint)
+	}
+
+	if client.httpClient != mock {
+		t.Error("expected custom HTTP client")
+	}
+
+	if client.semaphore.capacity() != 4 {
+		t.Errorf("expected semaphore capacity 4, got %d", client.semaphore.capacity())
+	}
+
+	if client.retryConfig.MaxRetries != 5 {
+		t.Errorf("expected MaxRetries=5, got %d", client.retryConfig.MaxRetries)
+	}
+
+	if client.cache.config != cacheConfig {
+		t.Errorf("expected cache config %+v, got %+v", cacheConfig, client.cache.config)
+	}
+
+	_, err := client.QueryContext(context.Background(), "[out:json];node(1);out;")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := mock.headers[0].Get("User-Agent"); got != "my-app/1.0" {
+		t.Errorf("expected User-Agent my-app/1.0, got %q", got)
+	}
+
+	if client.CacheSize() != 1 {
+		t.Errorf("expected query to be cached, got size %d", client.CacheSize())
+	}
+}
+
+func TestNewClientOptionEdgeCases(t *testing.T) {
+	t.Parallel()
+
+	client := NewClient(WithHTTPClient(nil), WithMaxParallel(0))
+	defer client.Close()
+
+	if client.httpClient != http.DefaultClient {
+		t.Error("nil HTTP client should keep http.DefaultClient")
+	}
+
+	if client.semaphore.capacity() != 1 {
+		t.Errorf("expected capacity clamped to 1, got %d", client.semaphore.capacity())
+	}
+}
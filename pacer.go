@@ -0,0 +1,62 @@
+package overpass
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// pacer enforces a minimum gap between the completion of one request and the
+// start of the next, and between the starts of concurrent requests.
+type pacer struct {
+	mu           sync.Mutex
+	lastDone     time.Time
+	lastReserved time.Time // start time handed to the latest caller of wait
+}
+
+// wait blocks until interval has elapsed since the previous request finished
+// and since the previously reserved start, or the context is done. The start
+// slot is reserved under the lock, so concurrent callers are spaced out
+// rather than released together. A cancelled wait keeps its slot.
+func (p *pacer) wait(ctx context.Context, interval time.Duration) error {
+	if interval <= 0 {
+		return nil
+	}
+
+	p.mu.Lock()
+	now := time.Now()
+	next := now
+
+	if after := p.lastDone.Add(interval); after.After(next) {
+		next = after
+	}
+
+	if after := p.lastReserved.Add(interval); !p.lastReserved.IsZero() && after.After(next) {
+		next = after
+	}
+
+	p.lastReserved = next
+	p.mu.Unlock()
+
+	delay := next.Sub(now)
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// done records the completion time of a request.
+func (p *pacer) done() {
+	p.mu.Lock()
+	p.lastDone = time.Now()
+	p.mu.Unlock()
+}
@@ -0,0 +1,148 @@
+package overpass
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPacerWait(t *testing.T) {
+	t.Parallel()
+
+	p := &pacer{}
+
+	// No previous request: no wait
+	start := time.Now()
+	if err := p.wait(context.Background(), time.Hour); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > 10*time.Millisecond {
+		t.Errorf("first request should not wait, waited %v", elapsed)
+	}
+
+	p.done()
+
+	start = time.Now()
+	if err := p.wait(context.Background(), 30*time.Millisecond); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed < 25*time.Millisecond {
+		t.Errorf("expected wait of ~30ms, waited %v", elapsed)
+	}
+
+	// Zero interval disables pacing
+	if err := p.wait(context.Background(), 0); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestClientMinInterval(t *testing.T) {
+	t.Parallel()
+
+	client := NewWithSettings(apiEndpoint, 1, &mockRecordingHTTPClient{})
+	client.MinInterval = 40 * time.Millisecond
+
+	start := time.Now()
+
+	for i := 0; i < 3; i++ {
+		_, err := client.QueryContext(context.Background(), "[out:json];node(1);out;")
+		if err != nil {
+			t.Fatalf("query %d failed: %v", i, err)
+		}
+	}
+
+	// Two gaps between three back-to-back queries
+	if elapsed := time.Since(start); elapsed < 75*time.Millisecond {
+		t.Errorf("expected queries to be spaced by MinInterval, took %v", elapsed)
+	}
+}
+
+func TestClientMinIntervalCancelled(t *testing.T) {
+	t.Parallel()
+
+	client := NewWithSettings(apiEndpoint, 1, &mockRecordingHTTPClient{})
+	client.MinInterval = time.Hour
+
+	_, err := client.QueryContext(context.Background(), "[out:json];node(1);out;")
+	if err != nil {
+		t.Fatalf("first query failed: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+
+	_, err = client.QueryContext(ctx, "[out:json];node(2);out;")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("cancelled wait should abort promptly, took %v", elapsed)
+	}
+}
+
+// startRecordingClient records when each request starts and takes latency
+// to answer, so concurrent requests overlap.
+type startRecordingClient struct {
+	mu      sync.Mutex
+	starts  []time.Time
+	latency time.Duration
+}
+
+func (m *startRecordingClient) Do(_ *http.Request) (*http.Response, error) {
+	m.mu.Lock()
+	m.starts = append(m.starts, time.Now())
+	m.mu.Unlock()
+
+	time.Sleep(m.latency)
+
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(bytes.NewBufferString(`{"elements":[]}`)),
+	}, nil
+}
+
+func TestClientMinIntervalParallel(t *testing.T) {
+	t.Parallel()
+
+	mock := &startRecordingClient{latency: 100 * time.Millisecond}
+	client := NewWithSettings(apiEndpoint, 2, mock)
+	client.MinInterval = 40 * time.Millisecond
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			_, err := client.QueryContext(context.Background(), "[out:json];node(1);out;")
+			if err != nil {
+				t.Errorf("query failed: %v", err)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if len(mock.starts) != 2 {
+		t.Fatalf("expected two requests, got %d", len(mock.starts))
+	}
+
+	sort.Slice(mock.starts, func(i, j int) bool { return mock.starts[i].Before(mock.starts[j]) })
+
+	if gap := mock.starts[1].Sub(mock.starts[0]); gap < 35*time.Millisecond {
+		t.Errorf("expected concurrent requests to start MinInterval apart, gap was %v", gap)
+	}
+}
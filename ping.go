@@ -0,0 +1,40 @@
+package overpass
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// pingQuery is a trivial query every Overpass API instance answers quickly.
+const pingQuery = "[out:json];out count;"
+
+// ErrNoGenerator is returned by Ping when the response lacks a generator field.
+var ErrNoGenerator = errors.New("overpass: response has no generator")
+
+// Ping runs a trivial query against the endpoint and returns the server's
+// generator string (e.g. "Overpass API 0.7.62.1 084b4234"), identifying the
+// Overpass version. It bypasses the cache and retries, so an unreachable or
+// failing endpoint is reported right away.
+func (c *Client) Ping(ctx context.Context) (string, error) {
+	body, err := c.httpPost(ctx, pingQuery)
+	if err != nil {
+		return "", fmt.Errorf("ping %s: %w", c.apiEndpoint, err)
+	}
+
+	var response struct {
+		Generator string `json:"generator"`
+	}
+
+	err = json.Unmarshal(body, &response)
+	if err != nil {
+		return "", fmt.Errorf("ping %s: %w", c.apiEndpoint, err)
+	}
+
+	if response.Generator == "" {
+		return "", fmt.Errorf("ping %s: %w", c.apiEndpoint, ErrNoGenerator)
+	}
+
+	return response.Generator, nil
+}
@@ -0,0 +1,49 @@
+package overpass
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+)
+
+func TestPing(t *testing.T) {
+	t.Parallel()
+
+	body := `{"version":0.6,"generator":"Overpass API 0.7.62.1 084b4234","osm3s":{},"elements":[{"type":"count","id":0}]}`
+	mock := &mockRecordingHTTPClient{bodies: []string{body}}
+	client := NewWithSettings(apiEndpoint, 1, mock)
+
+	version, err := client.Ping(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if version != "Overpass API 0.7.62.1 084b4234" {
+		t.Errorf("unexpected version %q", version)
+	}
+
+	if queries := mock.recordedQueries(); len(queries) != 1 || queries[0] != pingQuery {
+		t.Errorf("unexpected queries: %v", queries)
+	}
+}
+
+func TestPingErrors(t *testing.T) {
+	t.Parallel()
+
+	refused := &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+
+	client := NewWithSettings(apiEndpoint, 1, &mockHTTPClient{err: refused})
+
+	_, err := client.Ping(context.Background())
+	if !errors.Is(err, refused) {
+		t.Errorf("expected connection error, got %v", err)
+	}
+
+	client = NewWithSettings(apiEndpoint, 1, &mockRecordingHTTPClient{bodies: []string{`{"elements":[]}`}})
+
+	_, err = client.Ping(context.Background())
+	if !errors.Is(err, ErrNoGenerator) {
+		t.Errorf("expected ErrNoGenerator, got %v", err)
+	}
+}
@@ -0,0 +1,263 @@
+package overpass
+
+// Polygon is an area assembled from a multipolygon relation: one closed outer
+// ring and the closed inner rings (holes) lying inside it.
+type Polygon struct {
+	Outer  []Point
+	Inners [][]Point
+}
+
+// Polygons assembles the relation's member ways into closed rings. Member ways
+// with role "outer" (or an empty role) form outer rings, "inner" ways form holes;
+// ways split into several segments are joined end to end. Every inner ring is
+// attached to the first outer ring containing it. Rings that cannot be closed
+// are dropped, so incomplete relations yield fewer (or no) polygons.
+//
+// Rings follow the right-hand rule used by GeoJSON: outer rings are
+// counter-clockwise and holes clockwise, whatever the order of the OSM data.
+func (r *Relation) Polygons() []Polygon {
+	var outerPaths, innerPaths [][]Point
+
+	for _, member := range r.Members {
+		if member.Way == nil {
+			continue
+		}
+
+		path := member.Way.path()
+		if len(path) < 2 {
+			continue
+		}
+
+		switch member.Role {
+		case "outer", "":
+			outerPaths = append(outerPaths, path)
+		case "inner":
+			innerPaths = append(innerPaths, path)
+		}
+	}
+
+	outers := assembleRings(outerPaths)
+	polygons := make([]Polygon, len(outers))
+
+	for i, outer := range outers {
+		polygons[i].Outer = orientRing(outer, false)
+	}
+
+	for _, inner := range assembleRings(innerPaths) {
+		inner = orientRing(inner, true)
+
+		for i := range polygons {
+			if ringContains(polygons[i].Outer, inner[0]) {
+				polygons[i].Inners = append(polygons[i].Inners, inner)
+				break
+			}
+		}
+	}
+
+	return polygons
+}
+
+// ContainsPoint reports whether p lies inside the closed way.
+// Open ways never contain a point. The way is assumed to be a simple polygon
+// (see IsSimplePolygon); for self-intersecting rings the even-odd rule applies.
+func (w *Way) ContainsPoint(p Point) bool {
+	if !w.IsClosed() {
+		return false
+	}
+
+	return ringContains(w.path(), p)
+}
+
+// IsSimplePolygon reports whether the way is a closed ring whose edges meet
+// only at shared vertices of neighbouring edges, i.e. no two non-adjacent
+// edges cross or touch. Bow-ties and figure-eights are not simple.
+func (w *Way) IsSimplePolygon() bool {
+	if !w.IsClosed() {
+		return false
+	}
+
+	ring := w.path()
+	edges := len(ring) - 1
+
+	for i := 0; i < edges; i++ {
+		for j := i + 2; j < edges; j++ {
+			if i == 0 && j == edges-1 {
+				continue // first and last edge share the closing vertex
+			}
+
+			if segmentsIntersect(ring[i], ring[i+1], ring[j], ring[j+1]) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// segmentsIntersect reports whether the segments ab and cd cross or touch,
+// treating longitude as x and latitude as y.
+func segmentsIntersect(a, b, c, d Point) bool {
+	o1 := orientation(a, b, c)
+	o2 := orientation(a, b, d)
+	o3 := orientation(c, d, a)
+	o4 := orientation(c, d, b)
+
+	if o1 != o2 && o3 != o4 {
+		return true
+	}
+
+	// Collinear cases: an end point lies on the other segment
+	return (o1 == 0 && onSegment(a, c, b)) ||
+		(o2 == 0 && onSegment(a, d, b)) ||
+		(o3 == 0 && onSegment(c, a, d)) ||
+		(o4 == 0 && onSegment(c, b, d))
+}
+
+// orientation returns 1 if a, b, c turn counter-clockwise, -1 if clockwise
+// and 0 if they are collinear.
+func orientation(a, b, c Point) int {
+	cross := (b.Lon-a.Lon)*(c.Lat-a.Lat) - (b.Lat-a.Lat)*(c.Lon-a.Lon)
+
+	switch {
+	case cross > 0:
+		return 1
+	case cross < 0:
+		return -1
+	default:
+		return 0
+	}
+}
+
+// onSegment reports whether q, collinear with p and r, lies between them.
+func onSegment(p, q, r Point) bool {
+	return q.Lon >= min(p.Lon, r.Lon) && q.Lon <= max(p.Lon, r.Lon) &&
+		q.Lat >= min(p.Lat, r.Lat) && q.Lat <= max(p.Lat, r.Lat)
+}
+
+// ContainsPoint reports whether p lies inside one of the relation's polygons
+// and outside that polygon's holes.
+func (r *Relation) ContainsPoint(p Point) bool {
+	for _, polygon := range r.Polygons() {
+		if polygon.contains(p) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (poly *Polygon) contains(p Point) bool {
+	if !ringContains(poly.Outer, p) {
+		return false
+	}
+
+	for _, inner := range poly.Inners {
+		if ringContains(inner, p) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ringContains tests p against a closed ring using ray casting, treating
+// longitude as x and latitude as y.
+func ringContains(ring []Point, p Point) bool {
+	inside := false
+
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		a, b := ring[i], ring[j]
+		if (a.Lat > p.Lat) != (b.Lat > p.Lat) &&
+			p.Lon < (b.Lon-a.Lon)*(p.Lat-a.Lat)/(b.Lat-a.Lat)+a.Lon {
+			inside = !inside
+		}
+	}
+
+	return inside
+}
+
+// assembleRings joins paths sharing end points into closed rings.
+// Paths that cannot be closed are discarded.
+func assembleRings(paths [][]Point) [][]Point {
+	remaining := make([][]Point, len(paths))
+	copy(remaining, paths)
+
+	var rings [][]Point
+
+	for len(remaining) > 0 {
+		ring := append([]Point(nil), remaining[0]...)
+		remaining = remaining[1:]
+
+		for !isClosedPath(ring) {
+			next, ok := takeConnecting(&remaining, ring[len(ring)-1])
+			if !ok {
+				break
+			}
+
+			ring = append(ring, next[1:]...)
+		}
+
+		if isClosedPath(ring) {
+			rings = append(rings, ring)
+		}
+	}
+
+	return rings
+}
+
+// takeConnecting removes and returns the first path starting or ending at p,
+// oriented so that it starts at p.
+func takeConnecting(paths *[][]Point, p Point) ([]Point, bool) {
+	for i, path := range *paths {
+		var oriented []Point
+
+		switch p {
+		case path[0]:
+			oriented = path
+		case path[len(path)-1]:
+			oriented = reversePath(path)
+		default:
+			continue
+		}
+
+		*paths = append((*paths)[:i], (*paths)[i+1:]...)
+
+		return oriented, true
+	}
+
+	return nil, false
+}
+
+// ringIsClockwise reports whether the closed ring winds clockwise, using the
+// sign of its shoelace area with longitude as x and latitude as y.
+func ringIsClockwise(points []Point) bool {
+	sum := 0.0
+	for i := 0; i+1 < len(points); i++ {
+		sum += points[i].Lon*points[i+1].Lat - points[i+1].Lon*points[i].Lat
+	}
+
+	return sum < 0
+}
+
+// orientRing returns ring wound clockwise or counter-clockwise as requested,
+// reversing a copy if needed.
+func orientRing(ring []Point, clockwise bool) []Point {
+	if ringIsClockwise(ring) == clockwise {
+		return ring
+	}
+
+	return reversePath(ring)
+}
+
+func isClosedPath(path []Point) bool {
+	return len(path) >= 4 && path[0] == path[len(path)-1]
+}
+
+func reversePath(path []Point) []Point {
+	reversed := make([]Point, len(path))
+	for i, p := range path {
+		reversed[len(path)-1-i] = p
+	}
+
+	return reversed
+}
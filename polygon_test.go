@@ -0,0 +1,174 @@
+package overpass
+
+import (
+	"testing"
+)
+
+// squareRelation is a 10x10 square (split into two outer ways) with a 2x2 hole.
+func squareRelation() *Relation {
+	return &Relation{
+		Meta: Meta{ID: 1, Tags: map[string]string{"type": "multipolygon"}},
+		Members: []RelationMember{
+			{Type: ElementTypeWay, Role: "outer", Way: &Way{Geometry: []Point{{0, 0}, {0, 10}, {10, 10}}}},
+			// Second half stored in reverse direction
+			{Type: ElementTypeWay, Role: "outer", Way: &Way{Geometry: []Point{{0, 0}, {10, 0}, {10, 10}}}},
+			{Type: ElementTypeWay, Role: "inner", Way: &Way{Geometry: []Point{{4, 4}, {4, 6}, {6, 6}, {6, 4}, {4, 4}}}},
+			{Type: ElementTypeNode, Role: "label", Node: &Node{Lat: 5, Lon: 5}},
+		},
+	}
+}
+
+func TestRelationPolygons(t *testing.T) {
+	t.Parallel()
+
+	polygons := squareRelation().Polygons()
+	if len(polygons) != 1 {
+		t.Fatalf("expected 1 polygon, got %d", len(polygons))
+	}
+
+	if len(polygons[0].Outer) != 5 {
+		t.Errorf("expected closed outer ring with 5 points, got %v", polygons[0].Outer)
+	}
+
+	if len(polygons[0].Inners) != 1 {
+		t.Errorf("expected 1 inner ring, got %d", len(polygons[0].Inners))
+	}
+
+	// An unclosable ring is dropped
+	open := &Relation{Members: []RelationMember{
+		{Type: ElementTypeWay, Role: "outer", Way: &Way{Geometry: []Point{{0, 0}, {0, 1}, {1, 1}}}},
+	}}
+
+	if polygons := open.Polygons(); len(polygons) != 0 {
+		t.Errorf("expected no polygons for open ring, got %v", polygons)
+	}
+}
+
+func TestRelationContainsPoint(t *testing.T) {
+	t.Parallel()
+
+	relation := squareRelation()
+
+	testCases := []struct {
+		name     string
+		point    Point
+		expected bool
+	}{
+		{"inside", Point{Lat: 2, Lon: 2}, true},
+		{"outside", Point{Lat: 12, Lon: 5}, false},
+		{"inside hole", Point{Lat: 5, Lon: 5}, false},
+		{"between hole and edge", Point{Lat: 5, Lon: 8}, true},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := relation.ContainsPoint(tc.point); got != tc.expected {
+				t.Errorf("ContainsPoint(%v) = %v, want %v", tc.point, got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestWayContainsPoint(t *testing.T) {
+	t.Parallel()
+
+	square := &Way{Geometry: []Point{{0, 0}, {0, 10}, {10, 10}, {10, 0}, {0, 0}}}
+
+	if !square.ContainsPoint(Point{Lat: 5, Lon: 5}) {
+		t.Error("expected point inside square")
+	}
+
+	if square.ContainsPoint(Point{Lat: 5, Lon: 15}) {
+		t.Error("expected point outside square")
+	}
+
+	open := &Way{Geometry: []Point{{0, 0}, {0, 10}, {10, 10}, {10, 0}}}
+	if open.ContainsPoint(Point{Lat: 5, Lon: 5}) {
+		t.Error("open way should not contain points")
+	}
+}
+
+func TestWayIsSimplePolygon(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		geometry []Point
+		expected bool
+	}{
+		{"square", []Point{{0, 0}, {0, 10}, {10, 10}, {10, 0}, {0, 0}}, true},
+		{"triangle", []Point{{0, 0}, {0, 10}, {10, 5}, {0, 0}}, true},
+		{"concave", []Point{{0, 0}, {0, 10}, {5, 5}, {10, 10}, {10, 0}, {0, 0}}, true},
+		{"bow-tie", []Point{{0, 0}, {10, 10}, {10, 0}, {0, 10}, {0, 0}}, false},
+		{"figure-eight touching at a vertex", []Point{{0, 0}, {5, 5}, {10, 0}, {10, 10}, {5, 5}, {0, 10}, {0, 0}}, false},
+		{"edge doubling back", []Point{{0, 0}, {0, 10}, {0, 5}, {5, 5}, {0, 0}}, false},
+		{"open way", []Point{{0, 0}, {0, 10}, {10, 10}, {10, 0}}, false},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			way := &Way{Geometry: tc.geometry}
+			if got := way.IsSimplePolygon(); got != tc.expected {
+				t.Errorf("expected %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestRingIsClockwise(t *testing.T) {
+	t.Parallel()
+
+	ccw := []Point{{0, 0}, {0, 1}, {1, 1}, {1, 0}, {0, 0}} // east, north, west in lon/lat
+	if ringIsClockwise(ccw) {
+		t.Error("expected counter-clockwise ring")
+	}
+
+	if !ringIsClockwise(reversePath(ccw)) {
+		t.Error("expected reversed ring to be clockwise")
+	}
+}
+
+func TestRelationPolygonsWinding(t *testing.T) {
+	t.Parallel()
+
+	// Outer ring wound clockwise, hole counter-clockwise: both mis-wound
+	relation := &Relation{Members: []RelationMember{
+		{Type: ElementTypeWay, Role: "outer", Way: &Way{Geometry: []Point{{0, 0}, {10, 0}, {10, 10}, {0, 10}, {0, 0}}}},
+		{Type: ElementTypeWay, Role: "inner", Way: &Way{Geometry: []Point{{4, 4}, {4, 6}, {6, 6}, {6, 4}, {4, 4}}}},
+	}}
+
+	polygons := relation.Polygons()
+	if len(polygons) != 1 || len(polygons[0].Inners) != 1 {
+		t.Fatalf("expected one polygon with a hole, got %v", polygons)
+	}
+
+	outer, inner := polygons[0].Outer, polygons[0].Inners[0]
+
+	if ringIsClockwise(outer) {
+		t.Errorf("expected counter-clockwise outer ring, got %v", outer)
+	}
+
+	if !ringIsClockwise(inner) {
+		t.Errorf("expected clockwise inner ring, got %v", inner)
+	}
+
+	if expected := (Point{0, 10}); outer[1] != expected {
+		t.Errorf("expected reversed outer ring to continue at %v, got %v", expected, outer[1])
+	}
+
+	if expected := (Point{6, 4}); inner[1] != expected {
+		t.Errorf("expected reversed inner ring to continue at %v, got %v", expected, inner[1])
+	}
+
+	// The member way itself is left untouched
+	if relation.Members[0].Way.Geometry[1] != (Point{10, 0}) {
+		t.Error("member way geometry must not be modified")
+	}
+}
@@ -1,61 +1,152 @@
 package overpass
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
 )
 
+// ErrEmptyQuery is returned when a query is empty or contains only whitespace.
+var ErrEmptyQuery = errors.New("overpass: empty query")
+
+// ErrElementNotFound is returned when a requested element is missing from
+// the response, e.g. because it does not exist.
+var ErrElementNotFound = errors.New("overpass: element not found")
+
+// ErrEmptyResponse is returned when the server answers with an empty or
+// whitespace-only body. Flaky mirrors do this occasionally, so it is retried.
+var ErrEmptyResponse = errors.New("overpass: empty response body")
+
 type overpassResponse struct {
 	OSM3S struct {
-		TimestampOSMBase time.Time `json:"timestamp_osm_base"`
+		TimestampOSMBase   string `json:"timestamp_osm_base"`
+		TimestampAreasBase string `json:"timestamp_areas_base"`
 	} `json:"osm3s"`
 	Elements []overpassResponseElement `json:"elements"`
 }
 
 type overpassResponseElement struct {
-	Type      ElementType `json:"type"`
-	ID        int64       `json:"id"`
-	Lat       float64     `json:"lat"`
-	Lon       float64     `json:"lon"`
-	Timestamp *time.Time  `json:"timestamp"`
-	Version   int64       `json:"version"`
-	Changeset int64       `json:"changeset"`
-	User      string      `json:"user"`
-	UID       int64       `json:"uid"`
-	Nodes     []int64     `json:"nodes"`
-	Members   []struct {
-		Type     ElementType `json:"type"`
-		Ref      int64       `json:"ref"`
-		Role     string      `json:"role"`
-		Geometry []struct {
-			Lat float64 `json:"lat"`
-			Lon float64 `json:"lon"`
-		} `json:"geometry,omitempty"`
-	} `json:"members"`
-	Geometry []struct {
-		Lat float64 `json:"lat"`
-		Lon float64 `json:"lon"`
-	} `json:"geometry"`
-	Bounds *struct {
-		MinLat float64 `json:"minlat"`
-		MinLon float64 `json:"minlon"`
-		MaxLat float64 `json:"maxlat"`
-		MaxLon float64 `json:"maxlon"`
-	} `json:"bounds"`
-	Tags map[string]string `json:"tags"`
+	Type      ElementType       `json:"type"`
+	ID        int64             `json:"id"`
+	Lat       float64           `json:"lat"`
+	Lon       float64           `json:"lon"`
+	Timestamp *time.Time        `json:"timestamp"`
+	Version   int64             `json:"version"`
+	Changeset int64             `json:"changeset"`
+	User      string            `json:"user"`
+	UID       int64             `json:"uid"`
+	Nodes     nodeRefs          `json:"nodes"`
+	Members   []overpassMember  `json:"members"`
+	Geometry  []*geometryPoint  `json:"geometry"`
+	Bounds    *overpassBounds   `json:"bounds"`
+	Center    *Point            `json:"center"`
+	Tags      map[string]string `json:"tags"`
+}
+
+// overpassMember is a relation member as sent by the server.
+type overpassMember struct {
+	Type     ElementType      `json:"type"`
+	Ref      int64            `json:"ref"`
+	Role     string           `json:"role"`
+	Geometry []*geometryPoint `json:"geometry,omitempty"`
+}
+
+// overpassBounds is the bounding box of an element sent with "out bb".
+type overpassBounds struct {
+	MinLat float64 `json:"minlat"`
+	MinLon float64 `json:"minlon"`
+	MaxLat float64 `json:"maxlat"`
+	MaxLon float64 `json:"maxlon"`
+}
+
+// nodeRef is a single entry of a way's "nodes" array.
+type nodeRef struct {
+	ID        int64
+	Lat       float64
+	Lon       float64
+	HasCoords bool
+}
+
+// nodeRefs decodes a way's "nodes" array given either as bare ids ([1,2,3])
+// or as objects carrying coordinates ([{"id":1,"lat":..,"lon":..}]).
+type nodeRefs []nodeRef
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (n *nodeRefs) UnmarshalJSON(data []byte) error {
+	var raw []json.RawMessage
+
+	err := json.Unmarshal(data, &raw)
+	if err != nil {
+		return fmt.Errorf("node refs: %w", err)
+	}
+
+	refs := make(nodeRefs, len(raw))
+
+	for idx, item := range raw {
+		item = bytes.TrimSpace(item)
+		if len(item) > 0 && item[0] == '{' {
+			var obj struct {
+				ID  int64    `json:"id"`
+				Lat *float64 `json:"lat"`
+				Lon *float64 `json:"lon"`
+			}
+
+			err = json.Unmarshal(item, &obj)
+			if err != nil {
+				return fmt.Errorf("node refs: %w", err)
+			}
+
+			refs[idx].ID = obj.ID
+			if obj.Lat != nil && obj.Lon != nil {
+				refs[idx].Lat = *obj.Lat
+				refs[idx].Lon = *obj.Lon
+				refs[idx].HasCoords = true
+			}
+
+			continue
+		}
+
+		err = json.Unmarshal(item, &refs[idx].ID)
+		if err != nil {
+			return fmt.Errorf("node refs: %w", err)
+		}
+	}
+
+	*n = refs
+
+	return nil
 }
 
 // httpPost sends HTTP POST request with context support.
 func (c *Client) httpPost(ctx context.Context, query string) ([]byte, error) {
-	<-c.semaphore
+	// Fail fast on a cancelled context so no slot or request is touched
+	err := ctx.Err()
+	if err != nil {
+		return nil, fmt.Errorf("http error: %w", err)
+	}
+
+	err = c.semaphore.acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("http error: %w", err)
+	}
 
-	defer func() { c.semaphore <- struct{}{} }()
+	defer c.semaphore.release()
+
+	err = c.pacer.wait(ctx, c.MinInterval)
+	if err != nil {
+		return nil, fmt.Errorf("http error: %w", err)
+	}
+
+	defer c.pacer.done()
 
 	// Create POST request with context
 	data := url.Values{"data": []string{query}}
@@ -68,6 +159,14 @@ func (c *Client) httpPost(ctx context.Context, query string) ([]byte, error) {
 
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
+	if c.userAgent != "" {
+		req.Header.Set("User-Agent", c.userAgent)
+	}
+
+	for key, values := range c.headers {
+		req.Header[key] = append([]string(nil), values...)
+	}
+
 	// Use Do instead of PostForm to support context
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
@@ -81,11 +180,25 @@ func (c *Client) httpPost(ctx context.Context, query string) ([]byte, error) {
 		}
 	}()
 
-	body, err := io.ReadAll(resp.Body)
+	var reader io.Reader = resp.Body
+	if c.MaxResponseBytes > 0 {
+		// Read one byte past the limit to detect oversized bodies
+		reader = io.LimitReader(resp.Body, c.MaxResponseBytes+1)
+	}
+
+	body, err := io.ReadAll(reader)
 	if err != nil {
 		return nil, fmt.Errorf("http error: %w", err)
 	}
 
+	if c.adaptive != nil {
+		c.adaptive.observe(c.semaphore, resp.StatusCode)
+	}
+
+	if c.MaxResponseBytes > 0 && int64(len(body)) > c.MaxResponseBytes {
+		return nil, fmt.Errorf("http error: %w", &ResponseTooLargeError{Limit: c.MaxResponseBytes})
+	}
+
 	if resp.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("overpass engine error: %w", &ServerError{resp.StatusCode, body})
 	}
@@ -93,38 +206,121 @@ func (c *Client) httpPost(ctx context.Context, query string) ([]byte, error) {
 	return body, nil
 }
 
+// fetch sends query and decodes the response. Empty bodies are detected by
+// the decoder, so they surface as ErrEmptyResponse to the retry logic.
+func (c *Client) fetch(ctx context.Context, query string) (Result, error) {
+	body, err := c.httpPost(ctx, query)
+	if err != nil {
+		return Result{}, err
+	}
+
+	return unmarshalWith(body, decodeOptions{keepRaw: c.KeepRawJSON})
+}
+
+// decodeOptions controls optional parsing behavior.
+type decodeOptions struct {
+	keepRaw bool // record each element's source JSON in Result.Raw (JSON responses only)
+}
+
 func unmarshal(body []byte) (Result, error) {
+	return unmarshalWith(body, decodeOptions{})
+}
+
+func unmarshalWith(body []byte, opts decodeOptions) (Result, error) {
 	var overpassRes overpassResponse
 
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) == 0 {
+		return Result{}, fmt.Errorf("overpass engine error: %w", ErrEmptyResponse)
+	}
+
+	// [out:xml] responses start with the XML declaration or <osm>
+	if trimmed[0] == '<' {
+		return unmarshalXML(body)
+	}
+
 	err := json.Unmarshal(body, &overpassRes)
 	if err != nil {
 		return Result{}, fmt.Errorf("overpass engine error: %w", err)
 	}
 
 	result := Result{
-		Timestamp: overpassRes.OSM3S.TimestampOSMBase,
-		Count:     len(overpassRes.Elements),
-		Nodes:     make(map[int64]*Node),
-		Ways:      make(map[int64]*Way),
-		Relations: make(map[int64]*Relation),
+		Timestamp:          parseDataTimestamp(overpassRes.OSM3S.TimestampOSMBase),
+		DataTimestampRaw:   overpassRes.OSM3S.TimestampOSMBase,
+		TimestampAreasBase: parseDataTimestamp(overpassRes.OSM3S.TimestampAreasBase),
+		Count:              len(overpassRes.Elements),
+		Nodes:              make(map[int64]*Node),
+		Ways:               make(map[int64]*Way),
+		Relations:          make(map[int64]*Relation),
 	}
 
 	for _, element := range overpassRes.Elements {
-		meta := buildMeta(element)
-
-		switch element.Type {
-		case ElementTypeNode:
-			unmarshalNode(&result, element, meta)
-		case ElementTypeWay:
-			unmarshalWay(&result, element, meta)
-		case ElementTypeRelation:
-			unmarshalRelation(&result, element, meta)
+		result.addElement(element)
+	}
+
+	if opts.keepRaw {
+		result.Raw, err = rawElements(body, overpassRes.Elements)
+		if err != nil {
+			return Result{}, err
 		}
 	}
 
 	return result, nil
 }
 
+// addElement adds a decoded element to the result.
+func (r *Result) addElement(element overpassResponseElement) {
+	meta := buildMeta(element)
+
+	switch element.Type {
+	case ElementTypeNode:
+		unmarshalNode(r, element, meta)
+	case ElementTypeWay:
+		unmarshalWay(r, element, meta)
+	case ElementTypeRelation:
+		unmarshalRelation(r, element, meta)
+	case ElementTypeArea:
+		unmarshalArea(r, meta)
+	case ElementTypeCount:
+		r.Counts = append(r.Counts, parseElementCount(element.Tags))
+	}
+}
+
+// rawElements returns the source JSON of every element keyed by elementKey.
+func rawElements(body []byte, elements []overpassResponseElement) (map[string]json.RawMessage, error) {
+	var rawRes struct {
+		Elements []json.RawMessage `json:"elements"`
+	}
+
+	err := json.Unmarshal(body, &rawRes)
+	if err != nil {
+		return nil, fmt.Errorf("overpass engine error: %w", err)
+	}
+
+	raw := make(map[string]json.RawMessage, len(elements))
+	for i, element := range elements {
+		raw[elementKey(element.Type, element.ID)] = rawRes.Elements[i]
+	}
+
+	return raw, nil
+}
+
+// elementKey identifies an element as "type/id", e.g. "node/1".
+func elementKey(elemType ElementType, id int64) string {
+	return fmt.Sprintf("%s/%d", elemType, id)
+}
+
+// parseDataTimestamp parses timestamp_osm_base, returning the zero time if it
+// is missing or malformed.
+func parseDataTimestamp(raw string) time.Time {
+	ts, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		return time.Time{}
+	}
+
+	return ts
+}
+
 func buildMeta(element overpassResponseElement) Meta {
 	return Meta{
 		ID:        element.ID,
@@ -146,27 +342,81 @@ func unmarshalNode(result *Result, element overpassResponseElement, meta Meta) {
 	}
 }
 
+func unmarshalArea(result *Result, meta Meta) {
+	if result.Areas == nil {
+		result.Areas = make(map[int64]*Area)
+	}
+
+	result.Areas[meta.ID] = &Area{Meta: meta}
+}
+
+// parseElementCount reads the tags of a count element; missing or invalid
+// numbers stay zero.
+func parseElementCount(tags map[string]string) ElementCount {
+	count := func(key string) int {
+		n, _ := strconv.Atoi(tags[key])
+		return n
+	}
+
+	return ElementCount{
+		Nodes:     count("nodes"),
+		Ways:      count("ways"),
+		Relations: count("relations"),
+		Areas:     count("areas"),
+		Total:     count("total"),
+	}
+}
+
 func unmarshalWay(result *Result, element overpassResponseElement, meta Meta) {
 	way := result.getWay(element.ID)
 
 	*way = Way{
-		Meta:     meta,
-		Nodes:    make([]*Node, len(element.Nodes)),
-		Geometry: make([]Point, len(element.Geometry)),
+		Meta:  meta,
+		Nodes: make([]*Node, len(element.Nodes)),
 	}
 
-	for idx, nodeID := range element.Nodes {
-		way.Nodes[idx] = result.getNode(nodeID)
+	for idx, ref := range element.Nodes {
+		node := result.getNode(ref.ID)
+		// Inline coordinates only fill in nodes not (yet) returned as elements
+		if ref.HasCoords && node.Lat == 0 && node.Lon == 0 {
+			node.Lat = ref.Lat
+			node.Lon = ref.Lon
+		}
+
+		way.Nodes[idx] = node
 	}
 
 	if element.Bounds != nil {
 		way.Bounds = buildBounds(element.Bounds)
 	}
 
-	for idx, geo := range element.Geometry {
-		way.Geometry[idx].Lat = geo.Lat
-		way.Geometry[idx].Lon = geo.Lon
+	way.Center = element.Center
+	way.Geometry, way.HasCompleteGeometry = decodeGeometry(element.Geometry)
+}
+
+// geometryPoint is an entry of an inline "geometry" array. Entries for nodes
+// clipped by the query bbox may be null or lack coordinates.
+type geometryPoint struct {
+	Lat *float64 `json:"lat"`
+	Lon *float64 `json:"lon"`
+}
+
+// decodeGeometry converts inline geometry to points, dropping null entries and
+// entries without valid coordinates. complete reports whether there was at
+// least one entry and none had to be dropped.
+func decodeGeometry(entries []*geometryPoint) (points []Point, complete bool) {
+	points = make([]Point, 0, len(entries))
+
+	for _, entry := range entries {
+		if entry == nil || entry.Lat == nil || entry.Lon == nil ||
+			math.Abs(*entry.Lat) > 90 || math.Abs(*entry.Lon) > 180 {
+			continue
+		}
+
+		points = append(points, Point{Lat: *entry.Lat, Lon: *entry.Lon})
 	}
+
+	return points, len(entries) > 0 && len(points) == len(entries)
 }
 
 func unmarshalRelation(result *Result, element overpassResponseElement, meta Meta) {
@@ -184,18 +434,11 @@ func unmarshalRelation(result *Result, element overpassResponseElement, meta Met
 	if element.Bounds != nil {
 		relation.Bounds = buildBounds(element.Bounds)
 	}
+
+	relation.Center = element.Center
 }
 
-func buildRelationMember(result *Result, member struct {
-	Type     ElementType `json:"type"`
-	Ref      int64       `json:"ref"`
-	Role     string      `json:"role"`
-	Geometry []struct {
-		Lat float64 `json:"lat"`
-		Lon float64 `json:"lon"`
-	} `json:"geometry,omitempty"`
-},
-) RelationMember {
+func buildRelationMember(result *Result, member overpassMember) RelationMember {
 	relationMember := RelationMember{
 		Type: member.Type,
 		Role: member.Role,
@@ -211,10 +454,7 @@ func buildRelationMember(result *Result, member struct {
 		// This is needed for multipolygon relations where member ways may not be
 		// returned as separate elements but have their geometry embedded in the relation
 		if len(member.Geometry) > 0 && len(way.Geometry) == 0 {
-			way.Geometry = make([]Point, len(member.Geometry))
-			for i, g := range member.Geometry {
-				way.Geometry[i] = Point{Lat: g.Lat, Lon: g.Lon}
-			}
+			way.Geometry, way.HasCompleteGeometry = decodeGeometry(member.Geometry)
 		}
 	case ElementTypeRelation:
 		relationMember.Relation = result.getRelation(member.Ref)
@@ -223,13 +463,7 @@ func buildRelationMember(result *Result, member struct {
 	return relationMember
 }
 
-func buildBounds(boundsData *struct {
-	MinLat float64 `json:"minlat"`
-	MinLon float64 `json:"minlon"`
-	MaxLat float64 `json:"maxlat"`
-	MaxLon float64 `json:"maxlon"`
-},
-) *Box {
+func buildBounds(boundsData *overpassBounds) *Box {
 	return &Box{
 		Min: Point{
 			Lat: boundsData.MinLat,
@@ -261,3 +495,23 @@ type ServerError struct {
 func (e *ServerError) Error() string {
 	return fmt.Sprintf("%d %s", e.StatusCode, http.StatusText(e.StatusCode))
 }
+
+// ResponseTooLargeError is returned when a response body exceeds Client.MaxResponseBytes.
+type ResponseTooLargeError struct {
+	Limit int64
+}
+
+func (e *ResponseTooLargeError) Error() string {
+	return fmt.Sprintf("response body exceeds %d bytes", e.Limit)
+}
+
+// TooManyElementsError is returned when a result holds more elements than
+// Client.MaxElements.
+type TooManyElementsError struct {
+	Count int
+	Limit int
+}
+
+func (e *TooManyElementsError) Error() string {
+	return fmt.Sprintf("result has %d elements, exceeding the limit of %d", e.Count, e.Limit)
+}
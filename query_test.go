@@ -2,13 +2,19 @@ package overpass
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"reflect"
+	"strings"
+	"sync"
 	"testing"
 	"testing/iotest"
+	"time"
+	"unicode/utf8"
 )
 
 func TestUnmarshal(t *testing.T) {
@@ -36,7 +42,8 @@ func TestUnmarshal(t *testing.T) {
 			Result{
 				Count: 1,
 				Ways: map[int64]*Way{1: {
-					Geometry: []Point{{-37.9, 144.6}, {-37.8, 144.7}},
+					Geometry:            []Point{{-37.9, 144.6}, {-37.8, 144.7}},
+					HasCompleteGeometry: true,
 				}},
 			},
 		},
@@ -73,6 +80,98 @@ func TestUnmarshal(t *testing.T) {
 	}
 }
 
+func TestUnmarshalCenter(t *testing.T) {
+	t.Parallel()
+
+	result, err := unmarshal([]byte(`{"elements":[
+		{"type":"way","id":1,"center":{"lat":52.5,"lon":13.4}},
+		{"type":"relation","id":2,"center":{"lat":48.1,"lon":11.5}}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c := result.Ways[1].Center; c == nil || *c != (Point{52.5, 13.4}) {
+		t.Errorf("unexpected way center: %v", c)
+	}
+
+	if c := result.Relations[2].Center; c == nil || *c != (Point{48.1, 11.5}) {
+		t.Errorf("unexpected relation center: %v", c)
+	}
+}
+
+func TestUnmarshalWayNodeRefs(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name  string
+		input string
+		want  []Point
+	}{
+		{
+			"bare ids",
+			`{"elements":[
+				{"type":"node","id":1,"lat":1.5,"lon":2.5},
+				{"type":"way","id":10,"nodes":[1,2]}
+			]}`,
+			[]Point{{1.5, 2.5}, {0, 0}},
+		},
+		{
+			"objects with coordinates",
+			`{"elements":[
+				{"type":"way","id":10,"nodes":[{"id":1,"lat":1.5,"lon":2.5},{"id":2,"lat":3.5,"lon":4.5}]}
+			]}`,
+			[]Point{{1.5, 2.5}, {3.5, 4.5}},
+		},
+		{
+			"objects without coordinates",
+			`{"elements":[{"type":"way","id":10,"nodes":[{"id":1},{"id":2}]}]}`,
+			[]Point{{0, 0}, {0, 0}},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			result, err := unmarshal([]byte(tc.input))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			way := result.Ways[10]
+			if len(way.Nodes) != len(tc.want) {
+				t.Fatalf("expected %d nodes, got %d", len(tc.want), len(way.Nodes))
+			}
+
+			for i, node := range way.Nodes {
+				if node.ID != int64(i+1) {
+					t.Errorf("node %d: expected id %d, got %d", i, i+1, node.ID)
+				}
+
+				if node != result.Nodes[node.ID] {
+					t.Errorf("node %d not linked to result map", i)
+				}
+
+				if (Point{node.Lat, node.Lon}) != tc.want[i] {
+					t.Errorf("node %d: expected %v, got %v,%v", i, tc.want[i], node.Lat, node.Lon)
+				}
+			}
+		})
+	}
+}
+
+func TestUnmarshalWayNodeRefsInvalid(t *testing.T) {
+	t.Parallel()
+
+	_, err := unmarshal([]byte(`{"elements":[{"type":"way","id":10,"nodes":["x"]}]}`))
+	if err == nil {
+		t.Fatal("expected error for invalid node reference")
+	}
+}
+
 func normalizeResult(result *Result) {
 	normalizeNodes(result)
 	normalizeWays(result)
@@ -149,7 +248,7 @@ func TestQueryErrors(t *testing.T) {
 			"http error: read fail",
 		},
 		{
-			&http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewReader(nil))},
+			&http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("{"))},
 			nil,
 			"overpass engine error: unexpected end of JSON input",
 		},
@@ -163,7 +262,7 @@ func TestQueryErrors(t *testing.T) {
 
 			cli := NewWithSettings(apiEndpoint, 1, &mockHTTPClient{testCase.res, testCase.err})
 
-			_, err := cli.Query("")
+			_, err := cli.Query("[out:json];node(1);out;")
 			if err == nil {
 				t.Fatal("unexpected success")
 			}
@@ -179,6 +278,175 @@ func TestQueryErrors(t *testing.T) {
 	}
 }
 
+func TestEmptyQuery(t *testing.T) {
+	t.Parallel()
+
+	for _, query := range []string{"", "  \n\t "} {
+		mock := &mockRecordingHTTPClient{}
+		cli := NewWithSettings(apiEndpoint, 1, mock)
+
+		_, err := cli.QueryContext(context.Background(), query)
+		if !errors.Is(err, ErrEmptyQuery) {
+			t.Errorf("query %q: expected ErrEmptyQuery, got %v", query, err)
+		}
+
+		if n := len(mock.recordedQueries()); n != 0 {
+			t.Errorf("query %q: expected no requests, got %d", query, n)
+		}
+	}
+}
+
+func TestMaxResponseBytes(t *testing.T) {
+	t.Parallel()
+
+	body := `{"elements":[{"type":"node","id":1,"lat":1.0,"lon":2.0}]}`
+
+	t.Run("over limit", func(t *testing.T) {
+		t.Parallel()
+
+		cli := NewWithSettings(apiEndpoint, 1, &mockHTTPClient{
+			res: &http.Response{StatusCode: http.StatusOK, Body: newTestBody(body)},
+		})
+		cli.MaxResponseBytes = 16
+
+		_, err := cli.Query("[out:json];node(1);out;")
+
+		var tooLarge *ResponseTooLargeError
+		if !errors.As(err, &tooLarge) {
+			t.Fatalf("expected ResponseTooLargeError, got %v", err)
+		}
+
+		if tooLarge.Limit != 16 {
+			t.Errorf("expected limit 16, got %d", tooLarge.Limit)
+		}
+
+		if err.Error() != "http error: response body exceeds 16 bytes" {
+			t.Errorf("unexpected message: %s", err.Error())
+		}
+	})
+
+	t.Run("under limit", func(t *testing.T) {
+		t.Parallel()
+
+		cli := NewWithSettings(apiEndpoint, 1, &mockHTTPClient{
+			res: &http.Response{StatusCode: http.StatusOK, Body: newTestBody(body)},
+		})
+		cli.MaxResponseBytes = int64(len(body))
+
+		result, err := cli.Query("[out:json];node(1);out;")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if result.Count != 1 {
+			t.Errorf("expected count 1, got %d", result.Count)
+		}
+	})
+}
+
+func TestUnmarshalInvalidUTF8(t *testing.T) {
+	t.Parallel()
+
+	body := []byte("{\"elements\":[{\"type\":\"node\",\"id\":1,\"lat\":1,\"lon\":2," +
+		"\"user\":\"b\xe4r\",\"tags\":{\"name\":\"Caf\xe9 \\udc00\",\"k\xff\":\"v\"}}]}")
+
+	result, err := unmarshal(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	node := result.Nodes[1]
+
+	expected := map[string]string{"name": "Caf\ufffd \ufffd", "k\ufffd": "v"}
+	if !reflect.DeepEqual(node.Tags, expected) {
+		t.Errorf("expected invalid bytes replaced, got %q", node.Tags)
+	}
+
+	if node.User != "b\ufffdr" {
+		t.Errorf("expected sanitized user, got %q", node.User)
+	}
+
+	var geoJSON bytes.Buffer
+
+	err = writeGeoJSON(&geoJSON, &result, DefaultCoordinatePrecision)
+	if err != nil || !utf8.Valid(geoJSON.Bytes()) {
+		t.Errorf("expected valid UTF-8 GeoJSON, got %q (%v)", geoJSON.String(), err)
+	}
+}
+
+func TestUnmarshalCounts(t *testing.T) {
+	t.Parallel()
+
+	result, err := unmarshal([]byte(`{"elements":[` +
+		`{"type":"count","id":0,"tags":{"nodes":"3","ways":"1","relations":"0","total":"4"}},` +
+		`{"type":"count","id":0,"tags":{"nodes":"2","ways":"0","relations":"0","areas":"1","total":"3"}}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []ElementCount{
+		{Nodes: 3, Ways: 1, Total: 4},
+		{Nodes: 2, Areas: 1, Total: 3},
+	}
+	if !reflect.DeepEqual(result.Counts, expected) {
+		t.Errorf("expected %+v, got %+v", expected, result.Counts)
+	}
+
+	if len(result.Nodes) != 0 {
+		t.Errorf("expected count elements not to become nodes, got %d", len(result.Nodes))
+	}
+}
+
+func TestMaxElements(t *testing.T) {
+	t.Parallel()
+
+	body := `{"elements":[{"type":"node","id":1,"lat":1.0,"lon":2.0},{"type":"node","id":2,"lat":1.0,"lon":2.0}]}`
+
+	testCases := []struct {
+		name    string
+		limit   int
+		wantErr bool
+	}{
+		{"over limit", 1, true},
+		{"at limit", 2, false},
+		{"unlimited", 0, false},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			cli := NewWithSettings(apiEndpoint, 1, &mockHTTPClient{
+				res: &http.Response{StatusCode: http.StatusOK, Body: newTestBody(body)},
+			})
+			cli.MaxElements = tc.limit
+
+			result, err := cli.Query("[out:json];node;out;")
+			if !tc.wantErr {
+				if err != nil || result.Count != 2 {
+					t.Fatalf("expected 2 elements, got %d (%v)", result.Count, err)
+				}
+
+				return
+			}
+
+			var tooMany *TooManyElementsError
+			if !errors.As(err, &tooMany) {
+				t.Fatalf("expected TooManyElementsError, got %v", err)
+			}
+
+			if tooMany.Count != 2 || tooMany.Limit != 1 {
+				t.Errorf("unexpected error fields %+v", tooMany)
+			}
+
+			if err.Error() != "result has 2 elements, exceeding the limit of 1" {
+				t.Errorf("unexpected message: %s", err.Error())
+			}
+		})
+	}
+}
+
 type mockHTTPClient struct {
 	res *http.Response
 	err error
@@ -188,7 +456,316 @@ func (m *mockHTTPClient) Do(_ *http.Request) (*http.Response, error) {
 	return m.res, m.err
 }
 
+// mockRecordingHTTPClient records submitted queries and replays bodies in order.
+// The last body is repeated once the list is exhausted.
+type mockRecordingHTTPClient struct {
+	mu      sync.Mutex
+	bodies  []string
+	queries []string
+	headers []http.Header
+}
+
+func (m *mockRecordingHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	raw, err := io.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	values, err := url.ParseQuery(string(raw))
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.queries = append(m.queries, values.Get("data"))
+	m.headers = append(m.headers, req.Header.Clone())
+
+	body := `{"elements":[]}`
+	if n := len(m.bodies); n > 0 {
+		body = m.bodies[min(len(m.queries)-1, n-1)]
+	}
+
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       newTestBody(body),
+	}, nil
+}
+
+// recordedQueries returns a copy of the queries seen so far.
+func (m *mockRecordingHTTPClient) recordedQueries() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]string(nil), m.queries...)
+}
+
 // newTestBody creates an io.ReadCloser from a string for testing.
 func newTestBody(s string) io.ReadCloser {
 	return io.NopCloser(bytes.NewReader([]byte(s)))
 }
+
+func TestUnmarshalEmptyBody(t *testing.T) {
+	t.Parallel()
+
+	for _, body := range []string{"", "  \n\t"} {
+		_, err := unmarshal([]byte(body))
+		if !errors.Is(err, ErrEmptyResponse) {
+			t.Errorf("body %q: expected ErrEmptyResponse, got %v", body, err)
+		}
+	}
+}
+
+func TestUnmarshalDataTimestamp(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		body     string
+		wantTime time.Time
+		wantRaw  string
+	}{
+		{
+			"valid",
+			`{"osm3s":{"timestamp_osm_base":"2024-02-09T12:30:00Z"},"elements":[]}`,
+			time.Date(2024, 2, 9, 12, 30, 0, 0, time.UTC),
+			"2024-02-09T12:30:00Z",
+		},
+		{
+			"missing",
+			`{"osm3s":{},"elements":[]}`,
+			time.Time{},
+			"",
+		},
+		{
+			"malformed",
+			`{"osm3s":{"timestamp_osm_base":"2024-02-09 12:30 UTC"},"elements":[]}`,
+			time.Time{},
+			"2024-02-09 12:30 UTC",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			result, err := unmarshal([]byte(tc.body))
+			if err != nil {
+				t.Fatalf("unmarshal failed: %v", err)
+			}
+
+			if !result.Timestamp.Equal(tc.wantTime) {
+				t.Errorf("expected Timestamp %v, got %v", tc.wantTime, result.Timestamp)
+			}
+
+			if result.DataTimestampRaw != tc.wantRaw {
+				t.Errorf("expected DataTimestampRaw %q, got %q", tc.wantRaw, result.DataTimestampRaw)
+			}
+		})
+	}
+}
+
+func TestKeepRawJSON(t *testing.T) {
+	t.Parallel()
+
+	body := `{"elements":[{"type":"node","id":1,"lat":1.5,"lon":2.5,"extra":"field"},{"type":"way","id":2,"nodes":[1]}]}`
+
+	client := NewWithSettings(apiEndpoint, 1, &mockRecordingHTTPClient{bodies: []string{body}})
+	client.KeepRawJSON = true
+
+	result, err := client.QueryContext(context.Background(), "[out:json];node(1);out;")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	raw, ok := result.RawJSON(ElementTypeNode, 1)
+	if !ok {
+		t.Fatal("expected raw JSON for node 1")
+	}
+
+	if string(raw) != `{"type":"node","id":1,"lat":1.5,"lon":2.5,"extra":"field"}` {
+		t.Errorf("unexpected raw JSON: %s", raw)
+	}
+
+	if _, ok := result.RawJSON(ElementTypeWay, 2); !ok {
+		t.Error("expected raw JSON for way 2")
+	}
+
+	if _, ok := result.RawJSON(ElementTypeRelation, 1); ok {
+		t.Error("unexpected raw JSON for missing relation")
+	}
+}
+
+func TestKeepRawJSONDisabled(t *testing.T) {
+	t.Parallel()
+
+	body := `{"elements":[{"type":"node","id":1,"lat":1.5,"lon":2.5}]}`
+
+	client := NewWithSettings(apiEndpoint, 1, &mockRecordingHTTPClient{bodies: []string{body}})
+
+	result, err := client.QueryContext(context.Background(), "[out:json];node(1);out;")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if result.Raw != nil {
+		t.Errorf("expected nil raw map, got %v", result.Raw)
+	}
+
+	if _, ok := result.RawJSON(ElementTypeNode, 1); ok {
+		t.Error("unexpected raw JSON without KeepRawJSON")
+	}
+}
+
+func TestUnmarshalBBOutput(t *testing.T) {
+	t.Parallel()
+
+	body := []byte(`{"elements":[
+		{"type":"way","id":10,"bounds":{"minlat":52.1,"minlon":13.1,"maxlat":52.2,"maxlon":13.3},"tags":{"highway":"primary"}},
+		{"type":"relation","id":20,"bounds":{"minlat":48.0,"minlon":11.0,"maxlat":48.5,"maxlon":11.5}}
+	]}`)
+
+	result, err := unmarshal(body)
+	if err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+
+	way := result.Ways[10]
+	if way.Bounds == nil {
+		t.Fatal("expected way bounds")
+	}
+
+	expectedWay := Box{Min: Point{Lat: 52.1, Lon: 13.1}, Max: Point{Lat: 52.2, Lon: 13.3}}
+	if *way.Bounds != expectedWay {
+		t.Errorf("expected way bounds %+v, got %+v", expectedWay, *way.Bounds)
+	}
+
+	if len(way.Nodes) != 0 || len(way.Geometry) != 0 {
+		t.Errorf("bb output should carry no geometry, got %+v", way)
+	}
+
+	relation := result.Relations[20]
+	expectedRelation := Box{Min: Point{Lat: 48.0, Lon: 11.0}, Max: Point{Lat: 48.5, Lon: 11.5}}
+
+	if relation.Bounds == nil || *relation.Bounds != expectedRelation {
+		t.Errorf("expected relation bounds %+v, got %+v", expectedRelation, relation.Bounds)
+	}
+}
+
+func TestQueryCancelledContext(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		occupied bool
+	}{
+		{"free slot", false},
+		{"no free slot", true},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			mock := &mockRecordingHTTPClient{}
+			client := NewWithSettings(apiEndpoint, 1, mock)
+
+			if tc.occupied {
+				err := client.semaphore.acquire(context.Background())
+				if err != nil {
+					t.Fatalf("acquire failed: %v", err)
+				}
+
+				defer client.semaphore.release()
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			_, err := client.QueryContext(ctx, "[out:json];node(1);out;")
+			if !errors.Is(err, context.Canceled) {
+				t.Errorf("expected context.Canceled, got %v", err)
+			}
+
+			if n := len(mock.recordedQueries()); n != 0 {
+				t.Errorf("expected no requests, got %d", n)
+			}
+		})
+	}
+}
+
+func TestUnmarshalClippedGeometry(t *testing.T) {
+	t.Parallel()
+
+	body := []byte(`{"elements":[
+		{"type":"way","id":1,"geometry":[{"lat":1,"lon":1},null,{"lat":2,"lon":2},{},{"lat":91,"lon":0}]},
+		{"type":"way","id":2,"geometry":[{"lat":1,"lon":1},{"lat":0,"lon":0}]},
+		{"type":"way","id":3},
+		{"type":"relation","id":4,"members":[
+			{"type":"way","ref":5,"role":"outer","geometry":[null,{"lat":3,"lon":3}]}
+		]}
+	]}`)
+
+	result, err := unmarshal(body)
+	if err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+
+	clipped := result.Ways[1]
+	if len(clipped.Geometry) != 2 || clipped.Geometry[1] != (Point{2, 2}) {
+		t.Errorf("expected null and invalid entries dropped, got %v", clipped.Geometry)
+	}
+
+	if clipped.HasCompleteGeometry {
+		t.Error("expected clipped way to be incomplete")
+	}
+
+	if !result.Ways[2].HasCompleteGeometry || len(result.Ways[2].Geometry) != 2 {
+		t.Errorf("expected complete geometry for way 2, got %+v", result.Ways[2])
+	}
+
+	if result.Ways[3].HasCompleteGeometry {
+		t.Error("expected way without geometry to be incomplete")
+	}
+
+	member := result.Ways[5]
+	if member.HasCompleteGeometry || len(member.Geometry) != 1 {
+		t.Errorf("expected incomplete member geometry with one point, got %+v", member)
+	}
+}
+
+func TestUnmarshalAreasTimestamp(t *testing.T) {
+	t.Parallel()
+
+	body := []byte(`{"osm3s":{
+		"timestamp_osm_base":"2024-02-09T12:30:00Z",
+		"timestamp_areas_base":"2024-02-08T23:05:00Z"
+	},"elements":[]}`)
+
+	result, err := unmarshal(body)
+	if err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+
+	if want := time.Date(2024, 2, 9, 12, 30, 0, 0, time.UTC); !result.Timestamp.Equal(want) {
+		t.Errorf("expected data timestamp %v, got %v", want, result.Timestamp)
+	}
+
+	if want := time.Date(2024, 2, 8, 23, 5, 0, 0, time.UTC); !result.TimestampAreasBase.Equal(want) {
+		t.Errorf("expected areas timestamp %v, got %v", want, result.TimestampAreasBase)
+	}
+
+	result, err = unmarshal([]byte(`{"osm3s":{"timestamp_osm_base":"2024-02-09T12:30:00Z"},"elements":[]}`))
+	if err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+
+	if !result.TimestampAreasBase.IsZero() {
+		t.Errorf("expected zero areas timestamp, got %v", result.TimestampAreasBase)
+	}
+}
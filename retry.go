@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"math"
 	"math/rand"
+	"net"
 	"time"
 )
 
@@ -16,6 +17,12 @@ type RetryConfig struct {
 	MaxBackoff        time.Duration // Maximum backoff duration (default: 30s)
 	BackoffMultiplier float64       // Backoff multiplier (default: 2.0)
 	Jitter            bool          // Add randomization to prevent thundering herd (default: true)
+	TotalBudget       time.Duration // Cap on total time across all attempts and backoffs (0 = unlimited)
+
+	// RetryableError decides whether a failure without an HTTP status (e.g. a
+	// connection reset) is retried. Nil retries net.Error timeouts and
+	// temporary errors.
+	RetryableError func(error) bool
 }
 
 // DefaultRetryConfig returns sensible defaults.
@@ -38,6 +45,39 @@ func isRetryableStatus(statusCode int) bool {
 		statusCode == 504 // Gateway Timeout
 }
 
+// isTransientNetworkError reports whether err is a network timeout or
+// temporary failure. Context cancellation is never considered transient.
+func isTransientNetworkError(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var netErr net.Error
+	if !errors.As(err, &netErr) {
+		return false
+	}
+
+	return netErr.Timeout() || netErr.Temporary() //nolint:staticcheck // SA1019: Temporary still flags transient resolver and accept errors
+}
+
+// isRetryableError determines if a failed attempt warrants retry.
+func (config RetryConfig) isRetryableError(err error) bool {
+	var serverErr *ServerError
+	if errors.As(err, &serverErr) {
+		return isRetryableStatus(serverErr.StatusCode)
+	}
+
+	if errors.Is(err, ErrEmptyResponse) {
+		return true
+	}
+
+	if config.RetryableError != nil {
+		return config.RetryableError(err)
+	}
+
+	return isTransientNetworkError(err)
+}
+
 // calculateBackoff computes next backoff duration.
 func calculateBackoff(attempt int, config RetryConfig) time.Duration {
 	backoff := float64(config.InitialBackoff) * math.Pow(config.BackoffMultiplier, float64(attempt))
@@ -55,48 +95,53 @@ func calculateBackoff(attempt int, config RetryConfig) time.Duration {
 	return time.Duration(backoff)
 }
 
-// retryableHTTPPost wraps httpPost with retry logic.
-func (c *Client) retryableHTTPPost(ctx context.Context, query string) ([]byte, error) {
+// retryableFetch wraps fetch with the retry logic of config.
+func (c *Client) retryableFetch(ctx context.Context, query string, config RetryConfig) (Result, error) {
 	var lastErr error
 
-	for attempt := 0; attempt <= c.retryConfig.MaxRetries; attempt++ {
+	start := time.Now()
+
+	for attempt := 0; attempt <= config.MaxRetries; attempt++ {
 		// Check context before attempting
 		err := ctx.Err()
 		if err != nil {
-			return nil, fmt.Errorf("context error: %w", err)
+			return Result{}, fmt.Errorf("context error: %w", err)
 		}
 
-		body, err := c.httpPost(ctx, query)
+		result, err := c.fetch(ctx, query)
 
 		// Success - return immediately
 		if err == nil {
-			return body, nil
+			return result, nil
 		}
 
 		// Check if error is retryable
-		var serverErr *ServerError
-		isServerErr := errors.As(err, &serverErr)
-
-		if !isServerErr || !isRetryableStatus(serverErr.StatusCode) {
+		if !config.isRetryableError(err) {
 			// Not retryable - return error immediately
-			return nil, err
+			return Result{}, err
 		}
 
 		lastErr = err
 
 		// Don't sleep after last attempt
-		if attempt < c.retryConfig.MaxRetries {
-			backoff := calculateBackoff(attempt, c.retryConfig)
+		if attempt < config.MaxRetries {
+			backoff := calculateBackoff(attempt, config)
+
+			// Give up early rather than sleep past the total budget
+			budget := config.TotalBudget
+			if budget > 0 && time.Since(start)+backoff > budget {
+				return Result{}, fmt.Errorf("retry budget of %s exhausted: %w", budget, lastErr)
+			}
 
 			// Sleep with context awareness
 			select {
 			case <-time.After(backoff):
 				// Continue to next attempt
 			case <-ctx.Done():
-				return nil, fmt.Errorf("context cancelled: %w", ctx.Err())
+				return Result{}, fmt.Errorf("context cancelled: %w", ctx.Err())
 			}
 		}
 	}
 
-	return nil, fmt.Errorf("max retries exceeded: %w", lastErr)
+	return Result{}, fmt.Errorf("max retries exceeded: %w", lastErr)
 }
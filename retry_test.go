@@ -341,3 +341,199 @@ func TestRetryDifferentStatusCodes(t *testing.T) {
 		})
 	}
 }
+
+type transientNetError struct{}
+
+func (transientNetError) Error() string   { return "connection reset by peer" }
+func (transientNetError) Timeout() bool   { return false }
+func (transientNetError) Temporary() bool { return true }
+
+// Mock client that returns a transport error N times then succeeds.
+type networkFailingMockClient struct {
+	failCount int
+	attempts  int
+	err       error
+}
+
+func (m *networkFailingMockClient) Do(_ *http.Request) (*http.Response, error) {
+	m.attempts++
+
+	if m.attempts <= m.failCount {
+		return nil, m.err
+	}
+
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(bytes.NewReader([]byte(`{"osm3s":{},"elements":[]}`))),
+	}, nil
+}
+
+func TestRetryTransientNetworkError(t *testing.T) {
+	t.Parallel()
+
+	mock := &networkFailingMockClient{failCount: 1, err: transientNetError{}}
+
+	client := NewWithSettings(apiEndpoint, 1, mock)
+	client.retryConfig = RetryConfig{MaxRetries: 3, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+
+	_, err := client.QueryContext(context.Background(), "[out:json];node(1);out;")
+	if err != nil {
+		t.Fatalf("expected success after retry, got error: %v", err)
+	}
+
+	if mock.attempts != 2 {
+		t.Errorf("expected 2 attempts, got %d", mock.attempts)
+	}
+}
+
+func TestRetryableErrorPredicate(t *testing.T) {
+	t.Parallel()
+
+	permanent := errors.New("tls: bad certificate")
+	flaky := errors.New("flaky proxy")
+
+	testCases := []struct {
+		name      string
+		err       error
+		predicate func(error) bool
+		attempts  int
+	}{
+		{"permanent error not retried", permanent, nil, 1},
+		{"transient error retried", transientNetError{}, nil, 2},
+		{"custom predicate retries", flaky, func(err error) bool { return errors.Is(err, flaky) }, 2},
+		{"custom predicate rejects", transientNetError{}, func(error) bool { return false }, 1},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			mock := &networkFailingMockClient{failCount: 1, err: tc.err}
+
+			client := NewWithSettings(apiEndpoint, 1, mock)
+			client.retryConfig = RetryConfig{
+				MaxRetries:     2,
+				InitialBackoff: time.Millisecond,
+				MaxBackoff:     time.Millisecond,
+				RetryableError: tc.predicate,
+			}
+
+			_, _ = client.QueryContext(context.Background(), "[out:json];node(1);out;")
+
+			if mock.attempts != tc.attempts {
+				t.Errorf("expected %d attempts, got %d", tc.attempts, mock.attempts)
+			}
+		})
+	}
+}
+
+func TestIsTransientNetworkError(t *testing.T) {
+	t.Parallel()
+
+	if !isTransientNetworkError(fmt.Errorf("http error: %w", transientNetError{})) {
+		t.Error("wrapped temporary net.Error should be transient")
+	}
+
+	if isTransientNetworkError(context.Canceled) {
+		t.Error("context cancellation should not be transient")
+	}
+
+	if isTransientNetworkError(errors.New("boom")) {
+		t.Error("plain error should not be transient")
+	}
+}
+
+// Mock client that returns 200 with an empty body N times then succeeds.
+type emptyBodyMockClient struct {
+	failCount int
+	attempts  int
+}
+
+func (m *emptyBodyMockClient) Do(_ *http.Request) (*http.Response, error) {
+	m.attempts++
+
+	body := " \n"
+	if m.attempts > m.failCount {
+		body = `{"osm3s":{},"elements":[{"type":"node","id":1}]}`
+	}
+
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(strings.NewReader(body)),
+	}, nil
+}
+
+func TestRetryEmptyResponse(t *testing.T) {
+	t.Parallel()
+
+	mock := &emptyBodyMockClient{failCount: 2}
+
+	client := NewWithSettings(apiEndpoint, 1, mock)
+	client.retryConfig = RetryConfig{MaxRetries: 3, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+
+	result, err := client.QueryContext(context.Background(), "[out:json];node(1);out;")
+	if err != nil {
+		t.Fatalf("expected success after retries, got error: %v", err)
+	}
+
+	if mock.attempts != 3 {
+		t.Errorf("expected 3 attempts, got %d", mock.attempts)
+	}
+
+	if result.Count != 1 {
+		t.Errorf("expected 1 element, got %d", result.Count)
+	}
+}
+
+func TestRetryEmptyResponseExhaustion(t *testing.T) {
+	t.Parallel()
+
+	mock := &emptyBodyMockClient{failCount: 10}
+
+	client := NewWithSettings(apiEndpoint, 1, mock)
+	client.retryConfig = RetryConfig{MaxRetries: 2, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+
+	_, err := client.QueryContext(context.Background(), "[out:json];node(1);out;")
+	if !errors.Is(err, ErrEmptyResponse) {
+		t.Fatalf("expected ErrEmptyResponse, got %v", err)
+	}
+
+	if mock.attempts != 3 {
+		t.Errorf("expected 3 attempts (initial + 2 retries), got %d", mock.attempts)
+	}
+}
+
+func TestRetryTotalBudget(t *testing.T) {
+	t.Parallel()
+
+	mock := &failingMockClient{failCount: 10, statusCode: 503}
+
+	client := NewWithSettings(apiEndpoint, 1, mock)
+	client.retryConfig = RetryConfig{
+		MaxRetries:        10,
+		InitialBackoff:    20 * time.Millisecond,
+		MaxBackoff:        time.Second,
+		BackoffMultiplier: 2.0,
+		TotalBudget:       100 * time.Millisecond,
+	}
+
+	start := time.Now()
+	_, err := client.QueryContext(context.Background(), "[out:json];node(1);out;")
+	elapsed := time.Since(start)
+
+	var serverErr *ServerError
+	if !errors.As(err, &serverErr) || serverErr.StatusCode != 503 {
+		t.Fatalf("expected last server error, got %v", err)
+	}
+
+	// Backoffs of 20ms and 40ms fit the budget, the following 80ms does not
+	if mock.currentFail != 3 {
+		t.Errorf("expected 3 attempts within budget, got %d", mock.currentFail)
+	}
+
+	if elapsed > 100*time.Millisecond {
+		t.Errorf("retries exceeded budget: %v", elapsed)
+	}
+}
@@ -0,0 +1,71 @@
+package overpass
+
+import "strings"
+
+// RoutePolyline returns the coordinates of a route relation (bus, train,
+// hiking, ...) as a single list in route direction. It concatenates the
+// RouteSegments, so across a gap the polyline jumps straight to the next
+// segment; use RouteSegments to tell the pieces apart.
+func (r *Relation) RoutePolyline() []Point {
+	var points []Point
+
+	for _, segment := range r.RouteSegments() {
+		points = append(points, segment...)
+	}
+
+	return points
+}
+
+// RouteSegments returns the continuous pieces of a route relation. Member ways
+// are chained in member order, each reversed where needed so it starts where
+// the previous one ended; the first way is oriented by the second. A way that
+// does not touch its predecessor starts a new segment. Node members, ways
+// without geometry and stop or platform members are skipped.
+func (r *Relation) RouteSegments() [][]Point {
+	var (
+		segments [][]Point
+		current  []Point
+		single   bool // current holds a single way, whose direction may still flip
+	)
+
+	for _, member := range r.Members {
+		if member.Way == nil || strings.HasPrefix(member.Role, "stop") || strings.HasPrefix(member.Role, "platform") {
+			continue
+		}
+
+		path := member.Way.path()
+		if len(path) < 2 {
+			continue
+		}
+
+		if len(current) == 0 {
+			current, single = append([]Point(nil), path...), true
+
+			continue
+		}
+
+		first, last := path[0], path[len(path)-1]
+
+		end := current[len(current)-1]
+		if single && end != first && end != last && (first == current[0] || last == current[0]) {
+			current = reversePath(current)
+			end = current[len(current)-1]
+		}
+
+		switch end {
+		case first:
+			current, single = append(current, path[1:]...), false
+		case last:
+			current, single = append(current, reversePath(path)[1:]...), false
+		default:
+			segments = append(segments, current)
+			current, single = append([]Point(nil), path...), true
+		}
+	}
+
+	if len(current) > 0 {
+		segments = append(segments, current)
+	}
+
+	return segments
+}
@@ -0,0 +1,80 @@
+package overpass
+
+import (
+	"reflect"
+	"testing"
+)
+
+func routeWay(points ...Point) *Way {
+	return &Way{Geometry: points}
+}
+
+func TestRelationRoutePolyline(t *testing.T) {
+	t.Parallel()
+
+	// The second way is stored against the route direction
+	route := &Relation{Members: []RelationMember{
+		{Type: ElementTypeNode, Node: &Node{Lat: 9, Lon: 9}, Role: "stop"},
+		{Type: ElementTypeWay, Way: routeWay(Point{0, 0}, Point{0, 1}, Point{0, 2})},
+		{Type: ElementTypeWay, Way: routeWay(Point{2, 2}, Point{1, 2}, Point{0, 2})},
+		{Type: ElementTypeWay, Way: routeWay(Point{5, 5}, Point{5, 6}), Role: "platform"},
+	}}
+
+	expected := []Point{{0, 0}, {0, 1}, {0, 2}, {1, 2}, {2, 2}}
+	if got := route.RoutePolyline(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+
+	if route.Members[2].Way.Geometry[0] != (Point{2, 2}) {
+		t.Error("RoutePolyline must not modify member geometry")
+	}
+}
+
+func TestRelationRouteSegments(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		ways     []*Way
+		expected [][]Point
+	}{
+		{
+			name: "first way reversed",
+			ways: []*Way{
+				routeWay(Point{0, 2}, Point{0, 1}, Point{0, 0}),
+				routeWay(Point{0, 2}, Point{1, 2}),
+			},
+			expected: [][]Point{{{0, 0}, {0, 1}, {0, 2}, {1, 2}}},
+		},
+		{
+			name: "gap starts a new segment",
+			ways: []*Way{
+				routeWay(Point{0, 0}, Point{0, 1}),
+				routeWay(Point{3, 3}, Point{3, 4}),
+				routeWay(Point{3, 5}, Point{3, 4}),
+			},
+			expected: [][]Point{{{0, 0}, {0, 1}}, {{3, 3}, {3, 4}, {3, 5}}},
+		},
+		{
+			name:     "empty",
+			ways:     []*Way{{}},
+			expected: nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			route := &Relation{}
+			for _, way := range tc.ways {
+				route.Members = append(route.Members, RelationMember{Type: ElementTypeWay, Way: way})
+			}
+
+			if got := route.RouteSegments(); !reflect.DeepEqual(got, tc.expected) {
+				t.Errorf("expected %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
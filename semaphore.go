@@ -0,0 +1,87 @@
+package overpass
+
+import (
+	"context"
+	"sync"
+)
+
+// semaphore is a resizable counting semaphore limiting parallel requests.
+// Lowering the limit never interrupts in-flight requests; new acquisitions
+// simply wait until the number of active holders drops below the new limit.
+type semaphore struct {
+	mu     sync.Mutex
+	limit  int
+	active int
+	notify chan struct{} // closed and replaced whenever a slot may have become available
+}
+
+// newSemaphore creates a semaphore allowing limit concurrent holders.
+func newSemaphore(limit int) *semaphore {
+	return &semaphore{
+		limit:  limit,
+		notify: make(chan struct{}),
+	}
+}
+
+// acquire blocks until a slot is available or the context is done.
+func (s *semaphore) acquire(ctx context.Context) error {
+	for {
+		s.mu.Lock()
+		if s.active < s.limit {
+			s.active++
+			s.mu.Unlock()
+
+			return nil
+		}
+
+		notify := s.notify
+		s.mu.Unlock()
+
+		select {
+		case <-notify:
+			// Slot may be free, try again
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// release frees a slot acquired by acquire.
+func (s *semaphore) release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.active--
+	s.broadcast()
+}
+
+// resize changes the number of concurrent holders allowed.
+func (s *semaphore) resize(limit int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.limit = limit
+	s.broadcast()
+}
+
+// capacity returns the current limit.
+func (s *semaphore) capacity() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.limit
+}
+
+// available returns the number of free slots.
+func (s *semaphore) available() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return max(s.limit-s.active, 0)
+}
+
+// broadcast wakes all waiters; must be called with mu held.
+func (s *semaphore) broadcast() {
+	close(s.notify)
+	s.notify = make(chan struct{})
+}
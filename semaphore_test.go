@@ -0,0 +1,100 @@
+package overpass
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSemaphoreAcquireRelease(t *testing.T) {
+	t.Parallel()
+
+	sem := newSemaphore(2)
+
+	for i := 0; i < 2; i++ {
+		err := sem.acquire(context.Background())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	if sem.available() != 0 {
+		t.Errorf("expected 0 available, got %d", sem.available())
+	}
+
+	// Third acquire must block until the context expires
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	err := sem.acquire(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected DeadlineExceeded, got %v", err)
+	}
+
+	sem.release()
+
+	if sem.available() != 1 {
+		t.Errorf("expected 1 available after release, got %d", sem.available())
+	}
+}
+
+func TestSemaphoreResizeWakesWaiters(t *testing.T) {
+	t.Parallel()
+
+	sem := newSemaphore(1)
+
+	err := sem.acquire(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	acquired := make(chan error, 1)
+
+	go func() {
+		acquired <- sem.acquire(context.Background())
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("acquire should block while limit is reached")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	sem.resize(2)
+
+	select {
+	case err := <-acquired:
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("waiter not woken after resize")
+	}
+}
+
+func TestSemaphoreShrinkKeepsInFlight(t *testing.T) {
+	t.Parallel()
+
+	sem := newSemaphore(3)
+
+	for i := 0; i < 3; i++ {
+		_ = sem.acquire(context.Background())
+	}
+
+	sem.resize(1)
+
+	// All three holders may still release; no new slot until active < 1
+	sem.release()
+	sem.release()
+
+	if sem.available() != 0 {
+		t.Errorf("expected 0 available with 1 active and limit 1, got %d", sem.available())
+	}
+
+	sem.release()
+
+	if sem.available() != 1 {
+		t.Errorf("expected 1 available, got %d", sem.available())
+	}
+}
@@ -0,0 +1,144 @@
+package overpass
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// UnmarshalStream parses an Overpass JSON response from r element by element,
+// without holding the whole body in memory, e.g. for large saved dumps.
+// Unlike UnmarshalStreamPartial it fails on the first malformed element.
+func UnmarshalStream(r io.Reader) (Result, error) {
+	result, _, err := decodeStream(r, false)
+
+	return result, err
+}
+
+// UnmarshalStreamPartial parses like UnmarshalStream but skips elements that
+// are valid JSON yet cannot be decoded (e.g. "lat":"north"). Their errors are
+// returned in document order, each naming the element's position, alongside
+// the result of all good elements, whose Count excludes the skipped ones.
+// Broken JSON syntax still ends parsing; the elements read up to that point
+// are returned together with the error.
+func UnmarshalStreamPartial(r io.Reader) (Result, []error, error) {
+	return decodeStream(r, true)
+}
+
+// decodeStream walks the top-level response object, decoding the elements
+// array one entry at a time.
+func decodeStream(r io.Reader, tolerant bool) (Result, []error, error) {
+	result := Result{
+		Nodes:     make(map[int64]*Node),
+		Ways:      make(map[int64]*Way),
+		Relations: make(map[int64]*Relation),
+	}
+
+	dec := json.NewDecoder(r)
+
+	err := expectDelim(dec, '{')
+	if errors.Is(err, io.EOF) {
+		return Result{}, nil, fmt.Errorf("overpass engine error: %w", ErrEmptyResponse)
+	}
+
+	if err != nil {
+		return Result{}, nil, err
+	}
+
+	var elementErrs []error
+
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return result, elementErrs, fmt.Errorf("overpass engine error: %w", err)
+		}
+
+		switch key {
+		case "osm3s":
+			var osm3s struct {
+				TimestampOSMBase   string `json:"timestamp_osm_base"`
+				TimestampAreasBase string `json:"timestamp_areas_base"`
+			}
+
+			err = dec.Decode(&osm3s)
+			if err != nil {
+				return result, elementErrs, fmt.Errorf("overpass engine error: %w", err)
+			}
+
+			result.Timestamp = parseDataTimestamp(osm3s.TimestampOSMBase)
+			result.DataTimestampRaw = osm3s.TimestampOSMBase
+			result.TimestampAreasBase = parseDataTimestamp(osm3s.TimestampAreasBase)
+		case "elements":
+			elementErrs, err = decodeStreamElements(dec, &result, tolerant)
+			if err != nil {
+				return result, elementErrs, err
+			}
+		default:
+			var skipped json.RawMessage
+
+			err = dec.Decode(&skipped)
+			if err != nil {
+				return result, elementErrs, fmt.Errorf("overpass engine error: %w", err)
+			}
+		}
+	}
+
+	return result, elementErrs, nil
+}
+
+// decodeStreamElements adds the entries of the elements array to result.
+func decodeStreamElements(dec *json.Decoder, result *Result, tolerant bool) ([]error, error) {
+	err := expectDelim(dec, '[')
+	if err != nil {
+		return nil, err
+	}
+
+	var elementErrs []error
+
+	for i := 0; dec.More(); i++ {
+		var raw json.RawMessage
+
+		err = dec.Decode(&raw)
+		if err != nil {
+			return elementErrs, fmt.Errorf("overpass engine error: %w", err)
+		}
+
+		var element overpassResponseElement
+
+		err = json.Unmarshal(raw, &element)
+		if err != nil {
+			err = fmt.Errorf("element %d: %w", i, err)
+			if !tolerant {
+				return elementErrs, fmt.Errorf("overpass engine error: %w", err)
+			}
+
+			elementErrs = append(elementErrs, err)
+
+			continue
+		}
+
+		result.addElement(element)
+		result.Count++
+	}
+
+	return elementErrs, expectDelim(dec, ']')
+}
+
+// expectDelim consumes the next token, which must be delim.
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+	token, err := dec.Token()
+	if errors.Is(err, io.EOF) {
+		return err
+	}
+
+	if err != nil {
+		return fmt.Errorf("overpass engine error: %w", err)
+	}
+
+	if token != delim {
+		return fmt.Errorf("overpass engine error: expected %v, got %v", delim, token)
+	}
+
+	return nil
+}
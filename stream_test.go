@@ -0,0 +1,92 @@
+package overpass
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+const streamBody = `{
+  "version": 0.6,
+  "generator": "Overpass API",
+  "osm3s": {"timestamp_osm_base": "2024-01-01T00:00:00Z"},
+  "elements": [
+    {"type": "node", "id": 1, "lat": 52.5, "lon": 13.4, "tags": {"amenity": "cafe"}},
+    {"type": "node", "id": 2, "lat": "north", "lon": 13.5},
+    {"type": "way", "id": 10, "nodes": [1, 3]},
+    {"type": "node", "id": 3, "lat": 52.6, "lon": 13.6}
+  ]
+}`
+
+func TestUnmarshalStream(t *testing.T) {
+	t.Parallel()
+
+	good := strings.Replace(streamBody, `"north"`, "52.55", 1)
+
+	result, err := UnmarshalStream(strings.NewReader(good))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected, err := unmarshal([]byte(good))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if result.Count != expected.Count || len(result.Nodes) != 3 || len(result.Ways) != 1 {
+		t.Errorf("expected %d elements, got %d (%d nodes, %d ways)",
+			expected.Count, result.Count, len(result.Nodes), len(result.Ways))
+	}
+
+	if !result.Timestamp.Equal(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected timestamp %v", result.Timestamp)
+	}
+
+	if result.Ways[10].Nodes[1] != result.Nodes[3] {
+		t.Error("expected way nodes to be linked to later nodes")
+	}
+
+	_, err = UnmarshalStream(strings.NewReader(streamBody))
+	if err == nil || !strings.Contains(err.Error(), "element 1") {
+		t.Errorf("expected error for element 1, got %v", err)
+	}
+
+	_, err = UnmarshalStream(strings.NewReader(""))
+	if !errors.Is(err, ErrEmptyResponse) {
+		t.Errorf("expected ErrEmptyResponse, got %v", err)
+	}
+}
+
+func TestUnmarshalStreamPartial(t *testing.T) {
+	t.Parallel()
+
+	result, elementErrs, err := UnmarshalStreamPartial(strings.NewReader(streamBody))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(elementErrs) != 1 || !strings.Contains(elementErrs[0].Error(), "element 1") {
+		t.Errorf("expected one error for element 1, got %v", elementErrs)
+	}
+
+	if result.Count != 3 || result.Nodes[1].Tags["amenity"] != "cafe" || result.Nodes[3].Lat != 52.6 {
+		t.Errorf("expected the good elements to be parsed, got %d: %+v", result.Count, result.Nodes)
+	}
+
+	if _, ok := result.Nodes[2]; ok {
+		t.Error("expected the malformed node to be skipped")
+	}
+
+	// Broken syntax stops parsing but keeps what was read
+	truncated := streamBody[:strings.Index(streamBody, `{"type": "way"`)]
+
+	result, _, err = UnmarshalStreamPartial(strings.NewReader(truncated))
+	if err == nil {
+		t.Fatal("expected error for truncated input")
+	}
+
+	if result.Count != 1 || result.Nodes[1] == nil {
+		t.Errorf("expected the element before the break, got %d", result.Count)
+	}
+}
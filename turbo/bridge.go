@@ -0,0 +1,179 @@
+package turbo
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/MeKo-Christian/go-overpass"
+)
+
+// QueryFromStylesheet derives an Overpass QL data query from the selectors of a
+// stylesheet, the way Overpass Turbo fetches just the data a style renders.
+//
+// Every selector with an object type and at least one tag condition becomes an
+// element clause restricted to bbox; the clauses are unioned and followed by
+// "out body;". "line" selectors map to ways, "area" selectors to ways and
+// multipolygon relations. Selectors without conditions (e.g. layer- or
+// pseudo-class-only ones) are skipped. It returns "" if no selector qualifies.
+func QueryFromStylesheet(s *Stylesheet, bbox overpass.BoundingBox) string {
+	return QueryFromStylesheetWith(s, bbox, Options{})
+}
+
+// QueryFromStylesheetWith is QueryFromStylesheet writing the bbox with the
+// coordinate precision of opts. Other options are ignored.
+func QueryFromStylesheetWith(s *Stylesheet, bbox overpass.BoundingBox, opts Options) string {
+	if s == nil {
+		return ""
+	}
+
+	precision := opts.precision()
+	bboxFilter := fmt.Sprintf("(%s,%s,%s,%s)",
+		formatFloat(bbox.South, precision), formatFloat(bbox.West, precision),
+		formatFloat(bbox.North, precision), formatFloat(bbox.East, precision))
+	seen := map[string]bool{}
+
+	var clauses []string
+
+	for _, rule := range s.Rules {
+		for i := range rule.Selectors {
+			for _, clause := range rule.Selectors[i].queryClauses() {
+				clause += bboxFilter + ";"
+				if !seen[clause] {
+					seen[clause] = true
+					clauses = append(clauses, clause)
+				}
+			}
+		}
+	}
+
+	if len(clauses) == 0 {
+		return ""
+	}
+
+	return "[out:json];(" + strings.Join(clauses, "") + ");out body;"
+}
+
+// queryClauses returns the Overpass QL element clauses (without bbox) matching
+// the selector, or nil if the selector cannot be turned into a query.
+func (sel *Selector) queryClauses() []string {
+	if len(sel.Conditions) == 0 {
+		return nil
+	}
+
+	var filters strings.Builder
+
+	for i := range sel.Conditions {
+		filters.WriteString(sel.Conditions[i].queryFilter())
+	}
+
+	switch sel.Type {
+	case osmTypeNode, osmTypeWay, osmTypeRelation:
+		return []string{sel.Type + filters.String()}
+	case "line":
+		return []string{osmTypeWay + filters.String()}
+	case "area":
+		return []string{
+			osmTypeWay + filters.String(),
+			osmTypeRelation + `["type"="multipolygon"]` + filters.String(),
+		}
+	default:
+		return nil
+	}
+}
+
+// queryFilter converts a MapCSS condition into an Overpass QL filter.
+func (c *Condition) queryFilter() string {
+	key := overpass.EscapeQL(c.Key)
+	value := overpass.EscapeQL(c.Value)
+
+	switch c.Operator {
+	case "":
+		return fmt.Sprintf(`["%s"]`, key)
+	case "!":
+		return fmt.Sprintf(`[!"%s"]`, key)
+	case "=", "!=":
+		return fmt.Sprintf(`["%s"%s"%s"]`, key, c.Operator, value)
+	case "=~", "!~":
+		pattern := strings.TrimSuffix(strings.TrimPrefix(c.Value, "/"), "/")
+
+		return fmt.Sprintf(`["%s"%s"%s"]`, key, strings.TrimPrefix(c.Operator, "="), overpass.EscapeQL(pattern))
+	default: // numeric comparison
+		return fmt.Sprintf(`(if:number(t["%s"])%s%s)`, key, c.Operator, value)
+	}
+}
+
+// BBoxFromBoundingBox converts an overpass.BoundingBox to a BBox.
+func BBoxFromBoundingBox(b overpass.BoundingBox) BBox {
+	return BBox{South: b.South, West: b.West, North: b.North, East: b.East}
+}
+
+// BoundingBox converts b to an overpass.BoundingBox for use with the query
+// builder.
+func (b BBox) BoundingBox() overpass.BoundingBox {
+	return overpass.BoundingBox{South: b.South, West: b.West, North: b.North, East: b.East}
+}
+
+// FilterByStylesheet returns a result holding only the elements of r for which
+// s yields at least one declaration at zoom, i.e. the elements the stylesheet
+// would draw. Ways are matched with their closed state so :closed and :area
+// selectors apply. Areas carry no geometry to style and are all kept. Elements
+// are not copied; a kept way's nodes stay reachable through Way.Nodes even
+// when the nodes themselves are filtered out.
+func FilterByStylesheet(r *overpass.Result, s *Stylesheet, zoom int) overpass.Result {
+	filtered := overpass.Result{
+		Timestamp:          r.Timestamp,
+		DataTimestampRaw:   r.DataTimestampRaw,
+		TimestampAreasBase: r.TimestampAreasBase,
+		Query:              r.Query,
+		Nodes:              map[int64]*overpass.Node{},
+		Ways:               map[int64]*overpass.Way{},
+		Relations:          map[int64]*overpass.Relation{},
+	}
+
+	if r.Areas != nil {
+		filtered.Areas = make(map[int64]*overpass.Area, len(r.Areas))
+		for id, area := range r.Areas {
+			filtered.Areas[id] = area
+		}
+	}
+
+	filtered.Count = len(filtered.Areas)
+
+	if s == nil {
+		return filtered
+	}
+
+	for id, node := range r.Nodes {
+		if s.draws(StyleElement{Type: "node", Tags: node.Tags}, zoom) {
+			filtered.Nodes[id] = node
+		}
+	}
+
+	for id, way := range r.Ways {
+		if s.draws(StyleElement{Type: "way", Tags: way.Tags, Closed: way.IsClosed()}, zoom) {
+			filtered.Ways[id] = way
+		}
+	}
+
+	for id, relation := range r.Relations {
+		if s.draws(StyleElement{Type: "relation", Tags: relation.Tags}, zoom) {
+			filtered.Relations[id] = relation
+		}
+	}
+
+	filtered.Count += len(filtered.Nodes) + len(filtered.Ways) + len(filtered.Relations)
+
+	return filtered
+}
+
+// draws reports whether el matches any declaration of s at zoom.
+func (s *Stylesheet) draws(el StyleElement, zoom int) bool {
+	return len(s.MatchElement(el, zoom)) > 0
+}
+
+// ExpandBuilder builds qb and expands any Overpass Turbo macros in the result,
+// so structured builders can carry placeholders such as {{bbox}} in Raw
+// statements.
+func ExpandBuilder(qb *overpass.QueryBuilder, opts Options) (Result, error) {
+	return Expand(qb.Build(), opts)
+}
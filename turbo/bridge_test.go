@@ -0,0 +1,180 @@
+package turbo
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/MeKo-Christian/go-overpass"
+)
+
+func TestQueryFromStylesheet(t *testing.T) {
+	t.Parallel()
+
+	sheet := mustParseMapCSS(t, `
+		way[highway=primary] { color: red; }
+		node[amenity=cafe], node[amenity=cafe][!name] { icon-image: url('cafe.png'); }
+	`)
+
+	bbox := overpass.BoundingBox{South: 52.5, West: 13.4, North: 52.6, East: 13.5}
+
+	expected := `[out:json];(` +
+		`way["highway"="primary"](52.5,13.4,52.6,13.5);` +
+		`node["amenity"="cafe"](52.5,13.4,52.6,13.5);` +
+		`node["amenity"="cafe"][!"name"](52.5,13.4,52.6,13.5);` +
+		`);out body;`
+
+	if got := QueryFromStylesheet(sheet, bbox); got != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, got)
+	}
+}
+
+func TestQueryFromStylesheetWithPrecision(t *testing.T) {
+	t.Parallel()
+
+	sheet := mustParseMapCSS(t, `node[amenity=cafe] { color: red; }`)
+	bbox := overpass.BoundingBox{South: 52.123456789, West: 13.4, North: 52.6, East: 13.5}
+
+	if got := QueryFromStylesheet(sheet, bbox); !strings.Contains(got, "(52.1234568,13.4,52.6,13.5)") {
+		t.Errorf("expected default precision, got %s", got)
+	}
+
+	if got := QueryFromStylesheetWith(sheet, bbox, Options{Precision: 3}); !strings.Contains(got, "(52.123,13.4,52.6,13.5)") {
+		t.Errorf("expected 3 decimals, got %s", got)
+	}
+}
+
+func TestQueryFromStylesheetSelectors(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			"area maps to ways and multipolygons",
+			"area[building] { fill-color: #ccc; }",
+			`[out:json];(way["building"](0,0,1,1);` +
+				`relation["type"="multipolygon"]["building"](0,0,1,1);` +
+				`);out body;`,
+		},
+		{
+			"line regex and numeric",
+			"line[highway=~/^(primary|secondary)$/][lanes>=2] { width: 4; }",
+			`[out:json];(way["highway"~"^(primary|secondary)$"](if:number(t["lanes"])>=2)` +
+				`(0,0,1,1););out body;`,
+		},
+		{
+			"duplicate selectors are emitted once",
+			"node[shop] { color: red; } node[shop]::label { text: name; }",
+			`[out:json];(node["shop"](0,0,1,1););out body;`,
+		},
+		{
+			"selectors without conditions are skipped",
+			"way::casing { width: 6; } node:hover { color: red; } canvas { fill-color: white; }",
+			"",
+		},
+	}
+
+	bbox := overpass.BoundingBox{North: 1, East: 1}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			sheet := mustParseMapCSS(t, tc.input)
+
+			if got := QueryFromStylesheet(sheet, bbox); got != tc.expected {
+				t.Errorf("expected:\n%s\ngot:\n%s", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestBBoxConversion(t *testing.T) {
+	t.Parallel()
+
+	bbox := overpass.BoundingBox{South: 52.5, West: 13.3, North: 52.6, East: 13.5}
+
+	converted := BBoxFromBoundingBox(bbox)
+	if converted != (BBox{South: 52.5, West: 13.3, North: 52.6, East: 13.5}) {
+		t.Errorf("unexpected BBox: %+v", converted)
+	}
+
+	if got := converted.BoundingBox(); got != bbox {
+		t.Errorf("round trip: expected %+v, got %+v", bbox, got)
+	}
+}
+
+func TestFilterByStylesheet(t *testing.T) {
+	t.Parallel()
+
+	sheet := mustParseMapCSS(t, `way[highway] { color: red; }`)
+
+	result := overpass.Result{
+		Nodes: map[int64]*overpass.Node{
+			1: {Meta: overpass.Meta{ID: 1, Tags: map[string]string{"amenity": "cafe"}}},
+			2: {Meta: overpass.Meta{ID: 2, Tags: map[string]string{"highway": "bus_stop"}}},
+		},
+		Ways: map[int64]*overpass.Way{
+			10: {Meta: overpass.Meta{ID: 10, Tags: map[string]string{"highway": "primary"}}},
+			11: {Meta: overpass.Meta{ID: 11, Tags: map[string]string{"building": "yes"}}},
+		},
+		Relations: map[int64]*overpass.Relation{
+			20: {Meta: overpass.Meta{ID: 20, Tags: map[string]string{"highway": "pedestrian"}}},
+		},
+		Areas: map[int64]*overpass.Area{
+			3600062422: {Meta: overpass.Meta{ID: 3600062422, Tags: map[string]string{"name": "Berlin"}}},
+		},
+	}
+
+	filtered := FilterByStylesheet(&result, sheet, 16)
+
+	if len(filtered.Nodes) != 0 || len(filtered.Relations) != 0 {
+		t.Errorf("expected nodes and relations dropped, got %+v", filtered)
+	}
+
+	if len(filtered.Ways) != 1 || filtered.Ways[10] == nil {
+		t.Errorf("expected only way 10, got %v", filtered.Ways)
+	}
+
+	if len(filtered.Areas) != 1 || filtered.Areas[3600062422] == nil {
+		t.Errorf("expected areas kept, got %v", filtered.Areas)
+	}
+
+	if filtered.Count != 2 {
+		t.Errorf("expected count 2, got %d", filtered.Count)
+	}
+
+	delete(filtered.Areas, 3600062422)
+
+	if len(result.Nodes) != 2 || len(result.Ways) != 2 || len(result.Areas) != 1 {
+		t.Error("source result must not be modified")
+	}
+
+	if empty := FilterByStylesheet(&result, nil, 16); empty.Count != 1 || len(empty.Nodes)+len(empty.Ways) != 0 {
+		t.Errorf("expected only areas for nil stylesheet, got %+v", empty)
+	}
+}
+
+func TestExpandBuilder(t *testing.T) {
+	t.Parallel()
+
+	qb := overpass.NewQueryBuilder().Raw(`node["amenity"="cafe"]({{bbox}})`).OutputBody()
+
+	res, err := ExpandBuilder(qb, Options{BBox: &BBox{South: 1.1, West: 2.2, North: 3.3, East: 4.4}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := `[out:json]node["amenity"="cafe"](1.1,2.2,3.3,4.4);out body;`
+	if res.Query != expected {
+		t.Errorf("expected %q, got %q", expected, res.Query)
+	}
+
+	if _, err := ExpandBuilder(overpass.NewQueryBuilder().Raw("node({{bbox}})"), Options{}); err == nil {
+		t.Error("expected error for missing bbox")
+	}
+}
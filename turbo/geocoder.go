@@ -1,8 +1,12 @@
 package turbo
 
 import (
+	"context"
 	"fmt"
+	"strconv"
 	"strings"
+
+	"github.com/MeKo-Christian/go-overpass"
 )
 
 const (
@@ -17,6 +21,44 @@ type Geocoder interface {
 	Geocode(query string) (GeocodeResult, error)
 }
 
+// GeocoderContext is implemented by geocoders that support cancellation.
+// ExpandContext and FindInArea use GeocodeContext when the geocoder provides
+// it and fall back to Geocode otherwise.
+type GeocoderContext interface {
+	Geocoder
+	GeocodeContext(ctx context.Context, query string) (GeocodeResult, error)
+}
+
+// geocode resolves query with ctx, honoring cancellation even for geocoders
+// that do not implement GeocoderContext. A non-empty language is passed to
+// geocoders implementing LanguageGeocoder and ignored by others.
+func geocode(ctx context.Context, geocoder Geocoder, query, language string) (GeocodeResult, error) {
+	err := ctx.Err()
+	if err != nil {
+		return GeocodeResult{}, fmt.Errorf("geocoding cancelled: %w", err)
+	}
+
+	result, err := callGeocoder(ctx, geocoder, query, language)
+	if err != nil {
+		return GeocodeResult{}, fmt.Errorf("geocoding failed: %w", err)
+	}
+
+	return result, nil
+}
+
+// callGeocoder invokes the most capable method geocoder implements.
+func callGeocoder(ctx context.Context, geocoder Geocoder, query, language string) (GeocodeResult, error) {
+	if lg, ok := geocoder.(LanguageGeocoder); ok && language != "" {
+		return lg.GeocodeLanguage(ctx, query, language)
+	}
+
+	if gc, ok := geocoder.(GeocoderContext); ok {
+		return gc.GeocodeContext(ctx, query)
+	}
+
+	return geocoder.Geocode(query)
+}
+
 // GeocodeResult describes the first geocoding match.
 type GeocodeResult struct {
 	OSMType string
@@ -24,6 +66,9 @@ type GeocodeResult struct {
 	AreaID  int64
 	BBox    *BBox
 	Center  *Center
+	// DisplayName is the human-readable name of the match, localized if the
+	// geocoder supports it (optional).
+	DisplayName string
 }
 
 func expandGeocodeID(result GeocodeResult, format QueryFormat) (string, error) {
@@ -57,23 +102,23 @@ func expandGeocodeArea(result GeocodeResult, format QueryFormat) (string, error)
 	return fmt.Sprintf("area(%d)", areaID), nil
 }
 
-func expandGeocodeBbox(result GeocodeResult, format QueryFormat) (string, error) {
+func expandGeocodeBbox(result GeocodeResult, format QueryFormat, precision int) (string, error) {
 	if result.BBox == nil {
 		return "", ErrGeocodeData
 	}
 
-	return formatBBox(*result.BBox, format), nil
+	return formatBBox(*result.BBox, format, precision), nil
 }
 
-func expandGeocodeCoords(result GeocodeResult, format QueryFormat) (string, error) {
+func expandGeocodeCoords(result GeocodeResult, format QueryFormat, precision int) (string, error) {
 	if result.Center == nil {
 		return "", ErrGeocodeData
 	}
 
-	return formatCenter(*result.Center, format), nil
+	return formatCenter(*result.Center, format, precision), nil
 }
 
-func expandGeocode(content string, opts Options, format QueryFormat) (string, error) {
+func expandGeocode(ctx context.Context, content string, opts Options, format QueryFormat, statement bool) (string, error) {
 	if opts.Geocoder == nil {
 		return "", ErrMissingGeocoder
 	}
@@ -83,20 +128,25 @@ func expandGeocode(content string, opts Options, format QueryFormat) (string, er
 		return "", ErrBadMacro
 	}
 
-	result, err := opts.Geocoder.Geocode(query)
+	result, err := geocode(ctx, opts.Geocoder, query, opts.Language)
 	if err != nil {
-		return "", fmt.Errorf("geocoding failed: %w", err)
+		return "", err
 	}
 
 	switch kind {
 	case "geocodeId":
 		return expandGeocodeID(result, format)
 	case "geocodeArea":
-		return expandGeocodeArea(result, format)
+		area, err := expandGeocodeArea(result, format)
+		if err != nil || !statement || format == FormatXML || opts.GeocodeAreaSet == "" {
+			return area, err
+		}
+
+		return area + "->." + opts.GeocodeAreaSet, nil
 	case "geocodeBbox":
-		return expandGeocodeBbox(result, format)
+		return expandGeocodeBbox(result, format, opts.precision())
 	case "geocodeCoords":
-		return expandGeocodeCoords(result, format)
+		return expandGeocodeCoords(result, format, opts.precision())
 	default:
 		return "", ErrBadMacro
 	}
@@ -110,8 +160,8 @@ func parseGeocodeMacro(content string) (string, string, bool) {
 
 	kind := strings.TrimSpace(parts[0])
 
-	query := strings.TrimSpace(parts[1])
-	if kind == "" || query == "" {
+	query, ok := unquoteGeocodeName(strings.TrimSpace(parts[1]))
+	if kind == "" || query == "" || !ok {
 		return "", "", false
 	}
 
@@ -123,6 +173,22 @@ func parseGeocodeMacro(content string) (string, string, bool) {
 	}
 }
 
+// unquoteGeocodeName unescapes a double-quoted geocode name such as
+// "Frankfurt (Oder)", keeping its inner spaces verbatim. Unquoted names are
+// returned as they are.
+func unquoteGeocodeName(name string) (string, bool) {
+	if !strings.HasPrefix(name, `"`) {
+		return name, true
+	}
+
+	unquoted, err := strconv.Unquote(name)
+	if err != nil {
+		return "", false
+	}
+
+	return unquoted, true
+}
+
 func normalizeOSMType(t string) (string, bool) {
 	switch strings.ToLower(strings.TrimSpace(t)) {
 	case osmTypeNode:
@@ -151,3 +217,33 @@ func deriveAreaID(result GeocodeResult) (int64, error) {
 		return 0, ErrGeocodeData
 	}
 }
+
+// FindInArea builds a query for all elements tagged key=value inside the named area.
+// The area is resolved through the geocoder; matches without an area (e.g. nodes)
+// return ErrGeocodeData.
+func FindInArea(ctx context.Context, geocoder Geocoder, areaName string, tagKey, tagValue string) (string, error) {
+	if geocoder == nil {
+		return "", ErrMissingGeocoder
+	}
+
+	areaName = strings.TrimSpace(areaName)
+	if areaName == "" || tagKey == "" {
+		return "", ErrBadMacro
+	}
+
+	result, err := geocode(ctx, geocoder, areaName, "")
+	if err != nil {
+		return "", err
+	}
+
+	areaID := result.AreaID
+	if areaID == 0 {
+		areaID, err = deriveAreaID(result)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return fmt.Sprintf(`[out:json];area(%d)->.a;nwr["%s"="%s"](area.a);out center;`,
+		areaID, overpass.EscapeQL(tagKey), overpass.EscapeQL(tagValue)), nil
+}
@@ -0,0 +1,148 @@
+package turbo
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// GeocodeCacheConfig configures a CachingGeocoder.
+type GeocodeCacheConfig struct {
+	MaxEntries int           // Maximum cached answers, least recently used evicted first (0 = unlimited)
+	TTL        time.Duration // Lifetime of successful answers (0 = no expiry)
+	ErrorTTL   time.Duration // Lifetime of failed lookups, typically shorter than TTL (0 = errors are not cached)
+}
+
+// CachingGeocoder wraps a Geocoder and remembers its answers, so repeated
+// expansions of the same geocode macro do not hit the geocoding service
+// again. Cancelled or timed-out lookups are never cached. It is safe for
+// concurrent use.
+type CachingGeocoder struct {
+	geocoder Geocoder
+	config   GeocodeCacheConfig
+	now      func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List // of *geocodeCacheEntry, most recently used first
+}
+
+type geocodeCacheEntry struct {
+	key       string
+	result    GeocodeResult
+	err       error
+	expiresAt time.Time // zero means no expiry
+}
+
+// NewCachingGeocoder returns a CachingGeocoder in front of geocoder.
+func NewCachingGeocoder(geocoder Geocoder, config GeocodeCacheConfig) *CachingGeocoder {
+	return &CachingGeocoder{
+		geocoder: geocoder,
+		config:   config,
+		now:      time.Now,
+		entries:  map[string]*list.Element{},
+		order:    list.New(),
+	}
+}
+
+// Geocode resolves query using context.Background.
+func (g *CachingGeocoder) Geocode(query string) (GeocodeResult, error) {
+	return g.GeocodeLanguage(context.Background(), query, "")
+}
+
+// GeocodeContext resolves query, consulting the cache first.
+func (g *CachingGeocoder) GeocodeContext(ctx context.Context, query string) (GeocodeResult, error) {
+	return g.GeocodeLanguage(ctx, query, "")
+}
+
+// GeocodeLanguage resolves query in language, caching per query and language.
+func (g *CachingGeocoder) GeocodeLanguage(ctx context.Context, query, language string) (GeocodeResult, error) {
+	key := language + "\x00" + query
+
+	if entry, ok := g.lookup(key); ok {
+		return entry.result, entry.err
+	}
+
+	err := ctx.Err()
+	if err != nil {
+		return GeocodeResult{}, err
+	}
+
+	result, err := callGeocoder(ctx, g.geocoder, query, language)
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return result, err
+	}
+
+	g.store(key, result, err)
+
+	return result, err
+}
+
+// Len returns the number of cached answers, including expired ones not yet
+// evicted.
+func (g *CachingGeocoder) Len() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return g.order.Len()
+}
+
+// lookup returns the unexpired entry for key and marks it as recently used.
+func (g *CachingGeocoder) lookup(key string) (*geocodeCacheEntry, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	elem, ok := g.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	entry := elem.Value.(*geocodeCacheEntry) //nolint:forcetypeassert // list only holds entries
+	if !entry.expiresAt.IsZero() && g.now().After(entry.expiresAt) {
+		g.order.Remove(elem)
+		delete(g.entries, key)
+
+		return nil, false
+	}
+
+	g.order.MoveToFront(elem)
+
+	return entry, true
+}
+
+// store caches an answer, evicting the least recently used entry when full.
+func (g *CachingGeocoder) store(key string, result GeocodeResult, err error) {
+	ttl := g.config.TTL
+	if err != nil {
+		if g.config.ErrorTTL <= 0 {
+			return
+		}
+
+		ttl = g.config.ErrorTTL
+	}
+
+	entry := &geocodeCacheEntry{key: key, result: result, err: err}
+	if ttl > 0 {
+		entry.expiresAt = g.now().Add(ttl)
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if elem, ok := g.entries[key]; ok {
+		elem.Value = entry
+		g.order.MoveToFront(elem)
+
+		return
+	}
+
+	g.entries[key] = g.order.PushFront(entry)
+
+	if g.config.MaxEntries > 0 && g.order.Len() > g.config.MaxEntries {
+		oldest := g.order.Back()
+		g.order.Remove(oldest)
+		delete(g.entries, oldest.Value.(*geocodeCacheEntry).key) //nolint:forcetypeassert // list only holds entries
+	}
+}
@@ -0,0 +1,135 @@
+package turbo
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+var errGeocoderDown = errors.New("geocoder down")
+
+// countingGeocoder counts lookups per name and fails for names in fail.
+type countingGeocoder struct {
+	mu    sync.Mutex
+	calls map[string]int
+	fail  map[string]bool
+}
+
+func (g *countingGeocoder) Geocode(query string) (GeocodeResult, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.calls == nil {
+		g.calls = map[string]int{}
+	}
+
+	g.calls[query]++
+
+	if g.fail[query] {
+		return GeocodeResult{}, errGeocoderDown
+	}
+
+	return GeocodeResult{OSMType: "relation", OSMID: int64(len(query))}, nil
+}
+
+func (g *countingGeocoder) count(query string) int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return g.calls[query]
+}
+
+func TestCachingGeocoderEviction(t *testing.T) {
+	t.Parallel()
+
+	inner := &countingGeocoder{}
+	cached := NewCachingGeocoder(inner, GeocodeCacheConfig{MaxEntries: 2})
+
+	for _, name := range []string{"Berlin", "Hamburg", "Berlin", "Munich"} {
+		_, err := cached.Geocode(name)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", name, err)
+		}
+	}
+
+	if cached.Len() != 2 {
+		t.Errorf("expected 2 cached entries, got %d", cached.Len())
+	}
+
+	// Hamburg was least recently used when Munich arrived
+	_, _ = cached.Geocode("Berlin")
+	_, _ = cached.Geocode("Hamburg")
+
+	if n := inner.count("Hamburg"); n != 2 {
+		t.Errorf("expected evicted Hamburg to be fetched again, got %d lookups", n)
+	}
+
+	if n := inner.count("Berlin"); n != 1 {
+		t.Errorf("expected Berlin served from cache, got %d lookups", n)
+	}
+}
+
+func TestCachingGeocoderErrorTTL(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	inner := &countingGeocoder{fail: map[string]bool{"Atlantis": true}}
+	cached := NewCachingGeocoder(inner, GeocodeCacheConfig{TTL: time.Hour, ErrorTTL: time.Minute})
+	cached.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		_, err := cached.Geocode("Atlantis")
+		if !errors.Is(err, errGeocoderDown) {
+			t.Fatalf("expected cached error, got %v", err)
+		}
+
+		_, err = cached.Geocode("Berlin")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	if inner.count("Atlantis") != 1 {
+		t.Errorf("expected error to be cached, got %d lookups", inner.count("Atlantis"))
+	}
+
+	// The error expires before the successful answer
+	now = now.Add(2 * time.Minute)
+
+	_, _ = cached.Geocode("Atlantis")
+	_, _ = cached.Geocode("Berlin")
+
+	if inner.count("Atlantis") != 2 || inner.count("Berlin") != 1 {
+		t.Errorf("expected only the error to expire, got %d and %d lookups",
+			inner.count("Atlantis"), inner.count("Berlin"))
+	}
+}
+
+func TestCachingGeocoderSkipsCancelled(t *testing.T) {
+	t.Parallel()
+
+	inner := &countingGeocoder{}
+	cached := NewCachingGeocoder(inner, GeocodeCacheConfig{ErrorTTL: time.Minute})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := cached.GeocodeContext(ctx, "Berlin")
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+
+	if cached.Len() != 0 || inner.count("Berlin") != 0 {
+		t.Errorf("expected nothing cached or fetched, got %d entries", cached.Len())
+	}
+
+	// Uncached errors (ErrorTTL 0) are retried every time
+	uncached := NewCachingGeocoder(&countingGeocoder{fail: map[string]bool{"X": true}}, GeocodeCacheConfig{})
+
+	_, _ = uncached.Geocode("X")
+	if uncached.Len() != 0 {
+		t.Errorf("expected errors not cached without ErrorTTL, got %d entries", uncached.Len())
+	}
+}
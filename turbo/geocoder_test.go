@@ -0,0 +1,287 @@
+package turbo
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFindInArea(t *testing.T) {
+	t.Parallel()
+
+	geocoder := fakeGeocoder{
+		result: GeocodeResult{OSMType: "relation", OSMID: 62422},
+	}
+
+	query, err := FindInArea(context.Background(), geocoder, "Berlin", "amenity", "cafe")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := `[out:json];area(3600062422)->.a;nwr["amenity"="cafe"](area.a);out center;`
+	if query != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, query)
+	}
+}
+
+func TestFindInAreaExplicitAreaID(t *testing.T) {
+	t.Parallel()
+
+	geocoder := fakeGeocoder{
+		result: GeocodeResult{OSMType: "node", OSMID: 1, AreaID: 3600000042},
+	}
+
+	query, err := FindInArea(context.Background(), geocoder, "Somewhere", "shop", `say "hi"`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := `[out:json];area(3600000042)->.a;nwr["shop"="say \"hi\""](area.a);out center;`
+	if query != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, query)
+	}
+}
+
+func TestFindInAreaErrors(t *testing.T) {
+	t.Parallel()
+
+	geocodeErr := errors.New("service down")
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	testCases := []struct {
+		name     string
+		ctx      context.Context //nolint:containedctx // table-driven test input
+		geocoder Geocoder
+		want     error
+	}{
+		{"missing geocoder", context.Background(), nil, ErrMissingGeocoder},
+		{"geocode failure", context.Background(), fakeGeocoder{err: geocodeErr}, geocodeErr},
+		{
+			"node without area",
+			context.Background(),
+			fakeGeocoder{result: GeocodeResult{OSMType: "node", OSMID: 1}},
+			ErrGeocodeData,
+		},
+		{
+			"cancelled context",
+			cancelled,
+			fakeGeocoder{result: GeocodeResult{OSMType: "relation", OSMID: 1}},
+			context.Canceled,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := FindInArea(tc.ctx, tc.geocoder, "Vienna", "amenity", "cafe")
+			if !errors.Is(err, tc.want) {
+				t.Fatalf("expected %v, got %v", tc.want, err)
+			}
+		})
+	}
+}
+
+func TestGeocodeAreaSetAssignment(t *testing.T) {
+	t.Parallel()
+
+	geocoder := fakeGeocoder{
+		result: GeocodeResult{OSMType: "relation", OSMID: 1645},
+	}
+
+	query := `[out:json];{{geocodeArea:Vienna}};nwr["amenity"="cafe"](area.searchArea);out;`
+
+	res, err := Expand(query, Options{Geocoder: geocoder, GeocodeAreaSet: "searchArea"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := `[out:json];area(3600001645)->.searchArea;nwr["amenity"="cafe"](area.searchArea);out;`
+	if res.Query != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, res.Query)
+	}
+}
+
+func TestGeocodeAreaCustomSetName(t *testing.T) {
+	t.Parallel()
+
+	geocoder := fakeGeocoder{
+		result: GeocodeResult{OSMType: "relation", OSMID: 1645},
+	}
+
+	res, err := Expand("{{geocodeArea:Vienna}} ;", Options{Geocoder: geocoder, GeocodeAreaSet: "city"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if res.Query != "area(3600001645)->.city ;" {
+		t.Errorf("unexpected query: %s", res.Query)
+	}
+}
+
+func TestGeocodeAreaBareForm(t *testing.T) {
+	t.Parallel()
+
+	geocoder := fakeGeocoder{
+		result: GeocodeResult{OSMType: "relation", OSMID: 1645},
+	}
+
+	testCases := []struct {
+		name  string
+		query string
+		opts  Options
+		want  string
+	}{
+		{
+			"expression position with set option",
+			"({{geocodeArea:Vienna}})->.a;",
+			Options{Geocoder: geocoder, GeocodeAreaSet: "searchArea"},
+			"(area(3600001645))->.a;",
+		},
+		{
+			"statement position without set option",
+			"{{geocodeArea:Vienna}};",
+			Options{Geocoder: geocoder},
+			"area(3600001645);",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			res, err := Expand(tc.query, tc.opts)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if res.Query != tc.want {
+				t.Errorf("expected %s, got %s", tc.want, res.Query)
+			}
+		})
+	}
+}
+
+// recordingGeocoder records the names it was asked to geocode.
+type recordingGeocoder struct {
+	mu    sync.Mutex
+	names []string
+}
+
+func (g *recordingGeocoder) Geocode(query string) (GeocodeResult, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.names = append(g.names, query)
+
+	return GeocodeResult{OSMType: "relation", OSMID: 1}, nil
+}
+
+func TestGeocodeQuotedNames(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{"colon", `{{geocodeArea:"Frankfurt (Oder): Altstadt"}}`, "Frankfurt (Oder): Altstadt"},
+		{"inner and trailing spaces", `{{geocodeArea: "Bad  Homburg " }}`, "Bad  Homburg "},
+		{"escaped quote", `{{geocodeId:"The \"Hub\""}}`, `The "Hub"`},
+		{"unquoted", `{{geocodeArea: Frankfurt (Oder) }}`, "Frankfurt (Oder)"},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			geocoder := &recordingGeocoder{}
+
+			_, err := Expand(tc.query, Options{Geocoder: geocoder})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(geocoder.names) != 1 || geocoder.names[0] != tc.want {
+				t.Errorf("expected geocoder query %q, got %q", tc.want, geocoder.names)
+			}
+		})
+	}
+}
+
+func TestGeocodeQuotedNameInvalid(t *testing.T) {
+	t.Parallel()
+
+	_, err := Expand(`{{geocodeArea:"Frankfurt}}`, Options{Geocoder: &recordingGeocoder{}})
+	if !errors.Is(err, ErrBadMacro) {
+		t.Errorf("expected ErrBadMacro for unterminated quote, got %v", err)
+	}
+}
+
+// contextGeocoder blocks until its context is done unless ready is closed.
+type contextGeocoder struct {
+	ready chan struct{}
+}
+
+func (g *contextGeocoder) Geocode(query string) (GeocodeResult, error) {
+	return g.GeocodeContext(context.Background(), query)
+}
+
+func (g *contextGeocoder) GeocodeContext(ctx context.Context, _ string) (GeocodeResult, error) {
+	select {
+	case <-g.ready:
+		return GeocodeResult{OSMType: "relation", OSMID: 1}, nil
+	case <-ctx.Done():
+		return GeocodeResult{}, ctx.Err()
+	}
+}
+
+func TestExpandContextCancelsGeocoding(t *testing.T) {
+	t.Parallel()
+
+	query := `{{geocodeArea:Berlin}};out;`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err := ExpandContext(ctx, query, Options{Geocoder: &contextGeocoder{ready: make(chan struct{})}})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+
+	ready := make(chan struct{})
+	close(ready)
+
+	res, err := ExpandContext(context.Background(), query, Options{Geocoder: &contextGeocoder{ready: ready}})
+	if err != nil || res.Query != "area(3600000001);out;" {
+		t.Fatalf("expected expansion, got %q (%v)", res.Query, err)
+	}
+}
+
+func TestExpandContextCancelledPlainGeocoder(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	geocoder := &recordingGeocoder{}
+
+	_, err := ExpandContext(ctx, `{{geocodeArea:Berlin}};out;`, Options{Geocoder: geocoder})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+
+	if len(geocoder.names) != 0 {
+		t.Errorf("expected no geocoding after cancellation, got %v", geocoder.names)
+	}
+}
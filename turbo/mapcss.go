@@ -51,6 +51,12 @@ type Condition struct {
 type Declaration struct {
 	Property string
 	Value    Value
+	// Important is set for declarations flagged with !important. In the cascade
+	// they override non-important declarations regardless of source order.
+	Important bool
+	// Line and Column locate the property name in the source (1-based, 0 if unknown).
+	Line   int
+	Column int
 }
 
 // Value represents a MapCSS property value.
@@ -62,6 +68,7 @@ type Value struct {
 	// Parsed values for specific types.
 	Color   *Color
 	Number  float64
+	Unit    string // unit suffix of a number like "px" in 2px (empty if none)
 	URL     string
 	Eval    string
 	Dashes  []float64
@@ -315,6 +322,11 @@ func (p *parser) parseSingleSelector() (*Selector, error) {
 		return nil, err
 	}
 
+	// MapCSS 0.2 places the layer after the conditions (way[highway]::casing)
+	if sel.Layer == "" {
+		p.parseSelectorLayer(sel)
+	}
+
 	if sel.Type == "" && len(sel.Conditions) == 0 && len(sel.PseudoClasses) == 0 && len(sel.Classes) == 0 {
 		return nil, ErrEmptySelector
 	}
@@ -599,6 +611,8 @@ func (p *parser) parseDeclaration() (*Declaration, error) {
 		return p.parseSetDeclaration()
 	}
 
+	line, col := p.line, p.col
+
 	// Parse property name
 	prop := p.parseIdent()
 	if prop == "" {
@@ -620,18 +634,53 @@ func (p *parser) parseDeclaration() (*Declaration, error) {
 		return nil, err
 	}
 
+	important := false
+
+	// Keyword values swallow the flag while collecting up to ";"
+	if raw, ok := cutImportant(value.Raw); ok {
+		value = p.determineValueType(raw)
+		important = true
+	}
+
 	p.skipWhitespace()
 
+	// Function and hex values stop before the flag
+	if strings.HasPrefix(p.input[p.pos:], importantFlag) {
+		for i := 0; i < len(importantFlag); i++ {
+			p.advance()
+		}
+
+		important = true
+
+		p.skipWhitespace()
+	}
+
 	if p.pos < len(p.input) && p.peek() == ';' {
 		p.advance()
 	}
 
 	return &Declaration{
-		Property: prop,
-		Value:    *value,
+		Property:  prop,
+		Value:     *value,
+		Important: important,
+		Line:      line,
+		Column:    col,
 	}, nil
 }
 
+// importantFlag marks a declaration that wins over non-important ones.
+const importantFlag = "!important"
+
+// cutImportant strips a trailing !important flag from a raw value.
+func cutImportant(raw string) (string, bool) {
+	trimmed, ok := strings.CutSuffix(raw, importantFlag)
+	if !ok {
+		return raw, false
+	}
+
+	return strings.TrimSpace(trimmed), true
+}
+
 func (p *parser) parseURLValue() (*Value, error) {
 	p.pos += 4 // skip "url("
 	content := p.parseUntilClosingParen()
@@ -677,6 +726,14 @@ func (p *parser) determineValueType(rawStr string) *Value {
 		return val
 	}
 
+	if num, unit, ok := parseNumberWithUnit(rawStr); ok {
+		val.Type = ValueTypeNumber
+		val.Number = num
+		val.Unit = unit
+
+		return val
+	}
+
 	if strings.Contains(rawStr, ",") && !strings.ContainsAny(rawStr, "()") {
 		// Might be dashes pattern
 		parts := strings.Split(rawStr, ",")
@@ -706,6 +763,25 @@ func (p *parser) determineValueType(rawStr string) *Value {
 	return val
 }
 
+// numberUnits lists the unit suffixes recognized on numeric values.
+var numberUnits = []string{"px", "pt", "em", "%", "m"} //nolint:gochecknoglobals // lookup table for unit parsing
+
+// parseNumberWithUnit parses numbers carrying a unit suffix such as 2px or 1.5em.
+func parseNumberWithUnit(raw string) (float64, string, bool) {
+	for _, unit := range numberUnits {
+		if !strings.HasSuffix(raw, unit) {
+			continue
+		}
+
+		num, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(raw, unit)), 64)
+		if err == nil {
+			return num, unit, true
+		}
+	}
+
+	return 0, "", false
+}
+
 func (p *parser) parseValue() (*Value, error) { //nolint:cyclop // multiple value type checks needed
 	p.skipWhitespace()
 
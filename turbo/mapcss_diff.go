@@ -0,0 +1,235 @@
+package turbo
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// StylesheetDiff lists the rule changes between two stylesheets.
+type StylesheetDiff struct {
+	Added    []Rule     // rules only in the other stylesheet
+	Removed  []Rule     // rules only in the receiver
+	Modified []RuleDiff // rules in both with different declarations
+}
+
+// RuleDiff describes how the declarations of a rule changed.
+type RuleDiff struct {
+	Selector string // serialized selectors, see Selector.String
+	Changes  []DeclarationChange
+}
+
+// DeclarationChange is a single added, removed or changed declaration. Old is
+// nil for added and New is nil for removed declarations.
+type DeclarationChange struct {
+	Property string
+	Old, New *Declaration
+}
+
+// IsEmpty reports whether the stylesheets have the same rules.
+func (d StylesheetDiff) IsEmpty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Modified) == 0
+}
+
+// Diff compares s with other, a newer version of the stylesheet. Rules are
+// matched by their serialized selectors regardless of their position, so
+// moving a rule is not a change; rules with identical selectors are combined.
+// Declarations are compared by value and !important flag, ignoring their
+// source position. Results follow the order of the rules in the respective
+// stylesheet.
+func (s *Stylesheet) Diff(other *Stylesheet) StylesheetDiff {
+	oldRules := indexRules(s)
+	newRules := indexRules(other)
+
+	var diff StylesheetDiff
+
+	for _, rule := range oldRules {
+		counterpart := newRules.find(rule.selector)
+		if counterpart == nil {
+			diff.Removed = append(diff.Removed, rule.rule)
+
+			continue
+		}
+
+		if changes := diffDeclarations(rule.rule.Declarations, counterpart.rule.Declarations); len(changes) > 0 {
+			diff.Modified = append(diff.Modified, RuleDiff{Selector: rule.selector, Changes: changes})
+		}
+	}
+
+	for _, rule := range newRules {
+		if oldRules.find(rule.selector) == nil {
+			diff.Added = append(diff.Added, rule.rule)
+		}
+	}
+
+	return diff
+}
+
+// String serializes the selector in MapCSS syntax, e.g.
+// way|z12-[highway=primary].major:closed::casing. Descendant selectors are
+// prefixed by their parent.
+func (sel *Selector) String() string {
+	var sb strings.Builder
+
+	if sel.Parent != nil {
+		sb.WriteString(sel.Parent.String() + " ")
+	}
+
+	sb.WriteString(sel.Type)
+
+	switch {
+	case sel.ZoomMin != 0 && sel.ZoomMin == sel.ZoomMax:
+		sb.WriteString("|z" + strconv.Itoa(sel.ZoomMin))
+	case sel.ZoomMin != 0 || sel.ZoomMax != 0:
+		sb.WriteString("|z")
+
+		if sel.ZoomMin != 0 {
+			sb.WriteString(strconv.Itoa(sel.ZoomMin))
+		}
+
+		sb.WriteString("-")
+
+		if sel.ZoomMax != 0 {
+			sb.WriteString(strconv.Itoa(sel.ZoomMax))
+		}
+	}
+
+	for _, cond := range sel.Conditions {
+		switch cond.Operator {
+		case "":
+			sb.WriteString("[" + quoteMapCSS(cond.Key) + "]")
+		case "!":
+			sb.WriteString("[!" + quoteMapCSS(cond.Key) + "]")
+		default:
+			value := cond.Value
+			if cond.Regex == nil {
+				value = quoteMapCSS(value)
+			}
+
+			sb.WriteString("[" + quoteMapCSS(cond.Key) + cond.Operator + value + "]")
+		}
+	}
+
+	for _, class := range sel.Classes {
+		sb.WriteString("." + class)
+	}
+
+	for _, pseudo := range sel.PseudoClasses {
+		sb.WriteString(":" + pseudo)
+	}
+
+	if sel.Layer != "" {
+		sb.WriteString("::" + sel.Layer)
+	}
+
+	return sb.String()
+}
+
+// quoteMapCSS quotes s unless it can be written bare.
+func quoteMapCSS(s string) string {
+	if s != "" && !strings.ContainsAny(s, " \t\"':[]=!<>~,;{}") {
+		return s
+	}
+
+	return strconv.Quote(s)
+}
+
+// indexedRule is a rule with its serialized selectors.
+type indexedRule struct {
+	selector string
+	rule     Rule
+}
+
+type ruleIndex []indexedRule
+
+// indexRules serializes the selectors of every rule, combining rules that
+// share them; later declarations replace earlier ones for the same property.
+func indexRules(s *Stylesheet) ruleIndex {
+	var index ruleIndex
+
+	if s == nil {
+		return index
+	}
+
+	for _, rule := range s.Rules {
+		selectors := make([]string, len(rule.Selectors))
+		for i := range rule.Selectors {
+			selectors[i] = rule.Selectors[i].String()
+		}
+
+		key := strings.Join(selectors, ", ")
+
+		if existing := index.find(key); existing != nil {
+			existing.rule.Declarations = append(slices.Clip(existing.rule.Declarations), rule.Declarations...)
+
+			continue
+		}
+
+		index = append(index, indexedRule{selector: key, rule: rule})
+	}
+
+	return index
+}
+
+func (index ruleIndex) find(selector string) *indexedRule {
+	for i := range index {
+		if index[i].selector == selector {
+			return &index[i]
+		}
+	}
+
+	return nil
+}
+
+// diffDeclarations compares declarations by property, the last one winning.
+func diffDeclarations(oldDecls, newDecls []Declaration) []DeclarationChange {
+	oldByKey, oldKeys := declarationsByKey(oldDecls)
+	newByKey, newKeys := declarationsByKey(newDecls)
+
+	var changes []DeclarationChange
+
+	for _, key := range oldKeys {
+		oldDecl := oldByKey[key]
+
+		newDecl, ok := newByKey[key]
+		if !ok {
+			changes = append(changes, DeclarationChange{Property: oldDecl.Property, Old: oldDecl})
+
+			continue
+		}
+
+		if oldDecl.Value.Raw != newDecl.Value.Raw || oldDecl.Important != newDecl.Important {
+			changes = append(changes, DeclarationChange{Property: oldDecl.Property, Old: oldDecl, New: newDecl})
+		}
+	}
+
+	for _, key := range newKeys {
+		if _, ok := oldByKey[key]; !ok {
+			changes = append(changes, DeclarationChange{Property: newByKey[key].Property, New: newByKey[key]})
+		}
+	}
+
+	return changes
+}
+
+// declarationsByKey maps declarations by property, keeping first-seen order.
+// Class assignments are keyed by class, since a rule may set several.
+func declarationsByKey(decls []Declaration) (map[string]*Declaration, []string) {
+	byKey := make(map[string]*Declaration, len(decls))
+	keys := make([]string, 0, len(decls))
+
+	for i := range decls {
+		key := decls[i].Property
+		if key == "set-class" {
+			key += ":" + decls[i].Value.Raw
+		}
+
+		if _, seen := byKey[key]; !seen {
+			keys = append(keys, key)
+		}
+
+		byKey[key] = &decls[i]
+	}
+
+	return byKey, keys
+}
@@ -0,0 +1,71 @@
+package turbo
+
+import (
+	"testing"
+)
+
+func TestStylesheetDiff(t *testing.T) {
+	t.Parallel()
+
+	oldStyle := mustParseMapCSS(t, `
+		way[highway=primary] { color: red; width: 4; }
+		node[amenity=cafe] { icon-image: "cafe.png"; }
+		way[railway=rail] { color: gray; }
+	`)
+	newStyle := mustParseMapCSS(t, `
+		way[railway=rail] { color: gray; }
+		way[highway=primary] { color: orange; width: 4; casing-width: 1; }
+		area[building] { fill-color: #ccc; }
+	`)
+
+	diff := oldStyle.Diff(newStyle)
+
+	if len(diff.Added) != 1 || diff.Added[0].Selectors[0].String() != "area[building]" {
+		t.Errorf("expected area[building] added, got %+v", diff.Added)
+	}
+
+	if len(diff.Removed) != 1 || diff.Removed[0].Selectors[0].String() != "node[amenity=cafe]" {
+		t.Errorf("expected node[amenity=cafe] removed, got %+v", diff.Removed)
+	}
+
+	if len(diff.Modified) != 1 || diff.Modified[0].Selector != "way[highway=primary]" {
+		t.Fatalf("expected way[highway=primary] modified, got %+v", diff.Modified)
+	}
+
+	changes := diff.Modified[0].Changes
+	if len(changes) != 2 {
+		t.Fatalf("expected two declaration changes, got %+v", changes)
+	}
+
+	if changes[0].Property != "color" || changes[0].Old.Value.Raw != "red" || changes[0].New.Value.Raw != "orange" {
+		t.Errorf("expected color red -> orange, got %+v", changes[0])
+	}
+
+	if changes[1].Property != "casing-width" || changes[1].Old != nil || changes[1].New.Value.Raw != "1" {
+		t.Errorf("expected casing-width added, got %+v", changes[1])
+	}
+
+	if !oldStyle.Diff(oldStyle).IsEmpty() {
+		t.Error("expected no differences to itself")
+	}
+}
+
+func TestSelectorString(t *testing.T) {
+	t.Parallel()
+
+	testCases := []string{
+		"way|z12-[highway=primary].major:closed::casing",
+		"node|z14[!name]",
+		"relation[type=route] way|z10-14",
+		`area["addr:street"="Main Street"]`,
+		"*[name=~/^B/]",
+	}
+
+	for _, input := range testCases {
+		stylesheet := mustParseMapCSS(t, input+" { color: red; }")
+
+		if got := stylesheet.Rules[0].Selectors[0].String(); got != input {
+			t.Errorf("expected %s, got %s", input, got)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package turbo
+
+import (
+	"fmt"
+	"strings"
+)
+
+// knownProperties lists the MapCSS properties understood by Overpass Turbo and
+// common MapCSS renderers.
+//
+//nolint:gochecknoglobals // read-only vocabulary
+var knownProperties = map[string]bool{
+	"color":          true,
+	"width":          true,
+	"opacity":        true,
+	"dashes":         true,
+	"linecap":        true,
+	"linejoin":       true,
+	"fill-color":     true,
+	"fill-opacity":   true,
+	"fill-image":     true,
+	"z-index":        true,
+	"object-z-index": true,
+	"extrude":        true,
+	"image":          true,
+	"max-width":      true,
+	"antialiasing":   true,
+	"set-class":      true,
+}
+
+// knownPropertyPrefixes lists property families accepted by prefix
+// (e.g. casing-width, icon-image, text-color, set-tag:name).
+//
+//nolint:gochecknoglobals // read-only vocabulary
+var knownPropertyPrefixes = []string{
+	"casing-",
+	"icon-",
+	"text",
+	"font-",
+	"symbol-",
+	"shield-",
+	"set-tag:",
+}
+
+// Lint reports declarations whose property name is not part of the known MapCSS
+// vocabulary, such as misspellings like "colour" or "witdh". Such declarations
+// parse successfully but have no effect on rendering.
+func (s *Stylesheet) Lint() []ParseError {
+	var problems []ParseError
+
+	for _, rule := range s.Rules {
+		for _, decl := range rule.Declarations {
+			if isKnownProperty(decl.Property) {
+				continue
+			}
+
+			problems = append(problems, ParseError{
+				Line:    decl.Line,
+				Column:  decl.Column,
+				Message: fmt.Sprintf("unknown property %q", decl.Property),
+			})
+		}
+	}
+
+	return problems
+}
+
+func isKnownProperty(name string) bool {
+	name = strings.ToLower(name)
+	if knownProperties[name] {
+		return true
+	}
+
+	for _, prefix := range knownPropertyPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,51 @@
+package turbo
+
+import (
+	"testing"
+)
+
+func TestStylesheetLintUnknownProperty(t *testing.T) {
+	t.Parallel()
+
+	sheet := mustParseMapCSS(t, "way[highway] {\n  colour: red;\n  width: 2;\n}\nnode { witdh: 3; }")
+
+	problems := sheet.Lint()
+	if len(problems) != 2 {
+		t.Fatalf("expected 2 lint problems, got %d: %v", len(problems), problems)
+	}
+
+	first := problems[0]
+	if first.Line != 2 || first.Column != 3 {
+		t.Errorf("expected line 2, col 3, got line %d, col %d", first.Line, first.Column)
+	}
+
+	if first.Message != `unknown property "colour"` {
+		t.Errorf("unexpected message: %s", first.Message)
+	}
+
+	if problems[1].Line != 5 || problems[1].Message != `unknown property "witdh"` {
+		t.Errorf("unexpected second problem: %v", problems[1])
+	}
+}
+
+func TestStylesheetLintKnownProperties(t *testing.T) {
+	t.Parallel()
+
+	input := `way[highway] {
+  color: red; width: 2; opacity: 0.5; dashes: 5,5; fill-color: #00ff00;
+  casing-width: 1; casing-color: black; z-index: 3;
+  icon-image: url('cafe.png'); icon-width: 16;
+  text: name; text-color: white; text-halo-radius: 2; font-size: 12;
+  set .major; set highlight=yes;
+}`
+
+	sheet := mustParseMapCSS(t, input)
+
+	if problems := sheet.Lint(); len(problems) != 0 {
+		t.Errorf("expected no lint problems, got %v", problems)
+	}
+
+	if problems := mustParseMapCSS(t, complexStylesheet).Lint(); len(problems) != 0 {
+		t.Errorf("expected no lint problems in complex stylesheet, got %v", problems)
+	}
+}
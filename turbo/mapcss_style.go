@@ -0,0 +1,480 @@
+package turbo
+
+import (
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/MeKo-Christian/go-overpass"
+)
+
+// defaultLayer is the layer name used for selectors without an explicit ::layer.
+const defaultLayer = "default"
+
+// MarkerStyle is a resolved, typed view of the declarations relevant for drawing
+// a single map feature. Missing properties keep their zero values.
+type MarkerStyle struct {
+	IconURL    string
+	IconWidth  float64
+	IconHeight float64
+	Color      *Color
+	Width      float64
+	FillColor  *Color
+	Dashes     []float64
+	Text       string
+}
+
+// StyledLayer is the cascaded style of one layer (subpart) of an element.
+type StyledLayer struct {
+	Layer      string
+	ZIndex     float64 // numeric z-index declaration, 0 if unset
+	Properties map[string]Value
+}
+
+// StyleElement describes an element for style matching.
+type StyleElement struct {
+	Type   string // OSM type ("node", "way" or "relation"); empty matches any selector type
+	Tags   map[string]string
+	Closed bool // closed way (first node equals last)
+}
+
+// styleElement is the element being matched against selectors.
+// An empty osmType matches selectors of any object type.
+type styleElement struct {
+	osmType string
+	tags    map[string]string
+	closed  bool
+	classes map[string]bool
+}
+
+// Match returns the cascaded declarations of the default layer for an element.
+//
+// elementType is the OSM type ("node", "way" or "relation"). Selectors of type
+// "line" match ways, "area" matches ways and multipolygon relations. Later rules
+// override earlier ones, except that !important declarations beat
+// non-important ones. Selectors with a parent selector never match, as that
+// context is not available. See MatchElement for pseudo-class handling.
+func (s *Stylesheet) Match(elementType string, tags map[string]string, zoom int) map[string]Value {
+	return s.MatchElement(StyleElement{Type: elementType, Tags: tags}, zoom)
+}
+
+// MatchElement is like Match but also evaluates the data-driven pseudo-classes
+// :tagged (element has tags), :closed (closed way) and :area (closed way not
+// tagged area=no, or multipolygon relation). Interactive state such as :hover
+// or :active is never active during data matching, so those selectors do not
+// apply.
+func (s *Stylesheet) MatchElement(el StyleElement, zoom int) map[string]Value {
+	layers, _ := s.matchLayers(&styleElement{osmType: el.Type, tags: el.Tags, closed: el.Closed}, zoom)
+
+	return layers[defaultLayer]
+}
+
+// RenderOrder returns every matched layer of an element in drawing order:
+// ascending z-index, ties broken by the order in which the layers first appear
+// in the stylesheet. Layers drawn first belong at the bottom, so a casing with
+// a lower z-index is painted beneath the main line.
+func (s *Stylesheet) RenderOrder(elementType string, tags map[string]string, zoom int) []StyledLayer {
+	layers, order := s.matchLayers(&styleElement{osmType: elementType, tags: tags}, zoom)
+
+	styled := make([]StyledLayer, len(order))
+	for i, name := range order {
+		styled[i] = StyledLayer{
+			Layer:      name,
+			ZIndex:     numberProperty(layers[name], "z-index"),
+			Properties: layers[name],
+		}
+	}
+
+	sort.SliceStable(styled, func(i, j int) bool {
+		return styled[i].ZIndex < styled[j].ZIndex
+	})
+
+	return styled
+}
+
+// MarkerStyle resolves the cascaded style of an element with the given tags.
+// Selectors are matched without regard to the object type.
+func (s *Stylesheet) MarkerStyle(tags map[string]string, zoom int) MarkerStyle {
+	props := s.Match("", tags, zoom)
+
+	var style MarkerStyle
+
+	if v, ok := props["icon-image"]; ok {
+		style.IconURL = v.URL
+		if style.IconURL == "" {
+			style.IconURL = unquote(v.Raw)
+		}
+	}
+
+	style.IconWidth = numberProperty(props, "icon-width")
+	style.IconHeight = numberProperty(props, "icon-height")
+	style.Width = numberProperty(props, "width")
+	style.Color = colorProperty(props, "color")
+	style.FillColor = colorProperty(props, "fill-color")
+
+	if v, ok := props["dashes"]; ok {
+		style.Dashes = v.Dashes
+	}
+
+	if v, ok := props["text"]; ok {
+		style.Text = resolveText(v, tags)
+	}
+
+	return style
+}
+
+// Canvas returns the declarations of all canvas{} rules, which style the map
+// background rather than any element (e.g. fill-color). Later declarations
+// override earlier ones unless those are !important.
+func (s *Stylesheet) Canvas() map[string]Value {
+	return s.selectorTypeDeclarations("canvas")
+}
+
+// Meta returns the stylesheet metadata declared in meta{} rules, such as title
+// or author, with surrounding quotes removed.
+func (s *Stylesheet) Meta() map[string]string {
+	meta := map[string]string{}
+	for property, value := range s.selectorTypeDeclarations("meta") {
+		meta[property] = unquote(strings.TrimSpace(value.Raw))
+	}
+
+	return meta
+}
+
+// selectorTypeDeclarations cascades the declarations of rules having a
+// selector of the given type, ignoring zoom levels and conditions.
+func (s *Stylesheet) selectorTypeDeclarations(selectorType string) map[string]Value {
+	props := map[string]Value{}
+	important := map[string]bool{}
+
+	for _, rule := range s.Rules {
+		if !slices.ContainsFunc(rule.Selectors, func(sel Selector) bool { return sel.Type == selectorType }) {
+			continue
+		}
+
+		for _, decl := range rule.Declarations {
+			if important[decl.Property] && !decl.Important {
+				continue
+			}
+
+			props[decl.Property] = decl.Value
+			important[decl.Property] = important[decl.Property] || decl.Important
+		}
+	}
+
+	return props
+}
+
+// matchLayers applies every matching rule in source order and returns the
+// resulting declarations grouped by layer, along with the layer names in order
+// of first appearance. A later declaration overrides an earlier one unless the
+// earlier one is !important and the later one is not.
+func (s *Stylesheet) matchLayers(el *styleElement, zoom int) (map[string]map[string]Value, []string) {
+	el = s.applySets(el, zoom)
+	layers := map[string]map[string]Value{}
+	important := map[string]bool{} // layer + "\x00" + property
+
+	var order []string
+
+	for _, rule := range s.Rules {
+		layer, ok := rule.matchingLayer(el, zoom)
+		if !ok {
+			continue
+		}
+
+		props, exists := layers[layer]
+		if !exists {
+			props = map[string]Value{}
+			layers[layer] = props
+			order = append(order, layer)
+		}
+
+		for _, decl := range rule.Declarations {
+			key := layer + "\x00" + decl.Property
+			if important[key] && !decl.Important {
+				continue
+			}
+
+			props[decl.Property] = decl.Value
+			important[key] = important[key] || decl.Important
+		}
+	}
+
+	return layers, order
+}
+
+// maxSetPasses bounds how often set directives are re-evaluated, so rules
+// setting classes or tags in a cycle cannot loop forever.
+const maxSetPasses = 8
+
+// applySets evaluates the "set .class" and "set tag=value" directives of all
+// matching rules, adding the classes and tags to the element. Because a set
+// can make further rules match, the rules are re-evaluated until nothing
+// changes or maxSetPasses is reached. The element passed in is not modified;
+// a copy is returned if any directive applied.
+func (s *Stylesheet) applySets(el *styleElement, zoom int) *styleElement {
+	copied := false
+
+	for pass := 0; pass < maxSetPasses; pass++ {
+		changed := false
+
+		for _, rule := range s.Rules {
+			if _, ok := rule.matchingLayer(el, zoom); !ok {
+				continue
+			}
+
+			for _, decl := range rule.Declarations {
+				class, tag, ok := decl.setTarget()
+				if !ok || el.hasSet(class, tag, decl.Value.Raw) {
+					continue
+				}
+
+				if !copied {
+					el = el.clone()
+					copied = true
+				}
+
+				if class != "" {
+					el.classes[class] = true
+				} else {
+					el.tags[tag] = decl.Value.Raw
+				}
+
+				changed = true
+			}
+		}
+
+		if !changed {
+			break
+		}
+	}
+
+	return el
+}
+
+// setTarget returns the class or tag key a set directive assigns.
+func (d *Declaration) setTarget() (class, tag string, ok bool) {
+	if d.Property == "set-class" {
+		return d.Value.Raw, "", d.Value.Raw != ""
+	}
+
+	if key, found := strings.CutPrefix(d.Property, "set-tag:"); found && key != "" {
+		return "", key, true
+	}
+
+	return "", "", false
+}
+
+// hasSet reports whether the class, or the tag with value, is already set.
+func (el *styleElement) hasSet(class, tag, value string) bool {
+	if class != "" {
+		return el.classes[class]
+	}
+
+	current, ok := el.tags[tag]
+
+	return ok && current == value
+}
+
+// clone returns a copy of el with its own tag and class maps.
+func (el *styleElement) clone() *styleElement {
+	c := *el
+	c.tags = make(map[string]string, len(el.tags))
+	c.classes = make(map[string]bool, len(el.classes))
+
+	for k, v := range el.tags {
+		c.tags[k] = v
+	}
+
+	for class := range el.classes {
+		c.classes[class] = true
+	}
+
+	return &c
+}
+
+// matchingLayer returns the layer of the first selector in the rule that matches.
+func (r *Rule) matchingLayer(el *styleElement, zoom int) (string, bool) {
+	for i := range r.Selectors {
+		sel := &r.Selectors[i]
+		if sel.matches(el, zoom) {
+			if sel.Layer == "" {
+				return defaultLayer, true
+			}
+
+			return sel.Layer, true
+		}
+	}
+
+	return "", false
+}
+
+func (sel *Selector) matches(el *styleElement, zoom int) bool {
+	if sel.Parent != nil {
+		return false
+	}
+
+	for _, pseudo := range sel.PseudoClasses {
+		if !el.hasPseudoClass(pseudo) {
+			return false
+		}
+	}
+
+	if !sel.matchesType(el) || !sel.matchesZoom(zoom) {
+		return false
+	}
+
+	for _, class := range sel.Classes {
+		if !el.classes[class] {
+			return false
+		}
+	}
+
+	for i := range sel.Conditions {
+		if !sel.Conditions[i].matches(el.tags) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (sel *Selector) matchesType(el *styleElement) bool {
+	switch sel.Type {
+	case "", "*":
+		return true
+	case "canvas", "meta":
+		return false
+	}
+
+	if el.osmType == "" {
+		return true
+	}
+
+	switch sel.Type {
+	case "line":
+		return el.osmType == osmTypeWay
+	case "area":
+		return el.osmType == osmTypeWay ||
+			(el.osmType == osmTypeRelation && el.tags["type"] == "multipolygon")
+	default:
+		return sel.Type == el.osmType
+	}
+}
+
+// hasPseudoClass evaluates a data-driven pseudo-class. Unknown and interactive
+// pseudo-classes (:hover, :active, ...) never hold.
+func (el *styleElement) hasPseudoClass(pseudo string) bool {
+	switch pseudo {
+	case "tagged":
+		return len(el.tags) > 0
+	case "closed":
+		return el.closed
+	case "area":
+		if el.osmType == osmTypeRelation {
+			return el.tags["type"] == "multipolygon"
+		}
+
+		return el.closed && overpass.ClosedWayIsArea(el.tags)
+	default:
+		return false
+	}
+}
+
+func (sel *Selector) matchesZoom(zoom int) bool {
+	if zoom < sel.ZoomMin {
+		return false
+	}
+
+	return sel.ZoomMax == 0 || zoom <= sel.ZoomMax
+}
+
+func (c *Condition) matches(tags map[string]string) bool {
+	value, exists := tags[c.Key]
+
+	switch c.Operator {
+	case "":
+		return exists
+	case "!":
+		return !exists
+	case "=":
+		return exists && value == c.Value
+	case "!=":
+		return !exists || value != c.Value
+	case "=~":
+		return exists && c.Regex != nil && c.Regex.MatchString(value)
+	case "!~":
+		return !exists || c.Regex == nil || !c.Regex.MatchString(value)
+	case "<", ">", "<=", ">=":
+		return exists && compareNumeric(value, c.Operator, c.Value)
+	default:
+		return false
+	}
+}
+
+func compareNumeric(tagValue, operator, condValue string) bool {
+	left, err := strconv.ParseFloat(strings.TrimSpace(tagValue), 64)
+	if err != nil {
+		return false
+	}
+
+	right, err := strconv.ParseFloat(strings.TrimSpace(condValue), 64)
+	if err != nil {
+		return false
+	}
+
+	switch operator {
+	case "<":
+		return left < right
+	case ">":
+		return left > right
+	case "<=":
+		return left <= right
+	default:
+		return left >= right
+	}
+}
+
+func numberProperty(props map[string]Value, name string) float64 {
+	if v, ok := props[name]; ok && v.Type == ValueTypeNumber {
+		return v.Number
+	}
+
+	return 0
+}
+
+func colorProperty(props map[string]Value, name string) *Color {
+	if v, ok := props[name]; ok && v.Type == ValueTypeColor {
+		return v.Color
+	}
+
+	return nil
+}
+
+// resolveText interprets a text declaration: quoted values are literal labels,
+// bare keywords name the tag whose value is displayed.
+func resolveText(v Value, tags map[string]string) string {
+	raw := strings.TrimSpace(v.Raw)
+	if isQuoted(raw) {
+		return unquote(raw)
+	}
+
+	if tagValue, ok := tags[raw]; ok {
+		return tagValue
+	}
+
+	return ""
+}
+
+func isQuoted(s string) bool {
+	return len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0]
+}
+
+func unquote(s string) string {
+	if isQuoted(s) {
+		return s[1 : len(s)-1]
+	}
+
+	return s
+}
@@ -0,0 +1,386 @@
+package turbo
+
+import (
+	"testing"
+)
+
+func mustParseMapCSS(t *testing.T, input string) *Stylesheet {
+	t.Helper()
+
+	stylesheet, err := ParseMapCSS(input)
+	if err != nil {
+		t.Fatalf("ParseMapCSS() error = %v", err)
+	}
+
+	return stylesheet
+}
+
+func TestMatchCascade(t *testing.T) {
+	t.Parallel()
+
+	stylesheet := mustParseMapCSS(t, `
+		way { color: black; width: 1; }
+		way[highway] { width: 2; }
+		way[highway=primary] { color: red; }
+		node { color: green; }
+	`)
+
+	props := stylesheet.Match("way", map[string]string{"highway": "primary"}, 15)
+
+	if got := props["color"].Raw; got != "red" {
+		t.Errorf("color = %q, want red", got)
+	}
+
+	if got := props["width"].Number; got != 2 {
+		t.Errorf("width = %v, want 2", got)
+	}
+
+	props = stylesheet.Match("node", map[string]string{"highway": "primary"}, 15)
+	if got := props["color"].Raw; got != "green" {
+		t.Errorf("node color = %q, want green", got)
+	}
+}
+
+func TestMatchConditions(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		selector string
+		tags     map[string]string
+		want     bool
+	}{
+		{"exists", "node[name]", map[string]string{"name": "x"}, true},
+		{"exists missing", "node[name]", map[string]string{}, false},
+		{"not exists", "node[!name]", map[string]string{}, true},
+		{"equals", "node[amenity=cafe]", map[string]string{"amenity": "cafe"}, true},
+		{"not equals", "node[amenity!=cafe]", map[string]string{"amenity": "bar"}, true},
+		{"not equals missing", "node[amenity!=cafe]", map[string]string{}, true},
+		{"regex", "node[name=~/^Caf/]", map[string]string{"name": "Cafe Central"}, true},
+		{"regex no match", "node[name=~/^Caf/]", map[string]string{"name": "Bar"}, false},
+		{"numeric greater", "node[population>1000]", map[string]string{"population": "5000"}, true},
+		{"numeric less", "node[population<1000]", map[string]string{"population": "5000"}, false},
+		{"numeric invalid", "node[population>1000]", map[string]string{"population": "many"}, false},
+		{"class not set", "node.minor", map[string]string{}, false},
+		{"hover ignored", "node:hover", map[string]string{}, false},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			stylesheet := mustParseMapCSS(t, tc.selector+" { color: red; }")
+
+			_, got := stylesheet.Match("node", tc.tags, 15)["color"]
+			if got != tc.want {
+				t.Errorf("match = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestMatchZoomAndType(t *testing.T) {
+	t.Parallel()
+
+	stylesheet := mustParseMapCSS(t, `
+		way|z14- { width: 4; }
+		area[building] { fill-color: gray; }
+		line { color: blue; }
+	`)
+
+	if _, ok := stylesheet.Match("way", nil, 12)["width"]; ok {
+		t.Error("zoom-limited rule should not match at z12")
+	}
+
+	if _, ok := stylesheet.Match("way", nil, 16)["width"]; !ok {
+		t.Error("zoom-limited rule should match at z16")
+	}
+
+	building := map[string]string{"building": "yes", "type": "multipolygon"}
+	if _, ok := stylesheet.Match("relation", building, 16)["fill-color"]; !ok {
+		t.Error("area selector should match multipolygon relation")
+	}
+
+	if _, ok := stylesheet.Match("node", building, 16)["fill-color"]; ok {
+		t.Error("area selector should not match node")
+	}
+
+	if _, ok := stylesheet.Match("way", nil, 16)["color"]; !ok {
+		t.Error("line selector should match way")
+	}
+}
+
+func TestMarkerStyle(t *testing.T) {
+	t.Parallel()
+
+	stylesheet := mustParseMapCSS(t, complexStylesheet)
+
+	t.Run("cafe node", func(t *testing.T) {
+		t.Parallel()
+
+		style := stylesheet.MarkerStyle(map[string]string{"amenity": "cafe"}, 16)
+
+		if style.IconURL != "icons/maki/cafe-18.png" {
+			t.Errorf("IconURL = %q", style.IconURL)
+		}
+
+		if style.IconWidth != 18 || style.IconHeight != 18 {
+			t.Errorf("icon size = %vx%v, want 18x18", style.IconWidth, style.IconHeight)
+		}
+
+		if style.Color != nil || style.Width != 0 {
+			t.Errorf("unexpected line style: %+v", style)
+		}
+	})
+
+	t.Run("primary highway", func(t *testing.T) {
+		t.Parallel()
+
+		style := stylesheet.MarkerStyle(map[string]string{"highway": "primary"}, 16)
+
+		if style.Color == nil || style.Color.Hex() != "#ff0000" {
+			t.Errorf("Color = %v, want #ff0000", style.Color)
+		}
+
+		if style.Width != 8 {
+			t.Errorf("Width = %v, want 8", style.Width)
+		}
+
+		if style.IconURL != "" || style.FillColor != nil {
+			t.Errorf("unexpected marker style: %+v", style)
+		}
+	})
+}
+
+func TestMarkerStyleTextAndDashes(t *testing.T) {
+	t.Parallel()
+
+	stylesheet := mustParseMapCSS(t, `
+		way[highway=path] { dashes: 4,2; text: name; }
+		node[place] { text: "City"; }
+	`)
+
+	style := stylesheet.MarkerStyle(map[string]string{"highway": "path", "name": "Uferweg"}, 16)
+
+	if style.Text != "Uferweg" {
+		t.Errorf("Text = %q, want Uferweg", style.Text)
+	}
+
+	if len(style.Dashes) != 2 || style.Dashes[0] != 4 || style.Dashes[1] != 2 {
+		t.Errorf("Dashes = %v, want [4 2]", style.Dashes)
+	}
+
+	style = stylesheet.MarkerStyle(map[string]string{"place": "city"}, 16)
+	if style.Text != "City" {
+		t.Errorf("Text = %q, want City", style.Text)
+	}
+}
+
+func TestRenderOrder(t *testing.T) {
+	t.Parallel()
+
+	stylesheet := mustParseMapCSS(t, `
+		way[highway] { color: white; width: 4; z-index: 2; }
+		way[highway]::casing { color: black; width: 6; z-index: -1; }
+		way[highway]::label { text: name; }
+		node { color: red; }
+	`)
+
+	layers := stylesheet.RenderOrder("way", map[string]string{"highway": "primary"}, 16)
+
+	expected := []struct {
+		layer  string
+		zIndex float64
+	}{
+		{"casing", -1},
+		{"label", 0},
+		{defaultLayer, 2},
+	}
+
+	if len(layers) != len(expected) {
+		t.Fatalf("expected %d layers, got %d: %+v", len(expected), len(layers), layers)
+	}
+
+	for i, want := range expected {
+		if layers[i].Layer != want.layer || layers[i].ZIndex != want.zIndex {
+			t.Errorf("layer %d: expected %s (z=%v), got %s (z=%v)",
+				i, want.layer, want.zIndex, layers[i].Layer, layers[i].ZIndex)
+		}
+	}
+
+	if layers[0].Properties["width"].Number != 6 {
+		t.Errorf("casing width = %v, want 6", layers[0].Properties["width"].Number)
+	}
+}
+
+func TestRenderOrderSourceOrderTies(t *testing.T) {
+	t.Parallel()
+
+	stylesheet := mustParseMapCSS(t, `
+		area[building]::fill { fill-color: #cccccc; }
+		area[building]::outline { color: #999999; }
+		area[building]::fill { z-index: 0; }
+	`)
+
+	layers := stylesheet.RenderOrder("way", map[string]string{"building": "yes"}, 16)
+	if len(layers) != 2 || layers[0].Layer != "fill" || layers[1].Layer != "outline" {
+		t.Fatalf("expected fill before outline, got %+v", layers)
+	}
+
+	if layers := stylesheet.RenderOrder("node", map[string]string{"amenity": "cafe"}, 16); len(layers) != 0 {
+		t.Errorf("expected no layers for unmatched element, got %+v", layers)
+	}
+}
+
+func TestMatchImportant(t *testing.T) {
+	t.Parallel()
+
+	stylesheet := mustParseMapCSS(t, `
+		way[highway] { color: red !important; width: 2; }
+		way[highway=primary] { color: blue; width: 6; }
+		way[highway=primary]::casing { color: black; }
+	`)
+
+	props := stylesheet.Match("way", map[string]string{"highway": "primary"}, 16)
+
+	if props["color"].Raw != "red" {
+		t.Errorf("important color should win over later rule, got %q", props["color"].Raw)
+	}
+
+	if props["width"].Number != 6 {
+		t.Errorf("non-important width should follow source order, got %v", props["width"].Number)
+	}
+
+	// A later important declaration still overrides an earlier important one
+	stylesheet = mustParseMapCSS(t, `
+		way { color: red !important; }
+		way { color: green !important; }
+	`)
+
+	if got := stylesheet.Match("way", nil, 16)["color"].Raw; got != "green" {
+		t.Errorf("expected later important declaration to win, got %q", got)
+	}
+}
+
+func TestMatchElementPseudoClasses(t *testing.T) {
+	t.Parallel()
+
+	stylesheet := mustParseMapCSS(t, `
+		node:tagged { color: red; }
+		way:closed { fill-color: #00ff00; }
+		way:area { text: "area"; }
+		way:hover { width: 10; }
+	`)
+
+	testCases := []struct {
+		name     string
+		element  StyleElement
+		property string
+		want     bool
+	}{
+		{"tagged node", StyleElement{Type: "node", Tags: map[string]string{"amenity": "cafe"}}, "color", true},
+		{"untagged node", StyleElement{Type: "node"}, "color", false},
+		{"closed way", StyleElement{Type: "way", Closed: true}, "fill-color", true},
+		{"open way", StyleElement{Type: "way"}, "fill-color", false},
+		{"closed area", StyleElement{Type: "way", Closed: true, Tags: map[string]string{"building": "yes"}}, "text", true},
+		{"closed non-area", StyleElement{Type: "way", Closed: true, Tags: map[string]string{"area": "no"}}, "text", false},
+		{"closed highway", StyleElement{Type: "way", Closed: true, Tags: map[string]string{"highway": "primary"}}, "text", false},
+		{"highway area", StyleElement{Type: "way", Closed: true, Tags: map[string]string{"highway": "pedestrian", "area": "yes"}}, "text", true},
+		{"hover never applies", StyleElement{Type: "way", Closed: true}, "width", false},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, got := stylesheet.MatchElement(tc.element, 16)[tc.property]
+			if got != tc.want {
+				t.Errorf("property %s present = %v, want %v", tc.property, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestMatchSetDirectives(t *testing.T) {
+	t.Parallel()
+
+	stylesheet := mustParseMapCSS(t, `
+		.minor_road { color: gray; }
+		way[highway=footpath] { set .minor_road; set surface_known; }
+		way[surface_known] { dashes: 2,2; }
+	`)
+
+	tags := map[string]string{"highway": "footpath"}
+
+	props := stylesheet.Match("way", tags, 16)
+	if props["color"].Raw != "gray" {
+		t.Errorf("expected class set by a later rule to apply, got %q", props["color"].Raw)
+	}
+
+	if _, ok := props["dashes"]; !ok {
+		t.Error("expected rule matching a set tag to apply")
+	}
+
+	if _, ok := tags["surface_known"]; ok {
+		t.Error("set directives must not modify the caller's tags")
+	}
+
+	if props := stylesheet.Match("way", map[string]string{"highway": "primary"}, 16); props["color"].Raw != "" {
+		t.Errorf("class selector should not match without set, got %q", props["color"].Raw)
+	}
+
+	// Tag sets that flip each other are cut off after a bounded number of passes
+	flipping := mustParseMapCSS(t, `
+		way[state=on] { set state=off; }
+		way[state=off] { set state=on; }
+		way { width: 3; }
+	`)
+
+	if props := flipping.Match("way", map[string]string{"state": "on"}, 16); props["width"].Number != 3 {
+		t.Errorf("expected matching to terminate with width 3, got %v", props["width"].Number)
+	}
+}
+
+func TestStylesheetCanvasAndMeta(t *testing.T) {
+	t.Parallel()
+
+	stylesheet := mustParseMapCSS(t, `
+		meta { title: "My Style"; author: 'Jane'; }
+		canvas { fill-color: #eee; }
+		node[amenity] { fill-color: red; }
+		canvas { opacity: 0.5; }
+	`)
+
+	canvas := stylesheet.Canvas()
+	if len(canvas) != 2 {
+		t.Fatalf("expected 2 canvas properties, got %v", canvas)
+	}
+
+	fill := canvas["fill-color"]
+	if fill.Color == nil || fill.Color.R != 0xee/255.0 || fill.Color.A != 1 {
+		t.Errorf("expected #eee fill, got %+v", fill)
+	}
+
+	if canvas["opacity"].Number != 0.5 {
+		t.Errorf("expected opacity 0.5, got %+v", canvas["opacity"])
+	}
+
+	meta := stylesheet.Meta()
+	if meta["title"] != "My Style" || meta["author"] != "Jane" || len(meta) != 2 {
+		t.Errorf("unexpected meta %v", meta)
+	}
+
+	// Canvas and meta rules never style elements
+	if props := stylesheet.Match("node", map[string]string{}, 18); len(props) != 0 {
+		t.Errorf("expected no element properties, got %v", props)
+	}
+
+	empty := mustParseMapCSS(t, `way { width: 2; }`)
+	if len(empty.Canvas()) != 0 || len(empty.Meta()) != 0 {
+		t.Error("expected empty canvas and meta")
+	}
+}
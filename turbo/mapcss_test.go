@@ -304,6 +304,96 @@ func TestParseMapCSSDeclarations(t *testing.T) {
 	})
 }
 
+func TestParseMapCSSImportant(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		input     string
+		wantType  ValueType
+		wantRaw   string
+		important bool
+	}{
+		{"way { color: red !important; }", ValueTypeColor, "red", true},
+		{"way { color: #ff0000 !important; }", ValueTypeColor, "#ff0000", true},
+		{"way { width: 4!important }", ValueTypeNumber, "4", true},
+		{"node { icon-image: url('a.png') !important; }", ValueTypeURL, "url('a.png')", true},
+		{"way { color: red; }", ValueTypeColor, "red", false},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.input, func(t *testing.T) {
+			t.Parallel()
+
+			stylesheet, err := ParseMapCSS(tc.input)
+			if err != nil {
+				t.Fatalf("ParseMapCSS() error = %v", err)
+			}
+
+			decls := stylesheet.Rules[0].Declarations
+			if len(decls) != 1 {
+				t.Fatalf("expected 1 declaration, got %+v", decls)
+			}
+
+			decl := decls[0]
+			if decl.Important != tc.important || decl.Value.Type != tc.wantType || decl.Value.Raw != tc.wantRaw {
+				t.Errorf("got important=%v type=%v raw=%q, want important=%v type=%v raw=%q",
+					decl.Important, decl.Value.Type, decl.Value.Raw, tc.important, tc.wantType, tc.wantRaw)
+			}
+		})
+	}
+}
+
+func TestParseMapCSSNumberUnits(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		input    string
+		wantNum  float64
+		wantUnit string
+		wantRaw  string
+	}{
+		{"way { width: 2px; }", 2, "px", "2px"},
+		{"node { font-size: 1.5em; }", 1.5, "em", "1.5em"},
+		{"node { font-size: 12pt; }", 12, "pt", "12pt"},
+		{"way { width: 3; }", 3, "", "3"},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.wantRaw, func(t *testing.T) {
+			t.Parallel()
+
+			stylesheet, err := ParseMapCSS(tc.input)
+			if err != nil {
+				t.Fatalf("ParseMapCSS() error = %v", err)
+			}
+
+			value := stylesheet.Rules[0].Declarations[0].Value
+			if value.Type != ValueTypeNumber {
+				t.Fatalf("got type %v, want number", value.Type)
+			}
+
+			if value.Number != tc.wantNum || value.Unit != tc.wantUnit || value.Raw != tc.wantRaw {
+				t.Errorf("got %v %q (raw %q), want %v %q (raw %q)",
+					value.Number, value.Unit, value.Raw, tc.wantNum, tc.wantUnit, tc.wantRaw)
+			}
+		})
+	}
+
+	// Keywords that happen to end in a unit suffix stay keywords
+	stylesheet, err := ParseMapCSS("way { linecap: bottom; }")
+	if err != nil {
+		t.Fatalf("ParseMapCSS() error = %v", err)
+	}
+
+	if value := stylesheet.Rules[0].Declarations[0].Value; value.Type != ValueTypeKeyword {
+		t.Errorf("got type %v, want keyword", value.Type)
+	}
+}
+
 func TestParseMapCSSMultipleSelectors(t *testing.T) {
 	t.Parallel()
 
@@ -543,6 +633,20 @@ func TestParseMapCSSLayer(t *testing.T) {
 	}
 }
 
+func TestParseMapCSSLayerAfterConditions(t *testing.T) {
+	t.Parallel()
+
+	stylesheet, err := ParseMapCSS("way|z14-[highway=primary]::casing { width: 10; }")
+	if err != nil {
+		t.Fatalf("ParseMapCSS() error = %v", err)
+	}
+
+	sel := stylesheet.Rules[0].Selectors[0]
+	if sel.Layer != "casing" || len(sel.Conditions) != 1 || sel.ZoomMin != 14 {
+		t.Errorf("unexpected selector: %+v", sel)
+	}
+}
+
 func TestParseMapCSSZoomRange(t *testing.T) {
 	t.Parallel()
 
@@ -680,46 +784,47 @@ func TestParseMapCSSQuotedStrings(t *testing.T) {
 	}
 }
 
-func TestParseMapCSSComplexStylesheet(t *testing.T) {
-	t.Parallel()
+// complexStylesheet is a realistic Overpass Turbo style shared by parser and cascade tests.
+const complexStylesheet = `
+	/* Overpass Turbo MapCSS example */
+	node[amenity=cafe] {
+		icon-image: url('icons/maki/cafe-18.png');
+		icon-width: 18;
+		icon-height: 18;
+	}
 
-	input := `
-		/* Overpass Turbo MapCSS example */
-		node[amenity=cafe] {
-			icon-image: url('icons/maki/cafe-18.png');
-			icon-width: 18;
-			icon-height: 18;
-		}
+	way[highway=primary] {
+		color: #ff0000;
+		width: 8;
+		casing-width: 2;
+		casing-color: #660000;
+	}
 
-		way[highway=primary] {
-			color: #ff0000;
-			width: 8;
-			casing-width: 2;
-			casing-color: #660000;
-		}
+	way[highway=secondary], way[highway=tertiary] {
+		color: #ffcc00;
+		width: 6;
+	}
 
-		way[highway=secondary], way[highway=tertiary] {
-			color: #ffcc00;
-			width: 6;
-		}
+	area[building] {
+		fill-color: rgba(0.5, 0.5, 0.5, 0.3);
+		color: #333333;
+		width: 1;
+	}
 
-		area[building] {
-			fill-color: rgba(0.5, 0.5, 0.5, 0.3);
-			color: #333333;
-			width: 1;
-		}
+	way:hover {
+		color: blue;
+	}
 
-		way:hover {
-			color: blue;
-		}
+	relation[type=route] way {
+		color: purple;
+		width: 3;
+	}
+`
 
-		relation[type=route] way {
-			color: purple;
-			width: 3;
-		}
-	`
+func TestParseMapCSSComplexStylesheet(t *testing.T) {
+	t.Parallel()
 
-	stylesheet, err := ParseMapCSS(input)
+	stylesheet, err := ParseMapCSS(complexStylesheet)
 	if err != nil {
 		t.Fatalf("ParseMapCSS() error = %v", err)
 	}
@@ -824,3 +929,31 @@ func abs(x float64) float64 {
 
 	return x
 }
+
+func TestMergedStylesheet(t *testing.T) {
+	t.Parallel()
+
+	query := `{{style: node { color: red; } way { width: 2; } }}{{style: node { color: blue; } }}node(1);out;`
+
+	res, err := Expand(query, Options{})
+	if err != nil {
+		t.Fatalf("Expand() error = %v", err)
+	}
+
+	merged := res.MergedStylesheet()
+
+	want := len(res.ParsedStyles[0].Rules) + len(res.ParsedStyles[1].Rules)
+	if want != 3 || len(merged.Rules) != want {
+		t.Fatalf("got %d merged rules, want 3", len(merged.Rules))
+	}
+
+	// The later block wins the cascade
+	if got := merged.Match("node", nil, 16)["color"].Raw; got != "blue" {
+		t.Errorf("got color %q, want blue", got)
+	}
+
+	empty := Result{ParsedStyles: []*Stylesheet{nil}}
+	if sheet := empty.MergedStylesheet(); sheet == nil || len(sheet.Rules) != 0 {
+		t.Errorf("expected empty stylesheet, got %+v", sheet)
+	}
+}
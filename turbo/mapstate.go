@@ -0,0 +1,42 @@
+package turbo
+
+import "math"
+
+// tileSize is the edge length in pixels of a web-mercator tile at zoom 0.
+const tileSize = 256
+
+// FromMapState returns the bounding box shown by a web-mercator map (as used
+// by Leaflet, OpenLayers and Overpass Turbo itself) of widthPx×heightPx pixels
+// centered on centerLat/centerLon at the given zoom level. Longitudes are
+// clamped to ±180 and latitudes to the mercator limit of about ±85.05, so a
+// viewport larger than the world yields the whole map. The result is suitable
+// for Options.BBox.
+func FromMapState(zoom int, centerLat, centerLon float64, widthPx, heightPx int) BBox {
+	worldSize := tileSize * math.Exp2(float64(zoom))
+
+	x := (centerLon + 180) / 360 * worldSize
+	y := mercatorY(centerLat) * worldSize
+
+	halfWidth := float64(widthPx) / 2
+	halfHeight := float64(heightPx) / 2
+
+	return BBox{
+		South: mercatorLat(math.Min(y+halfHeight, worldSize) / worldSize),
+		West:  math.Max((x-halfWidth)/worldSize*360-180, -180),
+		North: mercatorLat(math.Max(y-halfHeight, 0) / worldSize),
+		East:  math.Min((x+halfWidth)/worldSize*360-180, 180),
+	}
+}
+
+// mercatorY projects lat to a fraction of the map height, 0 at the top.
+func mercatorY(lat float64) float64 {
+	sin := math.Sin(lat * math.Pi / 180)
+	y := 0.5 - math.Log((1+sin)/(1-sin))/(4*math.Pi)
+
+	return math.Min(math.Max(y, 0), 1)
+}
+
+// mercatorLat is the inverse of mercatorY.
+func mercatorLat(y float64) float64 {
+	return math.Atan(math.Sinh(math.Pi*(1-2*y))) * 180 / math.Pi
+}
@@ -0,0 +1,58 @@
+package turbo
+
+import (
+	"math"
+	"testing"
+)
+
+func TestFromMapState(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		zoom     int
+		lat, lon float64
+		width    int
+		height   int
+		expected BBox
+	}{
+		{
+			name:  "single tile at zoom 0",
+			zoom:  0,
+			width: 256, height: 256,
+			expected: BBox{South: -85.0511288, West: -180, North: 85.0511288, East: 180},
+		},
+		{
+			name: "south-east quarter at zoom 1",
+			zoom: 1, lat: -66.5132604, lon: 90,
+			width: 256, height: 256,
+			expected: BBox{South: -85.0511288, West: 0, North: 0, East: 180},
+		},
+		{
+			name: "Berlin viewport",
+			zoom: 14, lat: 52.52, lon: 13.405,
+			width: 800, height: 600,
+			expected: BBox{South: 52.5043292, West: 13.3706677, North: 52.5356652, East: 13.4393323},
+		},
+		{
+			name:  "viewport larger than the world",
+			zoom:  0,
+			width: 1024, height: 1024,
+			expected: BBox{South: -85.0511288, West: -180, North: 85.0511288, East: 180},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			got := FromMapState(tc.zoom, tc.lat, tc.lon, tc.width, tc.height)
+
+			if math.Abs(got.South-tc.expected.South) > 1e-6 || math.Abs(got.West-tc.expected.West) > 1e-6 ||
+				math.Abs(got.North-tc.expected.North) > 1e-6 || math.Abs(got.East-tc.expected.East) > 1e-6 {
+				t.Errorf("expected %+v, got %+v", tc.expected, got)
+			}
+		})
+	}
+}
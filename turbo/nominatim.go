@@ -0,0 +1,145 @@
+package turbo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/MeKo-Christian/go-overpass"
+)
+
+// DefaultNominatimEndpoint is the public Nominatim instance of the OSM Foundation.
+const DefaultNominatimEndpoint = "https://nominatim.openstreetmap.org"
+
+// ErrNoGeocodeMatch is returned when the geocoding service finds no match.
+var ErrNoGeocodeMatch = errors.New("turbo: no geocoding match")
+
+// NominatimGeocoder resolves names with the Nominatim search API. It
+// implements Geocoder, GeocoderContext and LanguageGeocoder.
+type NominatimGeocoder struct {
+	// Endpoint is the Nominatim base URL (DefaultNominatimEndpoint if empty).
+	Endpoint string
+	// HTTPClient performs the requests (http.DefaultClient if nil).
+	HTTPClient overpass.HTTPClient
+	// UserAgent identifies the application, as required by the Nominatim
+	// usage policy.
+	UserAgent string
+	// AcceptLanguage is sent as Accept-Language header (e.g. "de" or
+	// "de,en;q=0.8") so display names come back localized. Empty leaves the
+	// choice to the server.
+	AcceptLanguage string
+}
+
+// LanguageGeocoder is implemented by geocoders that can localize results.
+// ExpandContext uses it when Options.Language is set.
+type LanguageGeocoder interface {
+	Geocoder
+	GeocodeLanguage(ctx context.Context, query, language string) (GeocodeResult, error)
+}
+
+// nominatimPlace is a single jsonv2 search result.
+type nominatimPlace struct {
+	OSMType     string   `json:"osm_type"`
+	OSMID       int64    `json:"osm_id"`
+	DisplayName string   `json:"display_name"`
+	Lat         string   `json:"lat"`
+	Lon         string   `json:"lon"`
+	BoundingBox []string `json:"boundingbox"` // south, north, west, east
+}
+
+// Geocode resolves query using context.Background.
+func (g *NominatimGeocoder) Geocode(query string) (GeocodeResult, error) {
+	return g.GeocodeContext(context.Background(), query)
+}
+
+// GeocodeContext resolves query to the first Nominatim match.
+func (g *NominatimGeocoder) GeocodeContext(ctx context.Context, query string) (GeocodeResult, error) {
+	return g.GeocodeLanguage(ctx, query, g.AcceptLanguage)
+}
+
+// GeocodeLanguage resolves query with language as Accept-Language header,
+// overriding AcceptLanguage for this request.
+func (g *NominatimGeocoder) GeocodeLanguage(ctx context.Context, query, language string) (GeocodeResult, error) {
+	endpoint := g.Endpoint
+	if endpoint == "" {
+		endpoint = DefaultNominatimEndpoint
+	}
+
+	params := url.Values{"q": {query}, "format": {"jsonv2"}, "limit": {"1"}}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		strings.TrimRight(endpoint, "/")+"/search?"+params.Encode(), nil)
+	if err != nil {
+		return GeocodeResult{}, fmt.Errorf("nominatim: %w", err)
+	}
+
+	if g.UserAgent != "" {
+		req.Header.Set("User-Agent", g.UserAgent)
+	}
+
+	if language != "" {
+		req.Header.Set("Accept-Language", language)
+	}
+
+	httpClient := g.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return GeocodeResult{}, fmt.Errorf("nominatim: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return GeocodeResult{}, fmt.Errorf("nominatim: %w", &overpass.ServerError{StatusCode: resp.StatusCode})
+	}
+
+	var places []nominatimPlace
+
+	err = json.NewDecoder(resp.Body).Decode(&places)
+	if err != nil {
+		return GeocodeResult{}, fmt.Errorf("nominatim: %w", err)
+	}
+
+	if len(places) == 0 {
+		return GeocodeResult{}, fmt.Errorf("%w for %q", ErrNoGeocodeMatch, query)
+	}
+
+	return places[0].geocodeResult(), nil
+}
+
+// geocodeResult converts the place, skipping coordinates that do not parse.
+func (p *nominatimPlace) geocodeResult() GeocodeResult {
+	result := GeocodeResult{OSMType: p.OSMType, OSMID: p.OSMID, DisplayName: p.DisplayName}
+
+	lat, latErr := strconv.ParseFloat(p.Lat, 64)
+	lon, lonErr := strconv.ParseFloat(p.Lon, 64)
+
+	if latErr == nil && lonErr == nil {
+		result.Center = &Center{Lat: lat, Lon: lon}
+	}
+
+	if len(p.BoundingBox) == 4 {
+		var coords [4]float64
+
+		for i, s := range p.BoundingBox {
+			v, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				return result
+			}
+
+			coords[i] = v
+		}
+
+		result.BBox = &BBox{South: coords[0], North: coords[1], West: coords[2], East: coords[3]}
+	}
+
+	return result
+}
@@ -0,0 +1,147 @@
+package turbo
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/MeKo-Christian/go-overpass"
+)
+
+// nominatimMock answers searches with a display name in the requested language.
+type nominatimMock struct {
+	mu       sync.Mutex
+	requests []*http.Request
+	status   int
+	body     string // overrides the localized answer if set
+}
+
+func (m *nominatimMock) Do(req *http.Request) (*http.Response, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.requests = append(m.requests, req)
+
+	name := "Munich, Bavaria, Germany"
+	if req.Header.Get("Accept-Language") == "de" {
+		name = "München, Bayern, Deutschland"
+	}
+
+	body := m.body
+	if body == "" {
+		body = `[{"osm_type":"relation","osm_id":62428,"display_name":"` + name + `",` +
+			`"lat":"48.1371079","lon":"11.5753822","boundingbox":["48.0616","48.2481","11.3607","11.7229"]}]`
+	}
+
+	status := m.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	return &http.Response{StatusCode: status, Body: io.NopCloser(bytes.NewBufferString(body))}, nil
+}
+
+func (m *nominatimMock) lastRequest() *http.Request {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.requests[len(m.requests)-1]
+}
+
+func TestNominatimGeocoder(t *testing.T) {
+	t.Parallel()
+
+	mock := &nominatimMock{}
+	geocoder := &NominatimGeocoder{
+		Endpoint:       "https://nominatim.example/",
+		HTTPClient:     mock,
+		UserAgent:      "test-app/1.0",
+		AcceptLanguage: "de",
+	}
+
+	result, err := geocoder.Geocode("München")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	req := mock.lastRequest()
+	if got := req.Header.Get("Accept-Language"); got != "de" {
+		t.Errorf("expected Accept-Language de, got %q", got)
+	}
+
+	if got := req.Header.Get("User-Agent"); got != "test-app/1.0" {
+		t.Errorf("expected User-Agent, got %q", got)
+	}
+
+	if req.URL.Path != "/search" || req.URL.Query().Get("q") != "München" || req.URL.Query().Get("format") != "jsonv2" {
+		t.Errorf("unexpected request URL %s", req.URL)
+	}
+
+	if result.DisplayName != "München, Bayern, Deutschland" {
+		t.Errorf("expected localized display name, got %q", result.DisplayName)
+	}
+
+	if result.OSMType != "relation" || result.OSMID != 62428 {
+		t.Errorf("unexpected OSM reference %s %d", result.OSMType, result.OSMID)
+	}
+
+	if result.Center == nil || result.Center.Lat != 48.1371079 || result.Center.Lon != 11.5753822 {
+		t.Errorf("unexpected center %+v", result.Center)
+	}
+
+	expectedBBox := BBox{South: 48.0616, West: 11.3607, North: 48.2481, East: 11.7229}
+	if result.BBox == nil || *result.BBox != expectedBBox {
+		t.Errorf("expected bbox %+v, got %+v", expectedBBox, result.BBox)
+	}
+}
+
+func TestNominatimGeocoderLanguageOption(t *testing.T) {
+	t.Parallel()
+
+	mock := &nominatimMock{}
+	geocoder := &NominatimGeocoder{Endpoint: "https://nominatim.example", HTTPClient: mock}
+
+	res, err := ExpandContext(context.Background(), `{{geocodeArea:Munich}};out;`,
+		Options{Geocoder: geocoder, Language: "de"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := mock.lastRequest().Header.Get("Accept-Language"); got != "de" {
+		t.Errorf("expected Options.Language as Accept-Language, got %q", got)
+	}
+
+	if res.Query != "area(3600062428);out;" {
+		t.Errorf("unexpected expansion %q", res.Query)
+	}
+
+	// Without a language no header is sent
+	_, err = geocoder.Geocode("Munich")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := mock.lastRequest().Header.Get("Accept-Language"); got != "" {
+		t.Errorf("expected no Accept-Language header, got %q", got)
+	}
+}
+
+func TestNominatimGeocoderErrors(t *testing.T) {
+	t.Parallel()
+
+	_, err := (&NominatimGeocoder{HTTPClient: &nominatimMock{body: "[]"}}).Geocode("Nowhere")
+	if !errors.Is(err, ErrNoGeocodeMatch) {
+		t.Errorf("expected ErrNoGeocodeMatch, got %v", err)
+	}
+
+	_, err = (&NominatimGeocoder{HTTPClient: &nominatimMock{status: http.StatusTooManyRequests}}).Geocode("Berlin")
+
+	var serverErr *overpass.ServerError
+	if !errors.As(err, &serverErr) || serverErr.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("expected ServerError 429, got %v", err)
+	}
+}
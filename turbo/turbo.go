@@ -2,11 +2,14 @@ package turbo
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/MeKo-Christian/go-overpass"
 )
 
 // BBox represents a bounding box in south, west, north, east order.
@@ -33,9 +36,23 @@ type DataSource struct {
 // DataOptions exposes typed access to common {{data:...}} parameters.
 type DataOptions struct {
 	Server string
+	// Format is the requested output format (json, xml or csv), lower-cased.
+	Format string
 	Params map[string]string
 }
 
+// queryFormat maps the requested output format to a query dialect.
+func (o DataOptions) queryFormat() QueryFormat {
+	switch o.Format {
+	case "xml":
+		return FormatXML
+	case "json", "csv":
+		return FormatQL
+	default:
+		return FormatAuto
+	}
+}
+
 // Options control macro expansion.
 type Options struct {
 	BBox      *BBox
@@ -44,8 +61,43 @@ type Options struct {
 	Shortcuts map[string]string
 	Geocoder  Geocoder
 	Format    QueryFormat
+	// Language is the preferred language (e.g. "de") for geocoders
+	// implementing LanguageGeocoder, such as NominatimGeocoder.
+	Language string
+	// GeocodeAreaSet names the set assigned by {{geocodeArea:...}} macros used in
+	// statement position (directly followed by ";"), producing e.g.
+	// area(3600001645)->.searchArea; Macros used inside expressions stay bare.
+	// Empty keeps the bare form everywhere.
+	GeocodeAreaSet string
+	// UnknownMacroMode controls how unrecognized macros are handled.
+	UnknownMacroMode UnknownMacroMode
+	// Precision is the number of decimals coordinates from {{bbox}},
+	// {{center}} and geocode macros are written with. Values below 1 use
+	// overpass.DefaultCoordinatePrecision.
+	Precision int
 }
 
+// precision returns the effective number of coordinate decimals.
+func (o Options) precision() int {
+	if o.Precision < 1 {
+		return overpass.DefaultCoordinatePrecision
+	}
+
+	return o.Precision
+}
+
+// UnknownMacroMode selects how Expand treats macros it does not recognize.
+type UnknownMacroMode int
+
+const (
+	// UnknownMacroError fails expansion with ErrBadMacro (default).
+	UnknownMacroError UnknownMacroMode = iota
+	// UnknownMacroKeep leaves the macro text, including braces, verbatim.
+	UnknownMacroKeep
+	// UnknownMacroStrip removes the macro from the query.
+	UnknownMacroStrip
+)
+
 // Result holds the expanded query and any extracted metadata.
 type Result struct {
 	Query  string
@@ -61,6 +113,21 @@ type Result struct {
 	DataServer string
 }
 
+// MergedStylesheet concatenates the rules of all parsed {{style:...}} blocks
+// in order, so later blocks override earlier ones like a single stylesheet.
+// Styles that failed to parse are skipped; the result is never nil.
+func (r Result) MergedStylesheet() *Stylesheet {
+	merged := &Stylesheet{}
+
+	for _, sheet := range r.ParsedStyles {
+		if sheet != nil {
+			merged.Rules = append(merged.Rules, sheet.Rules...)
+		}
+	}
+
+	return merged
+}
+
 // QueryFormat controls how macros are expanded.
 type QueryFormat int
 
@@ -87,47 +154,84 @@ var (
 	ErrMissingGeocoder = errors.New("turbo: geocoder not provided")
 	ErrGeocodeData     = errors.New("turbo: geocoder result missing data")
 	ErrBadMacro        = errors.New("turbo: unsupported or malformed macro")
+	ErrMacroCycle      = errors.New("turbo: cyclic shortcut definition")
+
+	errUnknownMacro = fmt.Errorf("%w: unknown macro", ErrBadMacro)
 )
 
+// maxShortcutDepth bounds how deeply shortcut values may reference other macros.
+const maxShortcutDepth = 16
+
 // Expand replaces a subset of Overpass Turbo macros with Overpass QL compatible text.
 //
 // Supported macros:
 //   - {{bbox}} and {{center}} using Options.BBox/Options.Center
 //   - {{date}} and {{date:<n unit>}} using Options.Now (UTC if set, else time.Now)
-//   - Custom shortcuts: {{key=value}} defines {{key}}
+//   - Custom shortcuts: {{key=value}} defines {{key}}; values from
+//     Options.Shortcuts may themselves contain macros, which are expanded
+//     recursively (cycles return ErrMacroCycle)
 //   - {{style:...}} and {{data:...}} are removed from output and returned in Result
 //
+// Macros are formatted for the query dialect chosen by, in order of precedence:
+// an explicit Options.Format, a format=xml|json|csv option of a {{data:...}}
+// macro (xml selects XML, json and csv select QL), and detection of XML markers
+// in the query text.
+//
 // Unsupported geocode macros return an error for now.
 func Expand(query string, opts Options) (Result, error) {
-	format := detectFormat(query, opts.Format)
+	return ExpandContext(context.Background(), query, opts)
+}
 
+// ExpandContext works like Expand but passes ctx to geocoders implementing
+// GeocoderContext, so geocoding can be cancelled or timed out. Expansion
+// stops with the context's error once ctx is done.
+func ExpandContext(ctx context.Context, query string, opts Options) (Result, error) {
 	shortcuts := map[string]string{}
 	for k, v := range opts.Shortcuts {
 		shortcuts[k] = v
 	}
 
+	dataFormat := FormatAuto
+
 	err := scanMacros(query, func(_ int, _ int, content string) error {
 		name, value, ok := parseShortcutDefinition(content)
 		if ok {
 			shortcuts[name] = value
 		}
 
+		// Malformed data macros are reported during expansion
+		if raw, isData := strings.CutPrefix(strings.TrimSpace(content), "data:"); isData {
+			if dataSrc, err := parseDataSource(raw); err == nil {
+				dataFormat = dataSrc.Parsed.queryFormat()
+			}
+		}
+
 		return nil
 	})
 	if err != nil {
 		return Result{}, err
 	}
 
+	format := opts.Format
+	if format == FormatAuto {
+		format = detectFormat(query, dataFormat)
+	}
+
 	var res Result
 
 	expander := &macroExpander{
+		ctx:       ctx,
 		result:    &res,
 		opts:      opts,
 		format:    format,
 		shortcuts: shortcuts,
 	}
 
-	expanded, err := replaceMacros(query, expander.expandMacro)
+	expanded, err := replaceMacros(query, func(_ int, end int, content string) (string, error) {
+		expander.statement = isStatementPosition(query, end)
+
+		return expander.expand(content)
+	})
 	if err != nil {
 		return Result{}, err
 	}
@@ -137,11 +241,42 @@ func Expand(query string, opts Options) (Result, error) {
 	return res, nil
 }
 
+// StripMacros removes every {{...}} macro from query without expanding it and
+// returns the remaining text, e.g. for servers that do not understand macros.
+// Unlike Expand it needs no bounding box, geocoder or other data; an
+// unterminated macro returns ErrBadMacro.
+func StripMacros(query string) (string, error) {
+	return replaceMacros(query, func(int, int, string) (string, error) {
+		return "", nil
+	})
+}
+
 type macroExpander struct {
+	ctx       context.Context //nolint:containedctx // scoped to a single expansion
 	result    *Result
 	opts      Options
 	format    QueryFormat
 	shortcuts map[string]string
+	statement bool     // current macro is directly followed by ";"
+	expanding []string // shortcuts currently being expanded, outermost first
+}
+
+// expand expands a single macro, applying Options.UnknownMacroMode to
+// macros that are not recognized.
+func (e *macroExpander) expand(content string) (string, error) {
+	value, err := e.expandMacro(content)
+	if !errors.Is(err, errUnknownMacro) {
+		return value, err
+	}
+
+	switch e.opts.UnknownMacroMode {
+	case UnknownMacroKeep:
+		return "{{" + content + "}}", nil
+	case UnknownMacroStrip:
+		return "", nil
+	default:
+		return "", err
+	}
 }
 
 func (e *macroExpander) expandMacro(content string) (string, error) {
@@ -163,7 +298,7 @@ func (e *macroExpander) expandMacro(content string) (string, error) {
 	}
 
 	if strings.HasPrefix(content, "geocode") {
-		return expandGeocode(content, e.opts, e.format)
+		return expandGeocode(e.ctx, content, e.opts, e.format, e.statement)
 	}
 
 	if content == "bbox" {
@@ -179,10 +314,38 @@ func (e *macroExpander) expandMacro(content string) (string, error) {
 	}
 
 	if value, ok := e.shortcuts[content]; ok {
-		return value, nil
+		return e.expandShortcut(content, value)
+	}
+
+	return "", fmt.Errorf("%w {{%s}}", errUnknownMacro, content)
+}
+
+// expandShortcut resolves macros referenced inside a shortcut value.
+func (e *macroExpander) expandShortcut(name, value string) (string, error) {
+	for _, active := range e.expanding {
+		if active == name {
+			return "", fmt.Errorf("%w: %s -> %s", ErrMacroCycle, strings.Join(e.expanding, " -> "), name)
+		}
 	}
 
-	return "", ErrBadMacro
+	if len(e.expanding) >= maxShortcutDepth {
+		return "", fmt.Errorf("%w: shortcuts nested deeper than %d", ErrBadMacro, maxShortcutDepth)
+	}
+
+	e.expanding = append(e.expanding, name)
+	outerStatement := e.statement
+
+	defer func() {
+		e.expanding = e.expanding[:len(e.expanding)-1]
+		e.statement = outerStatement
+	}()
+
+	return replaceMacros(value, func(_ int, end int, content string) (string, error) {
+		e.statement = isStatementPosition(value, end) ||
+			(outerStatement && strings.TrimSpace(value[end:]) == "")
+
+		return e.expand(content)
+	})
 }
 
 func (e *macroExpander) expandStyleMacro(content string) (string, error) {
@@ -229,7 +392,7 @@ func (e *macroExpander) expandBBoxMacro() (string, error) {
 		return "", ErrMissingBBox
 	}
 
-	return formatBBox(*e.opts.BBox, e.format), nil
+	return formatBBox(*e.opts.BBox, e.format, e.opts.precision()), nil
 }
 
 func (e *macroExpander) expandCenterMacro() (string, error) {
@@ -237,7 +400,7 @@ func (e *macroExpander) expandCenterMacro() (string, error) {
 		return "", ErrMissingCenter
 	}
 
-	return formatCenter(*e.opts.Center, e.format), nil
+	return formatCenter(*e.opts.Center, e.format, e.opts.precision()), nil
 }
 
 // ApplyEndpointOverride returns the endpoint to use based on Result.EndpointOverride.
@@ -289,42 +452,43 @@ func detectFormat(query string, format QueryFormat) QueryFormat {
 	return FormatQL
 }
 
-func formatBBox(bbox BBox, format QueryFormat) string {
+func formatBBox(bbox BBox, format QueryFormat, precision int) string {
 	switch format {
 	case FormatXML:
 		return fmt.Sprintf(`s="%s" w="%s" n="%s" e="%s"`,
-			formatFloat(bbox.South),
-			formatFloat(bbox.West),
-			formatFloat(bbox.North),
-			formatFloat(bbox.East),
+			formatFloat(bbox.South, precision),
+			formatFloat(bbox.West, precision),
+			formatFloat(bbox.North, precision),
+			formatFloat(bbox.East, precision),
 		)
 	default:
 		return fmt.Sprintf("%s,%s,%s,%s",
-			formatFloat(bbox.South),
-			formatFloat(bbox.West),
-			formatFloat(bbox.North),
-			formatFloat(bbox.East),
+			formatFloat(bbox.South, precision),
+			formatFloat(bbox.West, precision),
+			formatFloat(bbox.North, precision),
+			formatFloat(bbox.East, precision),
 		)
 	}
 }
 
-func formatCenter(center Center, format QueryFormat) string {
+func formatCenter(center Center, format QueryFormat, precision int) string {
 	switch format {
 	case FormatXML:
 		return fmt.Sprintf(`lat="%s" lon="%s"`,
-			formatFloat(center.Lat),
-			formatFloat(center.Lon),
+			formatFloat(center.Lat, precision),
+			formatFloat(center.Lon, precision),
 		)
 	default:
 		return fmt.Sprintf("%s,%s",
-			formatFloat(center.Lat),
-			formatFloat(center.Lon),
+			formatFloat(center.Lat, precision),
+			formatFloat(center.Lon, precision),
 		)
 	}
 }
 
-func formatFloat(v float64) string {
-	return strconv.FormatFloat(v, 'f', -1, 64)
+// formatFloat formats a coordinate the same way the overpass query builder does.
+func formatFloat(v float64, precision int) string {
+	return overpass.FormatCoordinate(v, precision)
 }
 
 func parseShortcutDefinition(content string) (string, string, bool) {
@@ -380,9 +544,13 @@ func parseDataSource(raw string) (*DataSource, error) {
 		value := strings.TrimSpace(keyValue[1])
 
 		options[key] = value
-		if key == "server" {
+
+		switch key {
+		case "server":
 			parsed.Server = value
-		} else {
+		case "format":
+			parsed.Format = strings.ToLower(value)
+		default:
 			if parsed.Params == nil {
 				parsed.Params = map[string]string{}
 			}
@@ -521,14 +689,21 @@ func scanMacros(query string, callback func(start int, end int, content string)
 	return nil
 }
 
-func replaceMacros(query string, replace func(content string) (string, error)) (string, error) {
+// isStatementPosition reports whether the macro ending at end is followed by ";".
+func isStatementPosition(query string, end int) bool {
+	rest := strings.TrimLeft(query[end:], " \t\r\n")
+
+	return strings.HasPrefix(rest, ";")
+}
+
+func replaceMacros(query string, replace func(start int, end int, content string) (string, error)) (string, error) {
 	var out bytes.Buffer
 	last := 0
 
 	err := scanMacros(query, func(start int, end int, content string) error {
 		out.WriteString(query[last:start])
 
-		value, err := replace(content)
+		value, err := replace(start, end, content)
 		if err != nil {
 			return err
 		}
@@ -31,6 +31,23 @@ func TestExpandBBoxAndCenter(t *testing.T) {
 	}
 }
 
+func TestExpandPrecision(t *testing.T) {
+	t.Parallel()
+
+	res, err := Expand("node({{bbox}});out;{{center}}", Options{
+		BBox:      &BBox{South: 1.123456789, West: 2.2, North: 3.3, East: 4.4},
+		Center:    &Center{Lat: 5.987654321, Lon: 6.6},
+		Precision: 2,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !strings.Contains(res.Query, "node(1.12,2.2,3.3,4.4);") || !strings.Contains(res.Query, "5.99,6.6") {
+		t.Errorf("expected coordinates with 2 decimals, got %s", res.Query)
+	}
+}
+
 func TestExpandDate(t *testing.T) {
 	t.Parallel()
 
@@ -67,6 +84,114 @@ func TestCustomShortcuts(t *testing.T) {
 	}
 }
 
+func TestNestedShortcuts(t *testing.T) {
+	t.Parallel()
+
+	opts := Options{
+		Center: &Center{Lat: 52.5, Lon: 13.4},
+		Shortcuts: map[string]string{
+			"near":   "around:{{radius}},{{center}}",
+			"radius": "500",
+		},
+	}
+
+	res, err := Expand(`{{amenity=cafe}}node[amenity={{amenity}}]({{near}});out;`, opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := "node[amenity=cafe](around:500,52.5,13.4);out;"
+	if res.Query != expected {
+		t.Errorf("expected %s, got %s", expected, res.Query)
+	}
+}
+
+func TestCyclicShortcuts(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name      string
+		shortcuts map[string]string
+	}{
+		{"self reference", map[string]string{"a": "x{{a}}"}},
+		{"indirect cycle", map[string]string{"a": "{{b}}", "b": "{{c}}", "c": "{{a}}"}},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := Expand("node({{a}});out;", Options{Shortcuts: tc.shortcuts})
+			if !errors.Is(err, ErrMacroCycle) {
+				t.Fatalf("expected ErrMacroCycle, got %v", err)
+			}
+		})
+	}
+}
+
+func TestUnknownMacroMode(t *testing.T) {
+	t.Parallel()
+
+	query := "{{fixme: check tags}}node[amenity=cafe]({{bbox}});out;"
+	opts := Options{BBox: &BBox{South: 1, West: 2, North: 3, East: 4}}
+
+	testCases := []struct {
+		name     string
+		mode     UnknownMacroMode
+		expected string
+		wantErr  bool
+	}{
+		{"error", UnknownMacroError, "", true},
+		{"keep", UnknownMacroKeep, "{{fixme: check tags}}node[amenity=cafe](1,2,3,4);out;", false},
+		{"strip", UnknownMacroStrip, "node[amenity=cafe](1,2,3,4);out;", false},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			opts := opts
+			opts.UnknownMacroMode = tc.mode
+
+			res, err := Expand(query, opts)
+			if tc.wantErr {
+				if !errors.Is(err, ErrBadMacro) {
+					t.Fatalf("expected ErrBadMacro, got %v", err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if res.Query != tc.expected {
+				t.Errorf("expected %s, got %s", tc.expected, res.Query)
+			}
+		})
+	}
+}
+
+func TestUnknownMacroModeKeepsMalformedErrors(t *testing.T) {
+	t.Parallel()
+
+	// Recognized macros with invalid arguments still fail
+	_, err := Expand("node(newer:{{date:soon}});out;", Options{UnknownMacroMode: UnknownMacroStrip})
+	if err == nil {
+		t.Fatal("expected error for malformed date macro")
+	}
+
+	_, err = Expand("node({{bbox}});out;", Options{UnknownMacroMode: UnknownMacroKeep})
+	if !errors.Is(err, ErrMissingBBox) {
+		t.Fatalf("expected ErrMissingBBox, got %v", err)
+	}
+}
+
 func TestStyleAndDataExtraction(t *testing.T) {
 	t.Parallel()
 
@@ -104,6 +229,82 @@ node(1);out;`
 	}
 }
 
+func TestDataMacroFormat(t *testing.T) {
+	t.Parallel()
+
+	bbox := &BBox{South: 1, West: 2, North: 3, East: 4}
+
+	testCases := []struct {
+		name     string
+		query    string
+		format   QueryFormat
+		expected string
+	}{
+		{
+			"data macro xml overrides text detection",
+			"{{data:overpass,format=XML}}node({{bbox}});out;",
+			FormatAuto,
+			`node(s="1" w="2" n="3" e="4");out;`,
+		},
+		{
+			"data macro json overrides xml markers",
+			`{{data:overpass,format=json}}<query type="node"><bbox-query {{bbox}}/></query>`,
+			FormatAuto,
+			`<query type="node"><bbox-query 1,2,3,4/></query>`,
+		},
+		{
+			"explicit option wins over data macro",
+			"{{data:overpass,format=xml}}node({{bbox}});out;",
+			FormatQL,
+			"node(1,2,3,4);out;",
+		},
+		{
+			"unknown format falls back to detection",
+			"{{data:overpass,format=geojson}}node({{bbox}});out;",
+			FormatAuto,
+			"node(1,2,3,4);out;",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			res, err := Expand(tc.query, Options{BBox: bbox, Format: tc.format})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if res.Query != tc.expected {
+				t.Errorf("expected %s, got %s", tc.expected, res.Query)
+			}
+		})
+	}
+}
+
+func TestDataMacroFormatParsed(t *testing.T) {
+	t.Parallel()
+
+	res, err := Expand("{{data:overpass,format=CSV,foo=bar}}node(1);out;", Options{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if res.Data.Parsed.Format != "csv" {
+		t.Errorf("expected parsed format csv, got %q", res.Data.Parsed.Format)
+	}
+
+	if _, ok := res.Data.Parsed.Params["format"]; ok {
+		t.Error("format should not be duplicated in Params")
+	}
+
+	if res.Data.Options["format"] != "CSV" {
+		t.Errorf("raw options should keep the original value, got %q", res.Data.Options["format"])
+	}
+}
+
 func TestMultipleStyles(t *testing.T) {
 	t.Parallel()
 
@@ -123,6 +324,35 @@ func TestMultipleStyles(t *testing.T) {
 	}
 }
 
+func TestStripMacros(t *testing.T) {
+	t.Parallel()
+
+	query := `{{style: node { color: red; } }}{{data:overpass,server=https://example.com/api/}}` +
+		`[out:json][date:"{{date:1 day}}"];node[amenity=cafe]({{bbox}});out;{{custom=value}}`
+
+	stripped, err := StripMacros(query)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := `[out:json][date:""];node[amenity=cafe]();out;`
+	if stripped != expected {
+		t.Errorf("expected %q, got %q", expected, stripped)
+	}
+
+	if strings.Contains(stripped, "{{") || strings.Contains(stripped, "}}") {
+		t.Errorf("expected all macros removed, got %q", stripped)
+	}
+
+	if plain, err := StripMacros("node(1);out;"); err != nil || plain != "node(1);out;" {
+		t.Errorf("expected query without macros unchanged, got %q (%v)", plain, err)
+	}
+
+	if _, err := StripMacros("node({{bbox);out;"); !errors.Is(err, ErrBadMacro) {
+		t.Errorf("expected ErrBadMacro for unterminated macro, got %v", err)
+	}
+}
+
 func TestApplyEndpointOverride(t *testing.T) {
 	t.Parallel()
 
@@ -1,18 +1,28 @@
 package overpass
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 // ElementType represents possible types for Overpass response elements.
 type ElementType string
 
-// Possible values are node, way and relation.
+// Possible values are node, way and relation, plus area for the derived
+// area elements returned by is_in and area queries and count for the tallies
+// returned by "out count".
 const (
 	ElementTypeNode     ElementType = "node"
 	ElementTypeWay      ElementType = "way"
 	ElementTypeRelation ElementType = "relation"
+	ElementTypeArea     ElementType = "area"
+	ElementTypeCount    ElementType = "count"
 )
 
-// Meta contains fields common for all OSM types.
+// Meta contains fields common for all OSM types. Strings decoded from a
+// response are always valid UTF-8: invalid byte sequences in tag keys, values
+// and user names are replaced with U+FFFD, so results can be re-encoded as
+// JSON or GeoJSON safely.
 type Meta struct {
 	ID        int64             `json:"id"`
 	Timestamp *time.Time        `json:"timestamp,omitempty"`
@@ -38,6 +48,11 @@ type Way struct {
 	Nodes    []*Node `json:"nodes,omitempty"`
 	Bounds   *Box    `json:"bounds,omitempty"`
 	Geometry []Point `json:"geometry,omitempty"`
+	Center   *Point  `json:"center,omitempty"`
+	// HasCompleteGeometry reports whether the decoded inline geometry had no
+	// null or invalid entries (e.g. nodes clipped by the query bbox). It is
+	// false when the way came without geometry.
+	HasCompleteGeometry bool `json:"complete_geometry,omitempty"`
 }
 
 type Point struct {
@@ -51,6 +66,22 @@ type Relation struct {
 
 	Members []RelationMember `json:"members,omitempty"`
 	Bounds  *Box             `json:"bounds,omitempty"`
+	Center  *Point           `json:"center,omitempty"`
+}
+
+// Area represents an Overpass area element, derived from a closed way (id +
+// 2400000000) or a relation (id + 3600000000). Areas carry only metadata.
+type Area struct {
+	Meta
+}
+
+// ElementCount is the tally returned by an "out count" statement.
+type ElementCount struct {
+	Nodes     int `json:"nodes"`
+	Ways      int `json:"ways"`
+	Relations int `json:"relations"`
+	Areas     int `json:"areas,omitempty"`
+	Total     int `json:"total"`
 }
 
 type Box struct {
@@ -58,6 +89,12 @@ type Box struct {
 	Max Point `json:"max"`
 }
 
+// ToBoundingBox converts b to the south/west/north/east form used by the
+// query builder.
+func (b Box) ToBoundingBox() BoundingBox {
+	return BoundingBox{South: b.Min.Lat, West: b.Min.Lon, North: b.Max.Lat, East: b.Max.Lon}
+}
+
 // RelationMember represents OSM relation member type.
 type RelationMember struct {
 	Type     ElementType `json:"type"`
@@ -69,9 +106,35 @@ type RelationMember struct {
 
 // Result returned by Query and contains parsed result of Overpass query.
 type Result struct {
-	Timestamp time.Time           `json:"timestamp"`
-	Count     int                 `json:"count"`
-	Nodes     map[int64]*Node     `json:"nodes,omitempty"`
-	Ways      map[int64]*Way      `json:"ways,omitempty"`
-	Relations map[int64]*Relation `json:"relations,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+	// DataTimestampRaw is timestamp_osm_base as sent by the server. It is kept
+	// even when it cannot be parsed into Timestamp (which then stays zero).
+	DataTimestampRaw string `json:"timestamp_raw,omitempty"`
+	// TimestampAreasBase is the state of the areas database
+	// (timestamp_areas_base), which is rebuilt separately and may lag behind
+	// Timestamp. It is zero unless the query used areas.
+	TimestampAreasBase time.Time           `json:"timestamp_areas_base"`
+	Count              int                 `json:"count"`
+	Nodes              map[int64]*Node     `json:"nodes,omitempty"`
+	Ways               map[int64]*Way      `json:"ways,omitempty"`
+	Relations          map[int64]*Relation `json:"relations,omitempty"`
+	// Areas holds area elements, e.g. from is_in. It is nil unless the
+	// response contained any.
+	Areas map[int64]*Area `json:"areas,omitempty"`
+	// Counts holds the results of "out count" statements in query order.
+	Counts []ElementCount `json:"counts,omitempty"`
+	// Raw holds each element's source JSON keyed by "type/id" (e.g. "node/1").
+	// It is only populated when Client.KeepRawJSON is set; see RawJSON.
+	Raw map[string]json.RawMessage `json:"-"`
+	// Query is the query text sent by the client (after any prologue) that
+	// produced this result. It is empty for results decoded directly.
+	Query string `json:"query,omitempty"`
+}
+
+// RawJSON returns the source JSON of an element if the result was decoded
+// with Client.KeepRawJSON.
+func (r *Result) RawJSON(elemType ElementType, id int64) (json.RawMessage, bool) {
+	raw, ok := r.Raw[elementKey(elemType, id)]
+
+	return raw, ok
 }
@@ -0,0 +1,171 @@
+package overpass
+
+import (
+	"encoding/xml"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// xmlResponse is an [out:xml] response: <osm> with a <meta> header and the
+// elements in document order.
+type xmlResponse struct {
+	Meta struct {
+		OSMBase string `xml:"osm_base,attr"`
+		Areas   string `xml:"areas,attr"`
+	} `xml:"meta"`
+	Elements []xmlElement `xml:",any"`
+}
+
+type xmlElement struct {
+	XMLName   xml.Name
+	ID        int64       `xml:"id,attr"`
+	Lat       float64     `xml:"lat,attr"`
+	Lon       float64     `xml:"lon,attr"`
+	Timestamp string      `xml:"timestamp,attr"`
+	Version   int64       `xml:"version,attr"`
+	Changeset int64       `xml:"changeset,attr"`
+	User      string      `xml:"user,attr"`
+	UID       int64       `xml:"uid,attr"`
+	Bounds    *xmlBounds  `xml:"bounds"`
+	Center    *xmlPoint   `xml:"center"`
+	Nds       []xmlNd     `xml:"nd"`
+	Members   []xmlMember `xml:"member"`
+	Tags      []xmlTag    `xml:"tag"`
+}
+
+type xmlPoint struct {
+	Lat float64 `xml:"lat,attr"`
+	Lon float64 `xml:"lon,attr"`
+}
+
+type xmlBounds struct {
+	MinLat float64 `xml:"minlat,attr"`
+	MinLon float64 `xml:"minlon,attr"`
+	MaxLat float64 `xml:"maxlat,attr"`
+	MaxLon float64 `xml:"maxlon,attr"`
+}
+
+// xmlNd is a way node reference, carrying coordinates with "out geom".
+type xmlNd struct {
+	Ref int64    `xml:"ref,attr"`
+	Lat *float64 `xml:"lat,attr"`
+	Lon *float64 `xml:"lon,attr"`
+}
+
+type xmlMember struct {
+	Type ElementType `xml:"type,attr"`
+	Ref  int64       `xml:"ref,attr"`
+	Role string      `xml:"role,attr"`
+	Nds  []xmlNd     `xml:"nd"`
+}
+
+type xmlTag struct {
+	K string `xml:"k,attr"`
+	V string `xml:"v,attr"`
+}
+
+// unmarshalXML decodes an [out:xml] response into the same Result the JSON
+// decoder produces. Elements are converted to their JSON form first, so both
+// formats share the element handling.
+func unmarshalXML(body []byte) (Result, error) {
+	var response xmlResponse
+
+	err := xml.Unmarshal(body, &response)
+	if err != nil {
+		return Result{}, fmt.Errorf("overpass engine error: %w", err)
+	}
+
+	result := Result{
+		Timestamp:          parseDataTimestamp(response.Meta.OSMBase),
+		DataTimestampRaw:   response.Meta.OSMBase,
+		TimestampAreasBase: parseDataTimestamp(response.Meta.Areas),
+		Nodes:              make(map[int64]*Node),
+		Ways:               make(map[int64]*Way),
+		Relations:          make(map[int64]*Relation),
+	}
+
+	for _, element := range response.Elements {
+		switch ElementType(element.XMLName.Local) {
+		case ElementTypeNode, ElementTypeWay, ElementTypeRelation, ElementTypeArea, ElementTypeCount:
+			result.addElement(element.toResponseElement())
+			result.Count++
+		}
+	}
+
+	return result, nil
+}
+
+// toResponseElement converts the element to its JSON representation. Way
+// node references become "nodes" and their coordinates "geometry", as with
+// [out:json] and "out geom".
+func (e *xmlElement) toResponseElement() overpassResponseElement {
+	element := overpassResponseElement{
+		Type:      ElementType(e.XMLName.Local),
+		ID:        e.ID,
+		Lat:       e.Lat,
+		Lon:       e.Lon,
+		Version:   e.Version,
+		Changeset: e.Changeset,
+		User:      e.User,
+		UID:       e.UID,
+	}
+
+	if e.Center != nil {
+		element.Center = &Point{Lat: e.Center.Lat, Lon: e.Center.Lon}
+	}
+
+	if ts, err := time.Parse(time.RFC3339, e.Timestamp); err == nil {
+		element.Timestamp = &ts
+	}
+
+	if e.Bounds != nil {
+		element.Bounds = &overpassBounds{
+			MinLat: e.Bounds.MinLat, MinLon: e.Bounds.MinLon,
+			MaxLat: e.Bounds.MaxLat, MaxLon: e.Bounds.MaxLon,
+		}
+	}
+
+	for _, nd := range e.Nds {
+		element.Nodes = append(element.Nodes, nodeRef{ID: nd.Ref})
+	}
+
+	element.Geometry = xmlGeometry(e.Nds)
+
+	for _, member := range e.Members {
+		element.Members = append(element.Members, overpassMember{
+			Type:     member.Type,
+			Ref:      member.Ref,
+			Role:     member.Role,
+			Geometry: xmlGeometry(member.Nds),
+		})
+	}
+
+	if len(e.Tags) > 0 {
+		element.Tags = make(map[string]string, len(e.Tags))
+		for _, tag := range e.Tags {
+			element.Tags[tag.K] = tag.V
+		}
+	}
+
+	return element
+}
+
+// xmlGeometry returns the coordinates of nds, or nil if none carries any.
+// An nd without coordinates (clipped by the query bbox) becomes a nil entry,
+// as the null it is in the JSON output.
+func xmlGeometry(nds []xmlNd) []*geometryPoint {
+	if !slices.ContainsFunc(nds, func(nd xmlNd) bool { return nd.Lat != nil || nd.Lon != nil }) {
+		return nil
+	}
+
+	geometry := make([]*geometryPoint, len(nds))
+
+	for i, nd := range nds {
+		if nd.Lat != nil || nd.Lon != nil {
+			geometry[i] = &geometryPoint{Lat: nd.Lat, Lon: nd.Lon}
+		}
+	}
+
+	return geometry
+}
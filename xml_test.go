@@ -0,0 +1,81 @@
+package overpass
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestUnmarshalXML(t *testing.T) {
+	t.Parallel()
+
+	body := `<osm>
+  <relation id="7">
+    <bounds minlat="52.5" minlon="13.4" maxlat="52.6" maxlon="13.5"/>
+    <member type="way" ref="10" role="outer">
+      <nd lat="52.5" lon="13.4"/><nd lat="52.6" lon="13.5"/>
+    </member>
+    <member type="node" ref="1" role="label"/>
+  </relation>
+  <remark>runtime warning</remark>
+  <count id="0"><tag k="nodes" v="3"/><tag k="total" v="3"/></count>
+</osm>`
+
+	result, err := unmarshal([]byte(body))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	relation := result.Relations[7]
+	if relation == nil || relation.Bounds == nil || relation.Bounds.Max != (Point{52.6, 13.5}) {
+		t.Fatalf("unexpected relation: %+v", relation)
+	}
+
+	if way := relation.Members[0].Way; way == nil || len(way.Geometry) != 2 || !way.HasCompleteGeometry {
+		t.Errorf("expected member way geometry, got %+v", way)
+	}
+
+	if relation.Members[1].Node != result.Nodes[1] {
+		t.Error("expected node member to be linked")
+	}
+
+	if len(result.Counts) != 1 || result.Counts[0].Nodes != 3 || result.Count != 2 {
+		t.Errorf("unexpected counts %+v (%d elements)", result.Counts, result.Count)
+	}
+
+	_, err = unmarshal([]byte("<osm><node id=</osm>"))
+	if err == nil {
+		t.Error("expected error for malformed XML")
+	}
+}
+
+func TestUnmarshalXMLClippedGeometry(t *testing.T) {
+	t.Parallel()
+
+	fromJSON, err := unmarshal([]byte(`{"elements":[{"type":"way","id":10,"nodes":[1,2,3],` +
+		`"geometry":[{"lat":52.5,"lon":13.4},null,{"lat":52.6,"lon":13.5}]}]}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	fromXML, err := unmarshal([]byte(`<osm><way id="10">` +
+		`<nd ref="1" lat="52.5" lon="13.4"/><nd ref="2"/><nd ref="3" lat="52.6" lon="13.5"/>` +
+		`</way></osm>`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	jsonWay, xmlWay := fromJSON.Ways[10], fromXML.Ways[10]
+
+	if jsonWay.HasCompleteGeometry || xmlWay.HasCompleteGeometry {
+		t.Errorf("clipped geometry must be incomplete: json %v, xml %v",
+			jsonWay.HasCompleteGeometry, xmlWay.HasCompleteGeometry)
+	}
+
+	if !slices.Equal(jsonWay.Geometry, xmlWay.Geometry) {
+		t.Errorf("geometry differs: json %v, xml %v", jsonWay.Geometry, xmlWay.Geometry)
+	}
+
+	if len(jsonWay.Nodes) != len(xmlWay.Nodes) {
+		t.Errorf("node refs differ: json %d, xml %d", len(jsonWay.Nodes), len(xmlWay.Nodes))
+	}
+}